@@ -0,0 +1,908 @@
+package vcfgo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// type codes of BCF2 typed values.
+const (
+	bcfTypeNull  = 0
+	bcfTypeInt8  = 1
+	bcfTypeInt16 = 2
+	bcfTypeInt32 = 3
+	bcfTypeFloat = 5
+	bcfTypeChar  = 7
+)
+
+// BCF2 reserves the two lowest values of each integer type for missing and end-of-vector.
+const (
+	bcfInt8Missing  = math.MinInt8
+	bcfInt16Missing = math.MinInt16
+	bcfInt32Missing = math.MinInt32
+
+	bcfFloatEOV uint32 = 0x7F800002
+)
+
+var bcfMagic = []byte("BCF\x02")
+
+var errBCFTruncated = errors.New("bcf: truncated record")
+
+// headerIDRegexp finds the ID of a structured header line.
+var headerIDRegexp = regexp.MustCompile(`=<ID=([^,>]+)`)
+
+// isBCF reports whether buf starts with the magic of BCF 2.1 or 2.2.
+func isBCF(buf *bufio.Reader) bool {
+	m, _ := buf.Peek(5)
+	return len(m) == 5 && bytes.Equal(m[:4], bcfMagic) && (m[4] == 1 || m[4] == 2)
+}
+
+// readBCFHeader reads the magic and returns the text header.
+func readBCFHeader(buf *bufio.Reader) (string, error) {
+	var h [9]byte
+	if _, err := io.ReadFull(buf, h[:]); err != nil {
+		return "", fmt.Errorf("bcf: error reading header: %w", err)
+	}
+	text := make([]byte, binary.LittleEndian.Uint32(h[5:]))
+	if _, err := io.ReadFull(buf, text); err != nil {
+		return "", fmt.Errorf("bcf: error reading header: %w", err)
+	}
+	return strings.TrimRight(string(text), "\x00"), nil
+}
+
+// bcfDictionaries returns the string dictionary (FILTER, INFO and FORMAT ids)
+// and the contig dictionary defined by a header. PASS is always first and an
+// IDX attribute, when present, gives the position of an entry.
+func bcfDictionaries(text string) (strs []string, contigs []string) {
+	strs = []string{"PASS"}
+	seen := map[string]bool{"PASS": true}
+	set := func(dict []string, i int, id string) []string {
+		for len(dict) <= i {
+			dict = append(dict, "")
+		}
+		dict[i] = id
+		return dict
+	}
+	for _, line := range strings.Split(text, "\n") {
+		isContig := strings.HasPrefix(line, "##contig=<")
+		if !isContig && !strings.HasPrefix(line, "##FILTER=<") && !strings.HasPrefix(line, "##INFO=<") && !strings.HasPrefix(line, "##FORMAT=<") {
+			continue
+		}
+		m := headerIDRegexp.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		id := m[1]
+		idx := -1
+		if im := idxRegexp.FindStringSubmatch(line); im != nil {
+			idx, _ = strconv.Atoi(im[1])
+		}
+		if isContig {
+			if idx >= 0 {
+				contigs = set(contigs, idx, id)
+			} else {
+				contigs = append(contigs, id)
+			}
+			continue
+		}
+		if idx >= 0 {
+			strs = set(strs, idx, id)
+		} else if !seen[id] {
+			strs = append(strs, id)
+		}
+		seen[id] = true
+	}
+	return strs, contigs
+}
+
+// stripHeaderIDX removes the IDX attributes of the FILTER, INFO, FORMAT and
+// contig lines of a BCF header so that the Header holds them as a VCF would.
+func stripHeaderIDX(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, "##FILTER=<") || strings.HasPrefix(line, "##INFO=<") || strings.HasPrefix(line, "##FORMAT=<") || strings.HasPrefix(line, "##contig=<") {
+			lines[i] = stripIDX(line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// bcfDecoder turns BCF records into VCF text lines so that they are parsed
+// exactly as a text VCF would be.
+type bcfDecoder struct {
+	dict    []string
+	contigs []string
+	// flags holds the INFO keys with Type=Flag, whose values are not written.
+	flags map[string]bool
+
+	shared, indiv []byte
+	fields        []bcfFormat
+}
+
+// bcfFormat holds the values of a FORMAT field for all samples.
+type bcfFormat struct {
+	key  string
+	typ  byte
+	n    int
+	data []byte
+}
+
+func newBCFDecoder(text string) *bcfDecoder {
+	d := &bcfDecoder{flags: make(map[string]bool)}
+	d.dict, d.contigs = bcfDictionaries(text)
+	for _, line := range strings.Split(text, "\n") {
+		if !strings.HasPrefix(line, "##INFO=<") {
+			continue
+		}
+		if info, err := parseHeaderInfo(stripIDX(line)); err == nil && info.Type == "Flag" {
+			d.flags[info.Id] = true
+		}
+	}
+	return d
+}
+
+// readBCF decodes the next record. See Reader.Read.
+func (vr *Reader) readBCF() *Variant {
+	line, err := vr.bcf.next(vr.buf)
+	if err != nil {
+		if err != io.EOF {
+			vr.verr.Add(err, vr.LineNumber)
+		}
+		return nil
+	}
+	vr.LineNumber++
+	return vr.Parse(makeFields(line))
+}
+
+// next reads a record from r and returns it as a line of VCF text.
+func (d *bcfDecoder) next(r io.Reader) ([]byte, error) {
+	var lens [8]byte
+	if _, err := io.ReadFull(r, lens[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = errBCFTruncated
+		}
+		return nil, err
+	}
+	ls, li := binary.LittleEndian.Uint32(lens[:]), binary.LittleEndian.Uint32(lens[4:])
+	d.shared = growBytes(d.shared, int(ls))
+	d.indiv = growBytes(d.indiv, int(li))
+	if _, err := io.ReadFull(r, d.shared); err != nil {
+		return nil, errBCFTruncated
+	}
+	if _, err := io.ReadFull(r, d.indiv); err != nil {
+		return nil, errBCFTruncated
+	}
+	return d.decode()
+}
+
+func growBytes(b []byte, n int) []byte {
+	if cap(b) < n {
+		return make([]byte, n)
+	}
+	return b[:n]
+}
+
+func bcfSize(typ byte) int {
+	switch typ {
+	case bcfTypeInt8, bcfTypeChar:
+		return 1
+	case bcfTypeInt16:
+		return 2
+	case bcfTypeInt32, bcfTypeFloat:
+		return 4
+	}
+	return 0
+}
+
+// bcfInt returns the integer at the start of p and whether it is missing or marks the end of a vector.
+func bcfInt(typ byte, p []byte) (v int32, missing, eov bool) {
+	var min int32
+	switch typ {
+	case bcfTypeInt8:
+		v, min = int32(int8(p[0])), bcfInt8Missing
+	case bcfTypeInt16:
+		v, min = int32(int16(binary.LittleEndian.Uint16(p))), bcfInt16Missing
+	default:
+		v, min = int32(binary.LittleEndian.Uint32(p)), bcfInt32Missing
+	}
+	return v, v == min, v == min+1
+}
+
+// bcfTyped reads a type descriptor and returns the type, the count and the remaining bytes.
+func bcfTyped(p []byte) (typ byte, n int, rest []byte, err error) {
+	if len(p) == 0 {
+		return 0, 0, nil, errBCFTruncated
+	}
+	typ, n, p = p[0]&0xf, int(p[0]>>4), p[1:]
+	if n == 15 {
+		// the count follows as a typed integer.
+		t, m, q, err := bcfTyped(p)
+		if err != nil || m != 1 || bcfSize(t) == 0 || len(q) < bcfSize(t) || t == bcfTypeFloat || t == bcfTypeChar {
+			return 0, 0, nil, errBCFTruncated
+		}
+		v, _, _ := bcfInt(t, q)
+		n, p = int(v), q[bcfSize(t):]
+	}
+	if n < 0 || len(p) < n*bcfSize(typ) {
+		return 0, 0, nil, errBCFTruncated
+	}
+	return typ, n, p, nil
+}
+
+// bcfTypedInt reads a typed integer such as a dictionary key.
+func bcfTypedInt(p []byte) (int, []byte, error) {
+	typ, n, p, err := bcfTyped(p)
+	if err != nil {
+		return 0, nil, err
+	}
+	if n != 1 || typ == bcfTypeFloat || typ == bcfTypeChar || typ == bcfTypeNull {
+		return 0, nil, errors.New("bcf: expected a single integer")
+	}
+	v, _, _ := bcfInt(typ, p)
+	return int(v), p[bcfSize(typ):], nil
+}
+
+// appendBCFValues appends the n values of type typ in p as comma-separated VCF text.
+// Values after an end-of-vector marker are dropped and an empty vector is written as '.'.
+func appendBCFValues(dst []byte, typ byte, n int, p []byte) []byte {
+	start := len(dst)
+	switch typ {
+	case bcfTypeChar:
+		s := p[:n]
+		if i := bytes.IndexByte(s, 0); i >= 0 {
+			s = s[:i]
+		}
+		dst = append(dst, s...)
+	case bcfTypeFloat:
+		for i := 0; i < n; i++ {
+			bits := binary.LittleEndian.Uint32(p[i*4:])
+			if bits == bcfFloatEOV {
+				break
+			}
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			if bits == missingBits {
+				dst = append(dst, '.')
+			} else {
+				dst = strconv.AppendFloat(dst, float64(math.Float32frombits(bits)), 'g', -1, 32)
+			}
+		}
+	case bcfTypeInt8, bcfTypeInt16, bcfTypeInt32:
+		size := bcfSize(typ)
+		for i := 0; i < n; i++ {
+			v, missing, eov := bcfInt(typ, p[i*size:])
+			if eov {
+				break
+			}
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			if missing {
+				dst = append(dst, '.')
+			} else {
+				dst = strconv.AppendInt(dst, int64(v), 10)
+			}
+		}
+	}
+	if len(dst) == start {
+		dst = append(dst, '.')
+	}
+	return dst
+}
+
+// appendBCFGenotype appends a GT value. Each allele is stored as (allele+1)<<1|phased
+// where the phase bit describes the separator before the allele.
+func appendBCFGenotype(dst []byte, typ byte, n int, p []byte) []byte {
+	start := len(dst)
+	size := bcfSize(typ)
+	for i := 0; i < n; i++ {
+		v, missing, eov := bcfInt(typ, p[i*size:])
+		if eov {
+			break
+		}
+		if i > 0 {
+			if v&1 == 1 {
+				dst = append(dst, '|')
+			} else {
+				dst = append(dst, '/')
+			}
+		}
+		if allele := v>>1 - 1; missing || allele < 0 {
+			dst = append(dst, '.')
+		} else {
+			dst = strconv.AppendInt(dst, int64(allele), 10)
+		}
+	}
+	if len(dst) == start {
+		dst = append(dst, '.')
+	}
+	return dst
+}
+
+func (d *bcfDecoder) lookup(i int) (string, error) {
+	if i < 0 || i >= len(d.dict) || d.dict[i] == "" {
+		return "", fmt.Errorf("bcf: key %d is not in the header dictionary", i)
+	}
+	return d.dict[i], nil
+}
+
+// decode converts the current record to VCF text. The returned slice is newly
+// allocated since the Variant made from it keeps a reference to the INFO field.
+func (d *bcfDecoder) decode() ([]byte, error) {
+	p := d.shared
+	if len(p) < 24 {
+		return nil, errBCFTruncated
+	}
+	chrom := int(int32(binary.LittleEndian.Uint32(p)))
+	pos := int32(binary.LittleEndian.Uint32(p[4:]))
+	qual := binary.LittleEndian.Uint32(p[12:])
+	nInfo := int(binary.LittleEndian.Uint16(p[16:]))
+	nAllele := int(binary.LittleEndian.Uint16(p[18:]))
+	nSample := int(binary.LittleEndian.Uint32(p[20:]) & 0xffffff)
+	nFmt := int(p[23])
+	p = p[24:]
+	if chrom < 0 || chrom >= len(d.contigs) {
+		return nil, fmt.Errorf("bcf: contig %d is not in the header", chrom)
+	}
+
+	line := make([]byte, 0, len(d.shared)+2*len(d.indiv)+64)
+	line = append(line, d.contigs[chrom]...)
+	line = append(line, '\t')
+	line = strconv.AppendInt(line, int64(pos)+1, 10)
+	line = append(line, '\t')
+
+	typ, n, p, err := bcfTyped(p)
+	if err != nil {
+		return nil, err
+	}
+	line = appendBCFValues(line, typ, n, p)
+	p = p[n*bcfSize(typ):]
+
+	for i := 0; i < nAllele; i++ {
+		if typ, n, p, err = bcfTyped(p); err != nil {
+			return nil, err
+		}
+		if i < 2 {
+			line = append(line, '\t')
+		} else {
+			line = append(line, ',')
+		}
+		line = appendBCFValues(line, typ, n, p)
+		p = p[n*bcfSize(typ):]
+	}
+	for i := nAllele; i < 2; i++ {
+		line = append(line, "\t."...)
+	}
+
+	line = append(line, '\t')
+	if qual == missingBits {
+		line = append(line, '.')
+	} else {
+		line = strconv.AppendFloat(line, float64(math.Float32frombits(qual)), 'g', -1, 32)
+	}
+
+	line = append(line, '\t')
+	if typ, n, p, err = bcfTyped(p); err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		line = append(line, '.')
+	}
+	size := bcfSize(typ)
+	for i := 0; i < n; i++ {
+		v, _, _ := bcfInt(typ, p[i*size:])
+		id, err := d.lookup(int(v))
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			line = append(line, ';')
+		}
+		line = append(line, id...)
+	}
+	p = p[n*size:]
+
+	line = append(line, '\t')
+	for i := 0; i < nInfo; i++ {
+		var key int
+		if key, p, err = bcfTypedInt(p); err != nil {
+			return nil, err
+		}
+		id, err := d.lookup(key)
+		if err != nil {
+			return nil, err
+		}
+		if typ, n, p, err = bcfTyped(p); err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			line = append(line, ';')
+		}
+		line = append(line, id...)
+		// a flag has no values, though the spec suggests to encode it as an int8 1.
+		if n > 0 && typ != bcfTypeNull && !d.flags[id] {
+			line = append(line, '=')
+			line = appendBCFValues(line, typ, n, p)
+		}
+		p = p[n*bcfSize(typ):]
+	}
+	if nInfo == 0 {
+		line = append(line, '.')
+	}
+
+	if nFmt == 0 || nSample == 0 {
+		return line, nil
+	}
+	q := d.indiv
+	d.fields = d.fields[:0]
+	for i := 0; i < nFmt; i++ {
+		var key int
+		if key, q, err = bcfTypedInt(q); err != nil {
+			return nil, err
+		}
+		f := bcfFormat{}
+		if f.key, err = d.lookup(key); err != nil {
+			return nil, err
+		}
+		if f.typ, f.n, q, err = bcfTyped(q); err != nil {
+			return nil, err
+		}
+		l := nSample * f.n * bcfSize(f.typ)
+		if len(q) < l {
+			return nil, errBCFTruncated
+		}
+		f.data, q = q[:l], q[l:]
+		d.fields = append(d.fields, f)
+	}
+	line = append(line, '\t')
+	for i, f := range d.fields {
+		if i > 0 {
+			line = append(line, ':')
+		}
+		line = append(line, f.key...)
+	}
+	for s := 0; s < nSample; s++ {
+		line = append(line, '\t')
+		for i, f := range d.fields {
+			if i > 0 {
+				line = append(line, ':')
+			}
+			w := f.n * bcfSize(f.typ)
+			if f.key == "GT" && f.typ != bcfTypeChar && f.typ != bcfTypeFloat {
+				line = appendBCFGenotype(line, f.typ, f.n, f.data[s*w:])
+			} else {
+				line = appendBCFValues(line, f.typ, f.n, f.data[s*w:])
+			}
+		}
+	}
+	return line, nil
+}
+
+// the smallest values that are not reserved for the int8 and int16 types.
+const (
+	bcfInt8Min  = -120
+	bcfInt16Min = -32760
+)
+
+// writeBCFHeader writes the magic and the text header.
+func writeBCFHeader(w io.Writer, text string) error {
+	var b bytes.Buffer
+	b.Write(bcfMagic)
+	b.WriteByte(2)
+	binary.Write(&b, binary.LittleEndian, uint32(len(text)+1))
+	b.WriteString(text)
+	b.WriteByte(0)
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// bcfEncoder writes Variants as BCF records. The types of INFO and FORMAT
+// values come from the header.
+type bcfEncoder struct {
+	h       *Header
+	strs    map[string]int
+	contigs map[string]int
+
+	shared, indiv bytes.Buffer
+	ints          []int32
+	floats        []uint32
+	// pick holds the indexes of the sample columns to encode, if not all.
+	pick []int
+}
+
+func newBCFEncoder(h *Header, text string) *bcfEncoder {
+	strs, contigs := bcfDictionaries(text)
+	e := &bcfEncoder{h: h, strs: make(map[string]int, len(strs)), contigs: make(map[string]int, len(contigs))}
+	for i, s := range strs {
+		if s != "" {
+			e.strs[s] = i
+		}
+	}
+	for i, c := range contigs {
+		if c != "" {
+			e.contigs[c] = i
+		}
+	}
+	return e
+}
+
+// bcfEncodeSize writes a type descriptor for n values of typ.
+func bcfEncodeSize(b *bytes.Buffer, typ byte, n int) {
+	if n < 15 {
+		b.WriteByte(byte(n)<<4 | typ)
+		return
+	}
+	b.WriteByte(15<<4 | typ)
+	bcfEncodeInts(b, []int32{int32(n)})
+}
+
+// bcfIntType returns the smallest type that holds vals. bcfInt32Missing and
+// the end-of-vector value after it are markers that fit any type.
+func bcfIntType(vals []int32) byte {
+	typ := byte(bcfTypeInt8)
+	for _, v := range vals {
+		if v == bcfInt32Missing || v == bcfInt32Missing+1 {
+			continue
+		}
+		if v < bcfInt16Min || v > math.MaxInt16 {
+			return bcfTypeInt32
+		}
+		if v < bcfInt8Min || v > math.MaxInt8 {
+			typ = bcfTypeInt16
+		}
+	}
+	return typ
+}
+
+// bcfPutInts writes vals as typ without a descriptor.
+func bcfPutInts(b *bytes.Buffer, typ byte, vals []int32) {
+	var tmp [4]byte
+	for _, v := range vals {
+		switch typ {
+		case bcfTypeInt8:
+			if v == bcfInt32Missing || v == bcfInt32Missing+1 {
+				v += bcfInt8Missing - bcfInt32Missing
+			}
+			b.WriteByte(byte(int8(v)))
+		case bcfTypeInt16:
+			if v == bcfInt32Missing || v == bcfInt32Missing+1 {
+				v += bcfInt16Missing - bcfInt32Missing
+			}
+			binary.LittleEndian.PutUint16(tmp[:], uint16(int16(v)))
+			b.Write(tmp[:2])
+		default:
+			binary.LittleEndian.PutUint32(tmp[:], uint32(v))
+			b.Write(tmp[:])
+		}
+	}
+}
+
+// bcfEncodeInts writes vals as a typed vector of the smallest integer type.
+func bcfEncodeInts(b *bytes.Buffer, vals []int32) {
+	if len(vals) == 0 {
+		bcfEncodeSize(b, bcfTypeNull, 0)
+		return
+	}
+	typ := bcfIntType(vals)
+	bcfEncodeSize(b, typ, len(vals))
+	bcfPutInts(b, typ, vals)
+}
+
+func bcfPutFloats(b *bytes.Buffer, vals []uint32) {
+	var tmp [4]byte
+	for _, v := range vals {
+		binary.LittleEndian.PutUint32(tmp[:], v)
+		b.Write(tmp[:])
+	}
+}
+
+func bcfEncodeString(b *bytes.Buffer, s string) {
+	bcfEncodeSize(b, bcfTypeChar, len(s))
+	b.WriteString(s)
+}
+
+// appendInts parses the comma-separated integers in s, '.' being missing.
+func appendInts(dst []int32, s string) ([]int32, error) {
+	for _, f := range strings.Split(s, ",") {
+		if f == "." || f == "" {
+			dst = append(dst, bcfInt32Missing)
+			continue
+		}
+		v, err := strconv.ParseInt(f, 10, 32)
+		if err != nil || v <= bcfInt32Missing+1 {
+			return dst, fmt.Errorf("bad Integer value: %s", s)
+		}
+		dst = append(dst, int32(v))
+	}
+	return dst, nil
+}
+
+// appendFloats parses the comma-separated floats in s, '.' being missing.
+func appendFloats(dst []uint32, s string) ([]uint32, error) {
+	for _, f := range strings.Split(s, ",") {
+		if f == "." || f == "" {
+			dst = append(dst, missingBits)
+			continue
+		}
+		v, err := strconv.ParseFloat(f, 32)
+		if err != nil {
+			return dst, fmt.Errorf("bad Float value: %s", s)
+		}
+		dst = append(dst, math.Float32bits(float32(v)))
+	}
+	return dst, nil
+}
+
+// appendGenotype encodes a GT value such as 0/1 or 1|2.
+func appendGenotype(dst []int32, s string) []int32 {
+	phased := int32(0)
+	for len(s) > 0 {
+		i := strings.IndexAny(s, "/|")
+		a := s
+		if i >= 0 {
+			a = s[:i]
+		}
+		if v, err := strconv.Atoi(a); err == nil && v >= 0 {
+			dst = append(dst, int32(v+1)<<1|phased)
+		} else {
+			dst = append(dst, phased)
+		}
+		if i < 0 {
+			break
+		}
+		if s[i] == '|' {
+			phased = 1
+		} else {
+			phased = 0
+		}
+		s = s[i+1:]
+	}
+	return dst
+}
+
+func (e *bcfEncoder) key(kind, id string) (int32, error) {
+	i, ok := e.strs[id]
+	if !ok {
+		return 0, fmt.Errorf("vcfgo: bcf: %s %s is not defined in the header", kind, id)
+	}
+	return int32(i), nil
+}
+
+// samples returns the FORMAT values of each sample.
+func (e *bcfEncoder) samples(v *Variant) [][]string {
+	texts := v.sampleColumns()
+	if e.pick != nil && len(texts) > 0 {
+		texts = pickColumns(texts, e.pick)
+	}
+	values := make([][]string, len(texts))
+	for i, t := range texts {
+		values[i] = strings.Split(t, ":")
+	}
+	return values
+}
+
+// write encodes v and writes it to w as a single record.
+func (e *bcfEncoder) write(w io.Writer, v *Variant) error {
+	tid, ok := e.contigs[v.Chromosome]
+	if !ok {
+		return fmt.Errorf("vcfgo: bcf: contig %s is not in the header", v.Chromosome)
+	}
+	// BCF stores the 0-based position as an int32; POS 0 is written as -1.
+	if v.Pos > math.MaxInt32+1 {
+		return fmt.Errorf("vcfgo: bcf: POS %d at %s does not fit in BCF: %w", v.Pos, v.Chromosome, ErrInvalidValue)
+	}
+	s := &e.shared
+	s.Reset()
+	e.indiv.Reset()
+
+	id := v.Id_
+	if id == "." {
+		id = ""
+	}
+	bcfEncodeString(s, id)
+	nAllele := 1
+	bcfEncodeString(s, v.Reference)
+	if alts := v.Alternate; !(len(alts) == 1 && alts[0] == ".") {
+		for _, a := range alts {
+			bcfEncodeString(s, a)
+		}
+		nAllele += len(alts)
+	}
+	rlen := uint32(len(v.Reference))
+	if len(v.Alternate) > 0 {
+		rlen = v.End() - v.Start()
+	}
+
+	e.ints = e.ints[:0]
+	if v.Filter != "." && v.Filter != "" {
+		for _, f := range strings.Split(v.Filter, ";") {
+			k, err := e.key("FILTER", f)
+			if err != nil {
+				return err
+			}
+			e.ints = append(e.ints, k)
+		}
+	}
+	bcfEncodeInts(s, e.ints)
+
+	nInfo := 0
+	if info := v.Info_; info != nil {
+		if text := info.String(); text != "" && text != "." {
+			for _, kv := range strings.Split(text, ";") {
+				key, val, hasVal := strings.Cut(kv, "=")
+				k, err := e.key("INFO", key)
+				if err != nil {
+					return err
+				}
+				def := e.h.Infos[key]
+				if def == nil {
+					return fmt.Errorf("vcfgo: bcf: INFO %s is not defined in the header", key)
+				}
+				bcfEncodeInts(s, []int32{k})
+				if err := e.encodeInfo(s, def.Type, val, hasVal); err != nil {
+					return fmt.Errorf("vcfgo: bcf: INFO %s at %s:%d: %w", key, v.Chromosome, v.Pos, err)
+				}
+				nInfo++
+			}
+		}
+	}
+
+	nSample := len(e.h.SampleNames)
+	nFmt := 0
+	if samples := e.samples(v); len(v.Format) > 0 && len(samples) > 0 {
+		if len(samples) != nSample {
+			return fmt.Errorf("vcfgo: bcf: %s:%d has %d samples but the header has %d", v.Chromosome, v.Pos, len(samples), nSample)
+		}
+		for j, key := range v.Format {
+			if err := e.encodeFormat(j, key, samples); err != nil {
+				return fmt.Errorf("vcfgo: bcf: FORMAT %s at %s:%d: %w", key, v.Chromosome, v.Pos, err)
+			}
+		}
+		nFmt = len(v.Format)
+	}
+
+	var head [32]byte
+	binary.LittleEndian.PutUint32(head[0:], uint32(24+s.Len()))
+	binary.LittleEndian.PutUint32(head[4:], uint32(e.indiv.Len()))
+	binary.LittleEndian.PutUint32(head[8:], uint32(tid))
+	binary.LittleEndian.PutUint32(head[12:], uint32(v.Pos-1))
+	binary.LittleEndian.PutUint32(head[16:], rlen)
+	binary.LittleEndian.PutUint32(head[20:], math.Float32bits(v.Quality))
+	binary.LittleEndian.PutUint16(head[24:], uint16(nInfo))
+	binary.LittleEndian.PutUint16(head[26:], uint16(nAllele))
+	binary.LittleEndian.PutUint32(head[28:], uint32(nSample)|uint32(nFmt)<<24)
+	if _, err := w.Write(head[:]); err != nil {
+		return err
+	}
+	if _, err := w.Write(s.Bytes()); err != nil {
+		return err
+	}
+	_, err := w.Write(e.indiv.Bytes())
+	return err
+}
+
+func (e *bcfEncoder) encodeInfo(b *bytes.Buffer, typ, val string, hasVal bool) error {
+	var err error
+	switch {
+	case !hasVal || typ == "Flag":
+		bcfEncodeSize(b, bcfTypeNull, 0)
+	case typ == "Integer":
+		if e.ints, err = appendInts(e.ints[:0], val); err == nil {
+			bcfEncodeInts(b, e.ints)
+		}
+	case typ == "Float":
+		if e.floats, err = appendFloats(e.floats[:0], val); err == nil {
+			bcfEncodeSize(b, bcfTypeFloat, len(e.floats))
+			bcfPutFloats(b, e.floats)
+		}
+	default:
+		bcfEncodeString(b, val)
+	}
+	return err
+}
+
+// encodeFormat writes the j'th FORMAT field of all samples. Each sample gets
+// as many values as the longest, padded with end-of-vector values.
+func (e *bcfEncoder) encodeFormat(j int, key string, samples [][]string) error {
+	k, err := e.key("FORMAT", key)
+	if err != nil {
+		return err
+	}
+	typ := "String"
+	if def := e.h.SampleFormats[key]; def != nil {
+		typ = def.Type
+	} else if key != "GT" {
+		return fmt.Errorf("not defined in the header")
+	}
+	value := func(i int) string {
+		if j < len(samples[i]) {
+			return samples[i][j]
+		}
+		return "."
+	}
+	b := &e.indiv
+	bcfEncodeInts(b, []int32{k})
+
+	// the values of each sample are parsed into one slice and padded afterwards.
+	var ends []int
+	width := 0
+	switch {
+	case key == "GT" || typ == "Integer":
+		e.ints = e.ints[:0]
+		for i := range samples {
+			if key == "GT" {
+				e.ints = appendGenotype(e.ints, value(i))
+			} else if e.ints, err = appendInts(e.ints, value(i)); err != nil {
+				return err
+			}
+			ends = append(ends, len(e.ints))
+		}
+		width = maxWidth(ends)
+		padded := make([]int32, 0, width*len(samples))
+		last := 0
+		for _, end := range ends {
+			padded = append(padded, e.ints[last:end]...)
+			for n := end - last; n < width; n++ {
+				padded = append(padded, bcfInt32Missing+1)
+			}
+			last = end
+		}
+		t := bcfIntType(padded)
+		bcfEncodeSize(b, t, width)
+		bcfPutInts(b, t, padded)
+	case typ == "Float":
+		e.floats = e.floats[:0]
+		for i := range samples {
+			if e.floats, err = appendFloats(e.floats, value(i)); err != nil {
+				return err
+			}
+			ends = append(ends, len(e.floats))
+		}
+		width = maxWidth(ends)
+		bcfEncodeSize(b, bcfTypeFloat, width)
+		last := 0
+		for _, end := range ends {
+			bcfPutFloats(b, e.floats[last:end])
+			for n := end - last; n < width; n++ {
+				bcfPutFloats(b, []uint32{bcfFloatEOV})
+			}
+			last = end
+		}
+	default:
+		for i := range samples {
+			if l := len(value(i)); l > width {
+				width = l
+			}
+		}
+		bcfEncodeSize(b, bcfTypeChar, width)
+		for i := range samples {
+			s := value(i)
+			b.WriteString(s)
+			for n := len(s); n < width; n++ {
+				b.WriteByte(0)
+			}
+		}
+	}
+	return nil
+}
+
+// maxWidth returns the largest difference between consecutive ends.
+func maxWidth(ends []int) int {
+	width, last := 0, 0
+	for _, end := range ends {
+		if end-last > width {
+			width = end - last
+		}
+		last = end
+	}
+	return width
+}
@@ -0,0 +1,409 @@
+package vcfgo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"path/filepath"
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+type BCFSuite struct{}
+
+var _ = Suite(&BCFSuite{})
+
+const bcfTestHeader = `##fileformat=VCFv4.2
+##FILTER=<ID=PASS,Description="All filters passed",IDX=0>
+##FILTER=<ID=q10,Description="Quality below 10",IDX=5>
+##INFO=<ID=DP,Number=1,Type=Integer,Description="Depth",IDX=1>
+##INFO=<ID=AF,Number=A,Type=Float,Description="Allele frequency",IDX=2>
+##INFO=<ID=DB,Number=0,Type=Flag,Description="dbSNP membership",IDX=3>
+##FORMAT=<ID=GT,Number=1,Type=String,Description="Genotype",IDX=4>
+##FORMAT=<ID=AD,Number=R,Type=Integer,Description="Allelic depths",IDX=6>
+##contig=<ID=chr1,length=1000,IDX=0>
+##contig=<ID=chr2,length=1000,IDX=1>
+#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO	FORMAT	S1	S2
+`
+
+// the records encoded by bcfTestRecords.
+var bcfTestLines = []string{
+	"chr1\t10\trs1\tA\tC,G\t50\tPASS\tDP=14;AF=0.5,0.25;DB\tGT:AD\t0/1:10,300,.\t1|2:5,6",
+	"chr2\t20\t.\tT\t.\t.\tq10\t.\tGT:AD\t.:.\t0/0:3",
+}
+
+func bcfDescriptor(b *bytes.Buffer, typ byte, n int) {
+	if n < 15 {
+		b.WriteByte(byte(n)<<4 | typ)
+		return
+	}
+	b.WriteByte(0xf0 | typ)
+	b.WriteByte(0x10 | bcfTypeInt8)
+	b.WriteByte(byte(n))
+}
+
+// bcfPut writes values of typ without a descriptor.
+func bcfPut(b *bytes.Buffer, typ byte, vals ...int32) {
+	for _, v := range vals {
+		switch typ {
+		case bcfTypeInt8:
+			b.WriteByte(byte(int8(v)))
+		case bcfTypeInt16:
+			binary.Write(b, binary.LittleEndian, int16(v))
+		default:
+			binary.Write(b, binary.LittleEndian, v)
+		}
+	}
+}
+
+func bcfPutTyped(b *bytes.Buffer, typ byte, vals ...int32) {
+	bcfDescriptor(b, typ, len(vals))
+	bcfPut(b, typ, vals...)
+}
+
+func bcfPutString(b *bytes.Buffer, s string) {
+	bcfDescriptor(b, bcfTypeChar, len(s))
+	b.WriteString(s)
+}
+
+func bcfRecord(chrom, pos int32, qual uint32, nInfo, nAllele, nSample, nFmt int, shared, indiv []byte) []byte {
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, uint32(24+len(shared)))
+	binary.Write(&b, binary.LittleEndian, uint32(len(indiv)))
+	binary.Write(&b, binary.LittleEndian, chrom)
+	binary.Write(&b, binary.LittleEndian, pos)
+	binary.Write(&b, binary.LittleEndian, int32(1))
+	binary.Write(&b, binary.LittleEndian, qual)
+	binary.Write(&b, binary.LittleEndian, uint16(nInfo))
+	binary.Write(&b, binary.LittleEndian, uint16(nAllele))
+	binary.Write(&b, binary.LittleEndian, uint32(nSample)|uint32(nFmt)<<24)
+	b.Write(shared)
+	b.Write(indiv)
+	return b.Bytes()
+}
+
+func bcfTestRecords() [][]byte {
+	var s, i bytes.Buffer
+	bcfPutString(&s, "rs1")
+	for _, a := range []string{"A", "C", "G"} {
+		bcfPutString(&s, a)
+	}
+	bcfPutTyped(&s, bcfTypeInt8, 0)
+	bcfPutTyped(&s, bcfTypeInt8, 1)
+	bcfPutTyped(&s, bcfTypeInt8, 14)
+	bcfPutTyped(&s, bcfTypeInt8, 2)
+	bcfDescriptor(&s, bcfTypeFloat, 2)
+	binary.Write(&s, binary.LittleEndian, []float32{0.5, 0.25})
+	bcfPutTyped(&s, bcfTypeInt8, 3)
+	s.WriteByte(bcfTypeNull)
+
+	bcfPutTyped(&i, bcfTypeInt8, 4)
+	bcfDescriptor(&i, bcfTypeInt8, 2)
+	bcfPut(&i, bcfTypeInt8, 2, 4, 4, 7)
+	bcfPutTyped(&i, bcfTypeInt8, 6)
+	bcfDescriptor(&i, bcfTypeInt16, 3)
+	bcfPut(&i, bcfTypeInt16, 10, 300, bcfInt16Missing, 5, 6, bcfInt16Missing+1)
+	first := bcfRecord(0, 9, math.Float32bits(50), 3, 3, 2, 2, s.Bytes(), i.Bytes())
+
+	s.Reset()
+	i.Reset()
+	bcfPutString(&s, "")
+	bcfPutString(&s, "T")
+	bcfPutTyped(&s, bcfTypeInt8, 5)
+
+	bcfPutTyped(&i, bcfTypeInt8, 4)
+	bcfDescriptor(&i, bcfTypeInt8, 2)
+	bcfPut(&i, bcfTypeInt8, 0, bcfInt8Missing+1, 2, 2)
+	bcfPutTyped(&i, bcfTypeInt8, 6)
+	bcfDescriptor(&i, bcfTypeInt8, 1)
+	bcfPut(&i, bcfTypeInt8, bcfInt8Missing, 3)
+	second := bcfRecord(1, 19, missingBits, 0, 1, 2, 2, s.Bytes(), i.Bytes())
+	return [][]byte{first, second}
+}
+
+func bcfTestFile(records [][]byte) []byte {
+	var b bytes.Buffer
+	b.Write(bcfMagic)
+	b.WriteByte(2)
+	binary.Write(&b, binary.LittleEndian, uint32(len(bcfTestHeader)+1))
+	b.WriteString(bcfTestHeader)
+	b.WriteByte(0)
+	for _, r := range records {
+		b.Write(r)
+	}
+	return b.Bytes()
+}
+
+// expectedBCFVariants parses bcfTestLines as text.
+func expectedBCFVariants(c *C) []string {
+	text := stripIDX(bcfTestHeader) + strings.Join(bcfTestLines, "\n") + "\n"
+	rdr, err := NewReader(strings.NewReader(text), false)
+	c.Assert(err, IsNil)
+	return readAllVariants(c, rdr)
+}
+
+func (s *BCFSuite) TestRead(c *C) {
+	for _, data := range [][]byte{bcfTestFile(bcfTestRecords()), makeBGZF(c, bcfTestFile(bcfTestRecords()), 50)} {
+		rdr, err := NewReader(bytes.NewReader(data), false)
+		c.Assert(err, IsNil)
+		c.Assert(rdr.Header.SampleNames, DeepEquals, []string{"S1", "S2"})
+		c.Assert(rdr.Header.Contigs[1]["ID"], Equals, "chr2")
+		c.Assert(rdr.Header.Infos["DP"].Type, Equals, "Integer")
+
+		v := rdr.Read()
+		c.Assert(v, Not(IsNil))
+		c.Assert(v.Chromosome, Equals, "chr1")
+		c.Assert(v.Pos, Equals, uint64(10))
+		c.Assert(v.Alt(), DeepEquals, []string{"C", "G"})
+		dp, err := v.Info().Get("DP")
+		c.Assert(err, IsNil)
+		c.Assert(dp, Equals, 14)
+		c.Assert(v.Samples[1].GT, DeepEquals, []int{1, 2})
+		c.Assert(v.Samples[1].Phased, Equals, true)
+
+		v = rdr.Read()
+		c.Assert(v, Not(IsNil))
+		c.Assert(v.Filter, Equals, "q10")
+		c.Assert(rdr.Read(), IsNil)
+		c.Assert(rdr.Error(), IsNil)
+	}
+
+	rdr, err := NewReader(bytes.NewReader(bcfTestFile(bcfTestRecords())), false)
+	c.Assert(err, IsNil)
+	c.Assert(readAllVariants(c, rdr), DeepEquals, expectedBCFVariants(c))
+
+	d := newBCFDecoder(bcfTestHeader)
+	for i, rec := range bcfTestRecords() {
+		line, err := d.next(bytes.NewReader(rec))
+		c.Assert(err, IsNil)
+		c.Assert(string(line), Equals, bcfTestLines[i])
+	}
+}
+
+func (s *BCFSuite) TestFlagAsInt(c *C) {
+	// DB encoded as a one element int8 1 as the BCF spec recommends.
+	var b bytes.Buffer
+	bcfPutString(&b, "")
+	bcfPutString(&b, "A")
+	bcfPutString(&b, "C")
+	bcfPutTyped(&b, bcfTypeInt8)
+	bcfPutTyped(&b, bcfTypeInt8, 3)
+	bcfPutTyped(&b, bcfTypeInt8, 1)
+	bcfPutTyped(&b, bcfTypeInt8, 1)
+	bcfPutTyped(&b, bcfTypeInt8, 7)
+	rec := bcfRecord(0, 0, missingBits, 2, 2, 0, 0, b.Bytes(), nil)
+
+	d := newBCFDecoder(bcfTestHeader)
+	line, err := d.next(bytes.NewReader(rec))
+	c.Assert(err, IsNil)
+	c.Assert(string(line), Equals, "chr1\t1\t.\tA\tC\t.\t.\tDB;DP=7")
+
+	rdr, err := NewReader(bytes.NewReader(bcfTestFile([][]byte{rec})), false)
+	c.Assert(err, IsNil)
+	v := rdr.Read()
+	c.Assert(v, Not(IsNil))
+	db, err := v.Info().Get("DB")
+	c.Assert(err, IsNil)
+	c.Assert(db, Equals, true)
+}
+
+func (s *BCFSuite) TestDecodeErrors(c *C) {
+	recs := bcfTestRecords()
+	data := bcfTestFile(recs)
+	rdr, err := NewReader(bytes.NewReader(data[:len(data)-5]), false)
+	c.Assert(err, IsNil)
+	c.Assert(rdr.Read(), Not(IsNil))
+	c.Assert(rdr.Read(), IsNil)
+	c.Assert(rdr.Error(), ErrorMatches, "(?s).*bcf: truncated record.*")
+
+	// a contig that is not in the header.
+	bad := append([]byte(nil), recs[1]...)
+	binary.LittleEndian.PutUint32(bad[8:], 7)
+	rdr, err = NewReader(bytes.NewReader(bcfTestFile([][]byte{bad})), false)
+	c.Assert(err, IsNil)
+	c.Assert(rdr.Read(), IsNil)
+	c.Assert(rdr.Error(), ErrorMatches, "(?s).*bcf: contig 7 is not in the header.*")
+}
+
+func (s *BCFSuite) TestDictionaries(c *C) {
+	strs, contigs := bcfDictionaries(bcfTestHeader)
+	c.Assert(strs, DeepEquals, []string{"PASS", "DP", "AF", "DB", "GT", "q10", "AD"})
+	c.Assert(contigs, DeepEquals, []string{"chr1", "chr2"})
+
+	// without IDX the order of appearance is used and ids are shared between INFO and FORMAT.
+	strs, contigs = bcfDictionaries(`##INFO=<ID=DP,Number=1,Type=Integer,Description="d">
+##FILTER=<ID=q10,Description="q">
+##FORMAT=<ID=DP,Number=1,Type=Integer,Description="d">
+##FORMAT=<ID=GT,Number=1,Type=String,Description="g">
+##contig=<ID=2>
+##contig=<ID=1>
+`)
+	c.Assert(strs, DeepEquals, []string{"PASS", "DP", "q10", "GT"})
+	c.Assert(contigs, DeepEquals, []string{"2", "1"})
+}
+
+func (s *BCFSuite) TestQuery(c *C) {
+	var out bytes.Buffer
+	bw := NewBGZFWriter(&out)
+	recs := bcfTestRecords()
+	bw.Write(bcfTestFile(nil))
+	c.Assert(bw.Flush(), IsNil)
+	b, err := NewIndexBuilder(CSI, 14, 5)
+	c.Assert(err, IsNil)
+	for i, chrom := range []string{"chr1", "chr2"} {
+		start := bw.VirtualOffset()
+		bw.Write(recs[i])
+		c.Assert(b.Add(chrom, int64(9+10*i), int64(10+10*i), start, bw.VirtualOffset()), IsNil)
+	}
+	c.Assert(bw.Close(), IsNil)
+	idx := b.Index()
+	// like htslib, index references by their position in the contig dictionary.
+	idx.Names = nil
+
+	rdr, err := NewReader(bytes.NewReader(out.Bytes()), false)
+	c.Assert(err, IsNil)
+	rdr.SetIndex(idx)
+	expected := expectedBCFVariants(c)
+	for i, chrom := range []string{"chr1", "chr2"} {
+		it, err := rdr.Query(chrom, 0, 1000)
+		c.Assert(err, IsNil)
+		v := it.Read()
+		c.Assert(v, Not(IsNil))
+		c.Assert(v.String(), Equals, expected[i])
+		c.Assert(it.Read(), IsNil)
+	}
+	it, err := rdr.Query("chr2", 0, 19)
+	c.Assert(err, IsNil)
+	c.Assert(it.Read(), IsNil)
+	c.Assert(rdr.Error(), IsNil)
+}
+
+// bcfTextReader reads bcfTestLines and extra as text VCF.
+func bcfTextReader(c *C, extra ...string) *Reader {
+	lines := append(append([]string{}, bcfTestLines...), extra...)
+	rdr, err := NewReader(strings.NewReader(stripIDX(bcfTestHeader)+strings.Join(lines, "\n")+"\n"), false)
+	c.Assert(err, IsNil)
+	return rdr
+}
+
+func (s *BCFSuite) TestWrite(c *C) {
+	// a haploid genotype and values that need 16 and 32 bit integers.
+	extra := "chr2\t30\trs3\tG\tA\t.\tPASS\tDP=40000\tGT:AD\t1:3\t0|1:.,70000"
+	rdr := bcfTextReader(c, extra)
+	var out bytes.Buffer
+	w, err := NewWriterWithOptions(&out, rdr.Header, WriterOptions{BCF: true})
+	c.Assert(err, IsNil)
+	var expected []string
+	for v := rdr.Read(); v != nil; v = rdr.Read() {
+		c.Assert(w.WriteVariant(v), IsNil)
+		expected = append(expected, v.String())
+	}
+	c.Assert(w.Close(), IsNil)
+	c.Assert(len(expected), Equals, 3)
+	c.Assert(bytes.HasSuffix(out.Bytes(), bgzfEOF), Equals, true)
+
+	bcf, err := NewReader(bytes.NewReader(out.Bytes()), false)
+	c.Assert(err, IsNil)
+	c.Assert(bcf.Compression(), Equals, CompressionBGZF)
+	c.Assert(bcf.Header.SampleNames, DeepEquals, []string{"S1", "S2"})
+	c.Assert(readAllVariants(c, bcf), DeepEquals, expected)
+	c.Assert(bcf.Error(), IsNil)
+}
+
+func (s *BCFSuite) TestEncodeInts(c *C) {
+	for _, t := range []struct {
+		vals []int32
+		typ  byte
+	}{
+		{[]int32{1, -120, 127, bcfInt32Missing}, bcfTypeInt8},
+		{[]int32{1, -121}, bcfTypeInt16},
+		{[]int32{128, bcfInt32Missing + 1}, bcfTypeInt16},
+		{[]int32{32768}, bcfTypeInt32},
+		{[]int32{-32761}, bcfTypeInt32},
+	} {
+		c.Assert(bcfIntType(t.vals), Equals, t.typ, Commentf("%v", t.vals))
+	}
+
+	var b bytes.Buffer
+	vals := make([]int32, 20)
+	vals[3], vals[4] = bcfInt32Missing, bcfInt32Missing+1
+	bcfEncodeInts(&b, vals)
+	typ, n, rest, err := bcfTyped(b.Bytes())
+	c.Assert(err, IsNil)
+	c.Assert(typ, Equals, byte(bcfTypeInt8))
+	c.Assert(n, Equals, 20)
+	c.Assert(string(appendBCFValues(nil, typ, n, rest)), Equals, "0,0,0,.")
+}
+
+func (s *BCFSuite) TestWriteErrors(c *C) {
+	rdr := bcfTextReader(c, "chr3\t1\t.\tA\tC\t.\tPASS\t.", "chr2\t5\t.\tA\tC\t.\tPASS\tXX=1", "chr2\t5\t.\tA\tC\t.\tlow\t.")
+	w, err := NewWriterWithOptions(&bytes.Buffer{}, rdr.Header, WriterOptions{BCF: true})
+	c.Assert(err, IsNil)
+	var errs []string
+	for v := rdr.Read(); v != nil; v = rdr.Read() {
+		if err := w.WriteVariant(v); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	c.Assert(errs, DeepEquals, []string{
+		"vcfgo: bcf: contig chr3 is not in the header",
+		"vcfgo: bcf: INFO XX is not defined in the header",
+		"vcfgo: bcf: FILTER low is not defined in the header",
+	})
+
+	// BCF holds POS in 32 bits.
+	rdr = bcfTextReader(c, "chr2\t2147483649\t.\tA\tC\t.\tPASS\t.")
+	for v := rdr.Read(); v != nil; v = rdr.Read() {
+		err = w.WriteVariant(v)
+	}
+	c.Assert(err, ErrorMatches, "vcfgo: bcf: POS 2147483649 at chr2 does not fit in BCF: .*")
+	c.Assert(errors.Is(err, ErrInvalidValue), Equals, true)
+
+	_, err = NewWriterWithOptions(&bytes.Buffer{}, rdr.Header, WriterOptions{BCF: true, Index: TBI})
+	c.Assert(err, ErrorMatches, "vcfgo: BCF can only be indexed with CSI")
+}
+
+func (s *BCFSuite) TestCreateIndexed(c *C) {
+	rdr := bcfTextReader(c, "chr2\t30\t.\tG\tA\t.\tPASS\t.\tGT\t0/1\t1/1")
+	// skip chr1 so that the index has an empty first reference.
+	c.Assert(rdr.Read(), Not(IsNil))
+	path := filepath.Join(c.MkDir(), "out.bcf")
+	w, err := CreateWithOptions(path, rdr.Header, WriterOptions{Index: CSI})
+	c.Assert(err, IsNil)
+	var expected []string
+	for v := rdr.Read(); v != nil; v = rdr.Read() {
+		c.Assert(w.WriteVariant(v), IsNil)
+		expected = append(expected, v.String())
+	}
+	c.Assert(w.Close(), IsNil)
+
+	idx, err := OpenIndex(path + ".csi")
+	c.Assert(err, IsNil)
+	c.Assert(idx.Names, IsNil)
+	c.Assert(len(idx.refs), Equals, 2)
+	c.Assert(len(idx.refs[0].bins), Equals, 0)
+
+	bcf, err := Open(path, false)
+	c.Assert(err, IsNil)
+	defer bcf.Close()
+	it, err := bcf.Query("chr2", 25, 100)
+	c.Assert(err, IsNil)
+	v := it.Read()
+	c.Assert(v, Not(IsNil))
+	c.Assert(v.String(), Equals, expected[1])
+	c.Assert(it.Read(), IsNil)
+	it, err = bcf.Query("chr1", 0, 100)
+	c.Assert(err, IsNil)
+	c.Assert(it.Read(), IsNil)
+	c.Assert(bcf.Error(), IsNil)
+
+	// records must follow the order of the contigs in the header.
+	rdr = bcfTextReader(c)
+	w, err = NewWriterWithOptions(&bytes.Buffer{}, rdr.Header, WriterOptions{BCF: true, Index: CSI})
+	c.Assert(err, IsNil)
+	first, second := rdr.Read(), rdr.Read()
+	c.Assert(w.WriteVariant(second), IsNil)
+	c.Assert(w.WriteVariant(first), ErrorMatches, "vcfgo: index: records are not sorted: chr1 follows chr2 but comes before it in the header")
+}
@@ -5,14 +5,14 @@ import (
 	"testing"
 )
 
-func benchmarkReader(lazy bool, b *testing.B) {
+func benchmarkReader(opts ReaderOptions, b *testing.B) {
 
 	for n := 0; n < b.N; n++ {
 		f, err := os.Open("examples/test.query.vcf")
 		if err != nil {
 			panic(err)
 		}
-		rdr, err := NewReader(f, lazy)
+		rdr, err := NewReaderWithOptions(f, opts)
 		if err != nil {
 			panic(err)
 		}
@@ -25,8 +25,25 @@ func benchmarkReader(lazy bool, b *testing.B) {
 			}
 			j++
 		}
+		rdr.Close()
 	}
 }
 
-func BenchmarkLazy(b *testing.B)  { benchmarkReader(true, b) }
-func BenchmarkEager(b *testing.B) { benchmarkReader(false, b) }
+func BenchmarkLazy(b *testing.B)    { benchmarkReader(ReaderOptions{LazySamples: true}, b) }
+func BenchmarkEager(b *testing.B)   { benchmarkReader(ReaderOptions{}, b) }
+func BenchmarkWorkers(b *testing.B) { benchmarkReader(ReaderOptions{Workers: 4}, b) }
+
+func BenchmarkInfoGet(b *testing.B) {
+	i := NewInfoByte([]byte("DP=14;AC=1,2,3;AF=0.5,0.25,0.125;DB;GENE=BRCA2"), typedInfoHeader())
+	for n := 0; n < b.N; n++ {
+		i.Get("AF")
+	}
+}
+
+func BenchmarkInfoGetFloat32s(b *testing.B) {
+	i := NewInfoByte([]byte("DP=14;AC=1,2,3;AF=0.5,0.25,0.125;DB;GENE=BRCA2"), typedInfoHeader())
+	var dst []float32
+	for n := 0; n < b.N; n++ {
+		dst, _ = i.GetFloat32s("AF", dst)
+	}
+}
@@ -0,0 +1,374 @@
+package vcfgo
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// bgzfHeaderSize is the size of a BGZF block header including the BC extra field.
+const bgzfHeaderSize = 18
+
+// Compression describes the encoding of an input stream.
+type Compression int
+
+const (
+	// CompressionAuto sniffs the magic bytes of the input.
+	CompressionAuto Compression = iota
+	// CompressionNone is plain text.
+	CompressionNone
+	// CompressionGzip is a (possibly multi-member) gzip stream.
+	CompressionGzip
+	// CompressionBGZF is the blocked gzip format used by tabix and htslib.
+	CompressionBGZF
+)
+
+// String returns a string representation.
+func (c Compression) String() string {
+	switch c {
+	case CompressionAuto:
+		return "auto"
+	case CompressionNone:
+		return "none"
+	case CompressionGzip:
+		return "gzip"
+	case CompressionBGZF:
+		return "bgzf"
+	}
+	return fmt.Sprintf("Compression(%d)", int(c))
+}
+
+// detectCompression peeks at the start of buf without consuming it.
+func detectCompression(buf *bufio.Reader) Compression {
+	magic, _ := buf.Peek(bgzfHeaderSize)
+	if len(magic) < 2 || magic[0] != 0x1f || magic[1] != 0x8b {
+		return CompressionNone
+	}
+	if isBGZFHeader(magic) {
+		return CompressionBGZF
+	}
+	return CompressionGzip
+}
+
+// isBGZFHeader checks for the gzip magic with FEXTRA set and a leading BC subfield.
+func isBGZFHeader(h []byte) bool {
+	return len(h) >= bgzfHeaderSize && h[0] == 0x1f && h[1] == 0x8b && h[2] == 8 && h[3]&4 != 0 &&
+		binary.LittleEndian.Uint16(h[10:]) >= 6 && h[12] == 'B' && h[13] == 'C' &&
+		binary.LittleEndian.Uint16(h[14:]) == 2
+}
+
+// bgzfReader decompresses a BGZF stream one block at a time so that the
+// position of the decompressed data within the compressed file is known.
+type bgzfReader struct {
+	src *bufio.Reader
+	// r is the underlying reader that src wraps; it is used to seek.
+	r io.Reader
+
+	// coff is the compressed offset of the current block and next that of the following block.
+	coff int64
+	next int64
+
+	block []byte
+	off   int
+
+	cdata []byte
+	fr    io.ReadCloser
+	err   error
+}
+
+func newBGZFReader(src *bufio.Reader, r io.Reader) *bgzfReader {
+	return &bgzfReader{src: src, r: r}
+}
+
+// readBlock decompresses the next block into b.block.
+func (b *bgzfReader) readBlock() error {
+	h, err := b.src.Peek(bgzfHeaderSize)
+	if err != nil {
+		if err == io.EOF && len(h) == 0 {
+			return io.EOF
+		}
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	if !isBGZFHeader(h) {
+		return fmt.Errorf("bgzf: invalid block header at offset %d", b.next)
+	}
+	xlen := int(binary.LittleEndian.Uint16(h[10:]))
+	bsize := int(binary.LittleEndian.Uint16(h[16:])) + 1
+	if bsize < 12+xlen+8 {
+		return fmt.Errorf("bgzf: invalid block size %d at offset %d", bsize, b.next)
+	}
+	if cap(b.cdata) < bsize {
+		b.cdata = make([]byte, bsize)
+	}
+	b.cdata = b.cdata[:bsize]
+	if _, err := io.ReadFull(b.src, b.cdata); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	b.coff = b.next
+	b.next += int64(bsize)
+
+	tail := b.cdata[bsize-8:]
+	crc := binary.LittleEndian.Uint32(tail)
+	isize := int(binary.LittleEndian.Uint32(tail[4:]))
+	// a block never holds more than 64KB, whatever a corrupt trailer says.
+	if isize > 1<<16 {
+		return fmt.Errorf("bgzf: invalid uncompressed size %d in block at offset %d", isize, b.coff)
+	}
+	if cap(b.block) < isize {
+		b.block = make([]byte, isize)
+	}
+	b.block = b.block[:isize]
+	b.off = 0
+
+	cr := bytes.NewReader(b.cdata[12+xlen : bsize-8])
+	if b.fr == nil {
+		b.fr = flate.NewReader(cr)
+	} else {
+		b.fr.(flate.Resetter).Reset(cr, nil)
+	}
+	if _, err := io.ReadFull(b.fr, b.block); err != nil {
+		return fmt.Errorf("bgzf: error decompressing block at offset %d: %w", b.coff, err)
+	}
+	if crc32.ChecksumIEEE(b.block) != crc {
+		return fmt.Errorf("bgzf: checksum mismatch in block at offset %d", b.coff)
+	}
+	return nil
+}
+
+// Read satisfies io.Reader. Empty blocks, including the EOF marker, are skipped
+// so that concatenated BGZF files are read as a single stream.
+func (b *bgzfReader) Read(p []byte) (int, error) {
+	for b.off >= len(b.block) {
+		if b.err != nil {
+			return 0, b.err
+		}
+		if b.err = b.readBlock(); b.err != nil {
+			return 0, b.err
+		}
+	}
+	n := copy(p, b.block[b.off:])
+	b.off += n
+	return n, nil
+}
+
+// Close releases the decompressor. It does not close the underlying reader.
+func (b *bgzfReader) Close() error {
+	if b.fr != nil {
+		return b.fr.Close()
+	}
+	return nil
+}
+
+// bgzfBlockSize is the maximum number of uncompressed bytes in a block. As in
+// htslib it is a little under 64KB so that a block that does not compress
+// still fits within the 64KB limit on compressed block size.
+const bgzfBlockSize = 0xff00
+
+// bgzfEOF is the empty block that marks the end of a BGZF file.
+var bgzfEOF = []byte{0x1f, 0x8b, 8, 4, 0, 0, 0, 0, 0, 0xff, 6, 0, 'B', 'C', 2, 0, 0x1b, 0, 3, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+
+// VirtualOffset is a BGZF virtual file offset. The upper 48 bits hold the offset
+// of a block in the compressed file and the lower 16 bits the offset within
+// the uncompressed data of that block.
+type VirtualOffset uint64
+
+// NewVirtualOffset combines a compressed block offset and an offset within the block.
+func NewVirtualOffset(compressed int64, uncompressed int) VirtualOffset {
+	return VirtualOffset(uint64(compressed)<<16 | uint64(uncompressed&0xffff))
+}
+
+// Compressed returns the offset of the block in the compressed file.
+func (o VirtualOffset) Compressed() int64 {
+	return int64(o >> 16)
+}
+
+// Uncompressed returns the offset within the uncompressed block.
+func (o VirtualOffset) Uncompressed() int {
+	return int(o & 0xffff)
+}
+
+// String returns a string representation.
+func (o VirtualOffset) String() string {
+	return fmt.Sprintf("%d:%d", o.Compressed(), o.Uncompressed())
+}
+
+// BGZFWriter compresses data into BGZF blocks so that the output can be indexed.
+// Close must be called to flush the last block and write the EOF marker.
+type BGZFWriter struct {
+	w     io.Writer
+	buf   []byte
+	coff  int64
+	cdata bytes.Buffer
+	fw    *flate.Writer
+	err   error
+}
+
+// NewBGZFWriter returns a BGZFWriter that writes to w.
+func NewBGZFWriter(w io.Writer) *BGZFWriter {
+	return &BGZFWriter{w: w, buf: make([]byte, 0, bgzfBlockSize)}
+}
+
+// Write satisfies io.Writer. A block is emitted each time bgzfBlockSize bytes have been buffered.
+func (b *BGZFWriter) Write(p []byte) (int, error) {
+	n := 0
+	for len(p) > 0 {
+		if b.err != nil {
+			return n, b.err
+		}
+		m := copy(b.buf[len(b.buf):bgzfBlockSize], p)
+		b.buf = b.buf[:len(b.buf)+m]
+		p = p[m:]
+		n += m
+		if len(b.buf) == bgzfBlockSize {
+			b.err = b.writeBlock(b.buf)
+			b.buf = b.buf[:0]
+		}
+	}
+	return n, b.err
+}
+
+// Flush ends the current block so that the next write starts a new one.
+func (b *BGZFWriter) Flush() error {
+	if b.err != nil || len(b.buf) == 0 {
+		return b.err
+	}
+	b.err = b.writeBlock(b.buf)
+	b.buf = b.buf[:0]
+	return b.err
+}
+
+// VirtualOffset returns the virtual offset at which the next byte written will be stored.
+func (b *BGZFWriter) VirtualOffset() VirtualOffset {
+	return NewVirtualOffset(b.coff, len(b.buf))
+}
+
+// Close flushes any buffered data and writes the EOF marker block.
+// It does not close the underlying writer.
+func (b *BGZFWriter) Close() error {
+	if b.err == errBGZFClosed {
+		return nil
+	}
+	if err := b.Flush(); err != nil {
+		return err
+	}
+	_, b.err = b.w.Write(bgzfEOF)
+	if b.err == nil {
+		b.coff += int64(len(bgzfEOF))
+		b.err = errBGZFClosed
+		return nil
+	}
+	return b.err
+}
+
+var errBGZFClosed = errors.New("bgzf: write to closed writer")
+
+func (b *BGZFWriter) compress(p []byte, level int) error {
+	b.cdata.Reset()
+	if b.fw == nil || level != flate.DefaultCompression {
+		var err error
+		if b.fw, err = flate.NewWriter(&b.cdata, level); err != nil {
+			return err
+		}
+	} else {
+		b.fw.Reset(&b.cdata)
+	}
+	if _, err := b.fw.Write(p); err != nil {
+		return err
+	}
+	return b.fw.Close()
+}
+
+func (b *BGZFWriter) writeBlock(p []byte) error {
+	if err := b.compress(p, flate.DefaultCompression); err != nil {
+		return err
+	}
+	if bgzfHeaderSize+b.cdata.Len()+8 > 1<<16 {
+		if err := b.compress(p, flate.NoCompression); err != nil {
+			return err
+		}
+		// don't reuse a writer with the wrong level for the next block.
+		b.fw = nil
+	}
+	block := make([]byte, bgzfHeaderSize, bgzfHeaderSize+b.cdata.Len()+8)
+	copy(block, bgzfEOF[:bgzfHeaderSize])
+	binary.LittleEndian.PutUint16(block[16:], uint16(cap(block)-1))
+	block = append(block, b.cdata.Bytes()...)
+	block = binary.LittleEndian.AppendUint32(block, crc32.ChecksumIEEE(p))
+	block = binary.LittleEndian.AppendUint32(block, uint32(len(p)))
+	if _, err := b.w.Write(block); err != nil {
+		return err
+	}
+	b.coff += int64(len(block))
+	return nil
+}
+
+// virtualOffset returns the virtual offset of the next byte that Read will return.
+func (b *bgzfReader) virtualOffset() VirtualOffset {
+	if b.off >= len(b.block) {
+		return NewVirtualOffset(b.next, 0)
+	}
+	return NewVirtualOffset(b.coff, b.off)
+}
+
+// seek positions the reader at the virtual offset o. The underlying reader must be an io.Seeker.
+func (b *bgzfReader) seek(o VirtualOffset) error {
+	s, ok := b.r.(io.Seeker)
+	if !ok {
+		return errNotSeekable
+	}
+	if _, err := s.Seek(o.Compressed(), io.SeekStart); err != nil {
+		return err
+	}
+	b.src.Reset(b.r)
+	b.next = o.Compressed()
+	b.block = b.block[:0]
+	b.off = 0
+	if b.err = b.readBlock(); b.err != nil {
+		return b.err
+	}
+	if o.Uncompressed() > len(b.block) {
+		return fmt.Errorf("bgzf: offset %s is beyond the end of its block", o)
+	}
+	b.off = o.Uncompressed()
+	return nil
+}
+
+// readLine returns the next line without its trailing newline. The returned
+// slice is only valid until the next call.
+func (b *bgzfReader) readLine(line []byte) ([]byte, error) {
+	line = line[:0]
+	for {
+		if b.off >= len(b.block) {
+			if b.err != nil {
+				return line, b.err
+			}
+			if b.err = b.readBlock(); b.err != nil {
+				if b.err == io.EOF && len(line) > 0 {
+					return line, nil
+				}
+				return line, b.err
+			}
+			continue
+		}
+		if i := bytes.IndexByte(b.block[b.off:], '\n'); i >= 0 {
+			line = append(line, b.block[b.off:b.off+i]...)
+			b.off += i + 1
+			return line, nil
+		}
+		line = append(line, b.block[b.off:]...)
+		b.off = len(b.block)
+	}
+}
+
+var errNotSeekable = errors.New("bgzf: underlying reader does not support seeking")
@@ -0,0 +1,222 @@
+package vcfgo
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+type BGZFSuite struct{}
+
+var _ = Suite(&BGZFSuite{})
+
+// makeBGZF compresses data into BGZF blocks of at most size bytes followed by the EOF block.
+func makeBGZF(c *C, data []byte, size int) []byte {
+	var out bytes.Buffer
+	block := func(p []byte) {
+		var cdata bytes.Buffer
+		fw, err := flate.NewWriter(&cdata, flate.DefaultCompression)
+		c.Assert(err, IsNil)
+		fw.Write(p)
+		c.Assert(fw.Close(), IsNil)
+		h := []byte{0x1f, 0x8b, 8, 4, 0, 0, 0, 0, 0, 0xff, 6, 0, 'B', 'C', 2, 0, 0, 0}
+		binary.LittleEndian.PutUint16(h[16:], uint16(len(h)+cdata.Len()+8-1))
+		out.Write(h)
+		out.Write(cdata.Bytes())
+		binary.Write(&out, binary.LittleEndian, crc32.ChecksumIEEE(p))
+		binary.Write(&out, binary.LittleEndian, uint32(len(p)))
+	}
+	for len(data) > 0 {
+		n := size
+		if n > len(data) {
+			n = len(data)
+		}
+		block(data[:n])
+		data = data[n:]
+	}
+	block(nil)
+	return out.Bytes()
+}
+
+func readFixture(c *C) string {
+	b, err := os.ReadFile("examples/test.auto_dom.no_parents.vcf")
+	c.Assert(err, IsNil)
+	return string(b)
+}
+
+func readAllVariants(c *C, rdr *Reader) []string {
+	var out []string
+	for v := rdr.Read(); v != nil; v = rdr.Read() {
+		out = append(out, v.String())
+	}
+	return out
+}
+
+func (s *BGZFSuite) TestDetect(c *C) {
+	vcfStr := readFixture(c)
+	plain, err := NewReader(strings.NewReader(vcfStr), false)
+	c.Assert(err, IsNil)
+	c.Assert(plain.Compression(), Equals, CompressionNone)
+	expected := readAllVariants(c, plain)
+	c.Assert(len(expected), Equals, 5)
+
+	// two gzip members concatenated.
+	var gz bytes.Buffer
+	half := strings.Index(vcfStr, "#CHROM")
+	for _, part := range []string{vcfStr[:half], vcfStr[half:]} {
+		w := gzip.NewWriter(&gz)
+		w.Write([]byte(part))
+		c.Assert(w.Close(), IsNil)
+	}
+	rdr, err := NewReader(&gz, false)
+	c.Assert(err, IsNil)
+	c.Assert(rdr.Compression(), Equals, CompressionGzip)
+	c.Assert(readAllVariants(c, rdr), DeepEquals, expected)
+	c.Assert(rdr.Close(), IsNil)
+
+	rdr, err = NewReader(bytes.NewReader(makeBGZF(c, []byte(vcfStr), 100)), false)
+	c.Assert(err, IsNil)
+	c.Assert(rdr.Compression(), Equals, CompressionBGZF)
+	c.Assert(readAllVariants(c, rdr), DeepEquals, expected)
+	c.Assert(rdr.Close(), IsNil)
+}
+
+func (s *BGZFSuite) TestForcedCompression(c *C) {
+	vcfStr := readFixture(c)
+	_, err := NewReaderWithOptions(strings.NewReader(vcfStr), ReaderOptions{Compression: CompressionGzip})
+	c.Assert(err, Not(IsNil))
+
+	rdr, err := NewReaderWithOptions(strings.NewReader(vcfStr), ReaderOptions{Compression: CompressionNone, LazySamples: true})
+	c.Assert(err, IsNil)
+	c.Assert(rdr.Read().Chromosome, Equals, "chr10")
+}
+
+func (s *BGZFSuite) TestCorruptBlock(c *C) {
+	data := makeBGZF(c, []byte(readFixture(c)), 1<<15)
+	// flip a bit in the stored CRC of the first block.
+	bsize := int(binary.LittleEndian.Uint16(data[16:])) + 1
+	data[bsize-8] ^= 1
+	br := newBGZFReader(bufio.NewReader(bytes.NewReader(data)), nil)
+	_, err := io.ReadAll(br)
+	c.Assert(err, ErrorMatches, "bgzf: checksum mismatch.*")
+}
+
+func (s *BGZFSuite) TestCorruptSize(c *C) {
+	data := makeBGZF(c, []byte(readFixture(c)), 1<<15)
+	// claim 4GB in the ISIZE of the first block.
+	bsize := int(binary.LittleEndian.Uint16(data[16:])) + 1
+	binary.LittleEndian.PutUint32(data[bsize-4:], 1<<32-1)
+	br := newBGZFReader(bufio.NewReader(bytes.NewReader(data)), nil)
+	_, err := io.ReadAll(br)
+	c.Assert(err, ErrorMatches, "bgzf: invalid uncompressed size 4294967295 in block at offset 0")
+
+	// a block cut short.
+	br = newBGZFReader(bufio.NewReader(bytes.NewReader(data[:bsize/2])), nil)
+	_, err = io.ReadAll(br)
+	c.Assert(err, Equals, io.ErrUnexpectedEOF)
+}
+
+func (s *BGZFSuite) TestOpen(c *C) {
+	path := filepath.Join(c.MkDir(), "t.vcf.gz")
+	c.Assert(os.WriteFile(path, makeBGZF(c, []byte(readFixture(c)), 256), 0644), IsNil)
+	rdr, err := Open(path, true)
+	c.Assert(err, IsNil)
+	c.Assert(len(rdr.Header.SampleNames), Equals, 9)
+	c.Assert(len(readAllVariants(c, rdr)), Equals, 5)
+	c.Assert(rdr.Close(), IsNil)
+	// the file was closed with the reader.
+	c.Assert(rdr.r.(*os.File).Close(), Not(IsNil))
+
+	_, err = Open(filepath.Join(c.MkDir(), "missing.vcf"), false)
+	c.Assert(err, Not(IsNil))
+}
+
+func (s *BGZFSuite) TestWriterRoundTrip(c *C) {
+	rdr, err := NewReader(strings.NewReader(readFixture(c)), false)
+	c.Assert(err, IsNil)
+
+	path := filepath.Join(c.MkDir(), "out.vcf.gz")
+	w, err := Create(path, rdr.Header)
+	c.Assert(err, IsNil)
+
+	var lines []string
+	var offsets []VirtualOffset
+	for v := rdr.Read(); v != nil; v = rdr.Read() {
+		c.Assert(w.WriteVariant(v), IsNil)
+		start, end := w.Offsets()
+		c.Assert(end > start, Equals, true)
+		lines = append(lines, v.String())
+		offsets = append(offsets, start)
+	}
+	c.Assert(w.Close(), IsNil)
+
+	data, err := os.ReadFile(path)
+	c.Assert(err, IsNil)
+	c.Assert(bytes.HasSuffix(data, bgzfEOF), Equals, true)
+
+	// each offset points at the start of its record.
+	for i, off := range offsets {
+		br := newBGZFReader(bufio.NewReader(bytes.NewReader(data[off.Compressed():])), nil)
+		buf := bufio.NewReader(br)
+		_, err := buf.Discard(off.Uncompressed())
+		c.Assert(err, IsNil)
+		line, err := buf.ReadString('\n')
+		c.Assert(err, IsNil)
+		c.Assert(line, Equals, lines[i]+"\n")
+	}
+
+	rdr2, err := Open(path, false)
+	c.Assert(err, IsNil)
+	c.Assert(rdr2.Compression(), Equals, CompressionBGZF)
+	c.Assert(readAllVariants(c, rdr2), DeepEquals, lines)
+	c.Assert(rdr2.Close(), IsNil)
+}
+
+func (s *BGZFSuite) TestWriterBlocks(c *C) {
+	// incompressible data must still fit in a block.
+	data := make([]byte, 3*bgzfBlockSize+17)
+	x := uint32(1)
+	for i := range data {
+		x ^= x << 13
+		x ^= x >> 17
+		x ^= x << 5
+		data[i] = byte(x)
+	}
+	var out bytes.Buffer
+	bw := NewBGZFWriter(&out)
+	n, err := bw.Write(data)
+	c.Assert(err, IsNil)
+	c.Assert(n, Equals, len(data))
+	c.Assert(bw.VirtualOffset().Uncompressed(), Equals, 17)
+	c.Assert(bw.Close(), IsNil)
+	c.Assert(bw.Close(), IsNil)
+	_, err = bw.Write([]byte("x"))
+	c.Assert(err, Not(IsNil))
+
+	compressed := out.Bytes()
+	for p := compressed; len(p) > 0; {
+		c.Assert(isBGZFHeader(p), Equals, true)
+		bsize := int(binary.LittleEndian.Uint16(p[16:])) + 1
+		c.Assert(bsize <= 1<<16, Equals, true)
+		p = p[bsize:]
+	}
+	got, err := io.ReadAll(newBGZFReader(bufio.NewReader(bytes.NewReader(compressed)), nil))
+	c.Assert(err, IsNil)
+	c.Assert(bytes.Equal(got, data), Equals, true)
+}
+
+func (s *BGZFSuite) TestVirtualOffset(c *C) {
+	o := NewVirtualOffset(123456, 789)
+	c.Assert(o.Compressed(), Equals, int64(123456))
+	c.Assert(o.Uncompressed(), Equals, 789)
+	c.Assert(o.String(), Equals, "123456:789")
+}
@@ -0,0 +1,245 @@
+package vcfgo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// formatValues returns the raw value of the FORMAT field key for each sample
+// of v, taken from the parsed Samples or, with lazy samples, straight from the
+// sample columns. A sample that lacks the field gets ".".
+func (v *Variant) formatValues(key string) ([]string, *SampleFormat, error) {
+	format, ok := v.Header.SampleFormats[key]
+	if !ok {
+		return nil, nil, fmt.Errorf("field not found in formats: %s: %w", key, ErrUndeclared)
+	}
+	idx := -1
+	for j, f := range v.Format {
+		if f == key {
+			idx = j
+			break
+		}
+	}
+	if idx == -1 {
+		return nil, format, fmt.Errorf("field not found in genotypes: %s", key)
+	}
+	raw := v.rawFormat(idx)
+	if v.Samples == nil && !v.edited() {
+		return raw, format, nil
+	}
+	// with a sample selection the columns that were not decoded are still raw.
+	value := func(s *SampleGenotype, i int) string {
+		if s != nil {
+			if val, ok := s.Fields[key]; ok && val != "" {
+				return val
+			}
+		}
+		if i < len(raw) {
+			return raw[i]
+		}
+		return "."
+	}
+	if !v.edited() {
+		values := make([]string, len(v.Samples))
+		for i, s := range v.Samples {
+			values[i] = value(s, i)
+		}
+		return values, format, nil
+	}
+	ids, parsed := v.sampleLayout()
+	values := make([]string, len(ids))
+	for k, i := range ids {
+		var s *SampleGenotype
+		if parsed[k] >= 0 {
+			s = v.Samples[parsed[k]]
+		}
+		values[k] = value(s, i)
+	}
+	return values, format, nil
+}
+
+// rawFormat returns the idx'th FORMAT value of each sample from the unparsed
+// sample columns. Trailing fields may be dropped from a sample; they are ".".
+func (v *Variant) rawFormat(idx int) []string {
+	if v.sampleString == "" {
+		return nil
+	}
+	values := make([]string, 0, strings.Count(v.sampleString, "\t")+1)
+	rest := v.sampleString
+	for {
+		sample, more, found := strings.Cut(rest, "\t")
+		val := "."
+		for j := 0; ; j++ {
+			f, r, ok := strings.Cut(sample, ":")
+			if j == idx {
+				if f != "" {
+					val = f
+				}
+				break
+			}
+			if !ok {
+				break
+			}
+			sample = r
+		}
+		values = append(values, val)
+		if !found {
+			return values
+		}
+		rest = more
+	}
+}
+
+// formatStride returns the number of values per sample of a field with the
+// given header Number, or false if it varies.
+func (v *Variant) formatStride(format *SampleFormat) (int, bool) {
+	nAlts := len(v.Alternate)
+	switch format.Number {
+	case "A":
+		return nAlts, true
+	case "R":
+		return nAlts + 1, true
+	case "G":
+		return genotypeCount(nAlts+1, v.maxPloidy()), true
+	}
+	n, err := strconv.Atoi(format.Number)
+	return n, err == nil
+}
+
+// maxPloidy returns the largest number of alleles in the GT of any sample, or 2
+// if there is no GT.
+func (v *Variant) maxPloidy() int {
+	ploidy := 0
+	if v.Samples != nil {
+		for _, s := range v.Samples {
+			if s != nil && len(s.GT) > ploidy {
+				ploidy = len(s.GT)
+			}
+		}
+	} else {
+		for j, f := range v.Format {
+			if f != "GT" {
+				continue
+			}
+			for _, gt := range v.rawFormat(j) {
+				if p := strings.Count(gt, "/") + strings.Count(gt, "|") + 1; p > ploidy {
+					ploidy = p
+				}
+			}
+		}
+	}
+	if ploidy == 0 {
+		return 2
+	}
+	return ploidy
+}
+
+// layout checks values against the Number of format and returns the stride of the column.
+func (v *Variant) layout(key string, format *SampleFormat, values []string) (int, error) {
+	stride, fixed := v.formatStride(format)
+	max := 0
+	for _, val := range values {
+		n := strings.Count(val, ",") + 1
+		if n > max {
+			max = n
+		}
+		if fixed && val != "." && n > stride {
+			return 0, fmt.Errorf("%s has %d values but Number=%s allows %d: %w", key, n, format.Number, stride, ErrValueCount)
+		}
+	}
+	if !fixed {
+		stride = max
+	}
+	return stride, nil
+}
+
+// FormatInts returns the values of the Integer FORMAT field key for all
+// samples in one slice with stride values per sample, so that value j of
+// sample i is at i*stride+j. The stride follows the Number of the field in the
+// header (A, R and G are sized by the ALT alleles and the largest ploidy);
+// for variable Numbers it is the largest count of any sample. Missing values
+// and the padding of shorter samples are MISSING_INT.
+func (v *Variant) FormatInts(key string) ([]int32, int, error) {
+	values, format, err := v.formatValues(key)
+	if err != nil {
+		return nil, 0, err
+	}
+	if format.Type != "Integer" {
+		return nil, 0, fmt.Errorf("%s has Type=%s: %w", key, format.Type, ErrTypeMismatch)
+	}
+	stride, err := v.layout(key, format, values)
+	if err != nil {
+		return nil, 0, err
+	}
+	out := make([]int32, len(values)*stride)
+	for i := range out {
+		out[i] = MISSING_INT
+	}
+	for i, val := range values {
+		for j := 0; val != ""; j++ {
+			var s string
+			s, val, _ = strings.Cut(val, ",")
+			if s == "." {
+				continue
+			}
+			n, ok := parseInt(s)
+			// MISSING_INT itself can not be told from a missing value.
+			if !ok || n <= MISSING_INT || n > 1<<31-1 {
+				return nil, 0, fmt.Errorf("bad Integer for %s in sample %d: %s: %w", key, i, s, ErrInvalidValue)
+			}
+			out[i*stride+j] = int32(n)
+		}
+	}
+	return out, stride, nil
+}
+
+// FormatFloats is FormatInts for a Float (or Integer) FORMAT field. Missing
+// values and padding are MISSING_VAL.
+func (v *Variant) FormatFloats(key string) ([]float32, int, error) {
+	values, format, err := v.formatValues(key)
+	if err != nil {
+		return nil, 0, err
+	}
+	if format.Type != "Float" && format.Type != "Integer" {
+		return nil, 0, fmt.Errorf("%s has Type=%s: %w", key, format.Type, ErrTypeMismatch)
+	}
+	stride, err := v.layout(key, format, values)
+	if err != nil {
+		return nil, 0, err
+	}
+	out := make([]float32, len(values)*stride)
+	for i := range out {
+		out[i] = MISSING_VAL
+	}
+	for i, val := range values {
+		for j := 0; val != ""; j++ {
+			var s string
+			s, val, _ = strings.Cut(val, ",")
+			if s == "." {
+				continue
+			}
+			f, err := strconv.ParseFloat(s, 32)
+			if err != nil {
+				return nil, 0, fmt.Errorf("bad Float for %s in sample %d: %s: %w", key, i, s, ErrInvalidValue)
+			}
+			out[i*stride+j] = float32(f)
+		}
+	}
+	return out, stride, nil
+}
+
+// FormatStrings returns the value of the FORMAT field key for each sample,
+// percent-decoded if the header says so. A missing value is ".".
+func (v *Variant) FormatStrings(key string) ([]string, error) {
+	values, _, err := v.formatValues(key)
+	if err != nil {
+		return nil, err
+	}
+	if v.Header.percentEncoded() {
+		for i, val := range values {
+			values[i] = PercentDecode(val)
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,108 @@
+package vcfgo
+
+import (
+	"errors"
+	"math"
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+type ColumnsSuite struct{}
+
+var _ = Suite(&ColumnsSuite{})
+
+const columnsVCF = `##fileformat=VCFv4.3
+##FORMAT=<ID=GT,Number=1,Type=String,Description="Genotype">
+##FORMAT=<ID=DP,Number=1,Type=Integer,Description="Depth">
+##FORMAT=<ID=AD,Number=R,Type=Integer,Description="Allele depths">
+##FORMAT=<ID=PL,Number=G,Type=Integer,Description="Phred-scaled likelihoods">
+##FORMAT=<ID=AF,Number=A,Type=Float,Description="Allele fractions">
+##FORMAT=<ID=XS,Number=.,Type=Integer,Description="Variable">
+##FORMAT=<ID=FT,Number=1,Type=String,Description="Sample filter">
+##FORMAT=<ID=NA,Number=1,Type=Integer,Description="Not in the records">
+#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO	FORMAT	S1	S2	S3
+1	100	.	A	C,G	.	PASS	.	GT:DP:AD:PL:AF:XS:FT	0/1:10:4,6,0:0,1,2,3,4,5:0.6,.:1:low%3Adepth	1/2:.:.:.:.:1,2,3:.	./.
+1	200	.	A	C	.	PASS	.	GT:AD	0	0/1:1,2,3
+`
+
+func (s *ColumnsSuite) TestColumns(c *C) {
+	for _, lazy := range []bool{false, true} {
+		rdr, err := NewReader(strings.NewReader(columnsVCF), lazy)
+		c.Assert(err, IsNil)
+		v := rdr.Read()
+		comment := Commentf("lazy: %v", lazy)
+
+		dp, stride, err := v.FormatInts("DP")
+		c.Assert(err, IsNil, comment)
+		c.Assert(stride, Equals, 1)
+		c.Assert(dp, DeepEquals, []int32{10, MISSING_INT, MISSING_INT}, comment)
+
+		ad, stride, err := v.FormatInts("AD")
+		c.Assert(err, IsNil, comment)
+		c.Assert(stride, Equals, 3)
+		c.Assert(ad, DeepEquals, []int32{4, 6, 0, MISSING_INT, MISSING_INT, MISSING_INT, MISSING_INT, MISSING_INT, MISSING_INT}, comment)
+
+		pl, stride, err := v.FormatInts("PL")
+		c.Assert(err, IsNil, comment)
+		c.Assert(stride, Equals, 6)
+		c.Assert(pl[:6], DeepEquals, []int32{0, 1, 2, 3, 4, 5}, comment)
+
+		af, stride, err := v.FormatFloats("AF")
+		c.Assert(err, IsNil, comment)
+		c.Assert(stride, Equals, 2)
+		c.Assert(af[0], Equals, float32(0.6))
+		for _, f := range af[1:] {
+			c.Assert(math.Float32bits(f), Equals, missingBits, comment)
+		}
+
+		// variable Numbers use the largest count and pad the others.
+		xs, stride, err := v.FormatInts("XS")
+		c.Assert(err, IsNil, comment)
+		c.Assert(stride, Equals, 3)
+		c.Assert(xs, DeepEquals, []int32{1, MISSING_INT, MISSING_INT, 1, 2, 3, MISSING_INT, MISSING_INT, MISSING_INT}, comment)
+
+		fdp, _, err := v.FormatFloats("DP")
+		c.Assert(err, IsNil, comment)
+		c.Assert(fdp[0], Equals, float32(10))
+
+		ft, err := v.FormatStrings("FT")
+		c.Assert(err, IsNil, comment)
+		c.Assert(ft, DeepEquals, []string{"low:depth", ".", "."}, comment)
+
+		_, _, err = v.FormatInts("AF")
+		c.Assert(errors.Is(err, ErrTypeMismatch), Equals, true, comment)
+		_, _, err = v.FormatInts("XX")
+		c.Assert(errors.Is(err, ErrUndeclared), Equals, true, comment)
+		_, _, err = v.FormatInts("NA")
+		c.Assert(err, ErrorMatches, "field not found in genotypes: NA")
+
+		// a haploid sample and a sample with too many values.
+		v = rdr.Read()
+		_, _, err = v.FormatInts("AD")
+		c.Assert(errors.Is(err, ErrValueCount), Equals, true, comment)
+	}
+}
+
+func (s *ColumnsSuite) TestIntRange(c *C) {
+	for _, dp := range []string{"-2147483648", "2147483648"} {
+		rdr, err := NewReader(strings.NewReader(strings.Replace(columnsVCF, "0/1:10:", "0/1:"+dp+":", 1)), true)
+		c.Assert(err, IsNil)
+		_, _, err = rdr.Read().FormatInts("DP")
+		c.Assert(errors.Is(err, ErrInvalidValue), Equals, true, Commentf(dp))
+	}
+}
+
+func (s *ColumnsSuite) TestPloidy(c *C) {
+	vcf := strings.Replace(columnsVCF, "0/1:10:4,6,0:0,1,2,3,4,5", "0/1/2:10:4,6,0:0,1,2,3,4,5,6,7,8,9", 1)
+	for _, lazy := range []bool{false, true} {
+		rdr, err := NewReader(strings.NewReader(vcf), lazy)
+		c.Assert(err, IsNil)
+		v := rdr.Read()
+		pl, stride, err := v.FormatInts("PL")
+		c.Assert(err, IsNil)
+		c.Assert(stride, Equals, 10)
+		c.Assert(pl, HasLen, 30)
+		c.Assert(pl[9], Equals, int32(9))
+	}
+}
@@ -0,0 +1,354 @@
+package vcfgo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// faiEntry is a line of a samtools faidx index.
+type faiEntry struct {
+	name   string
+	length int64
+	// offset is that of the first base in the (uncompressed) file.
+	offset int64
+	// lineBases is the number of bases and lineWidth the number of bytes of each line.
+	lineBases, lineWidth int64
+}
+
+// gziEntry maps the compressed offset of a BGZF block to its uncompressed offset.
+type gziEntry struct {
+	compressed, uncompressed int64
+}
+
+// FastaReader returns subsequences of a FASTA file indexed by samtools faidx.
+// The file may be plain text or BGZF compressed. It implements RefSeq and is
+// safe for concurrent use.
+type FastaReader struct {
+	mu sync.Mutex
+	r  io.ReadSeeker
+	// closer is set when the FastaReader opened the file itself.
+	closer io.Closer
+
+	index map[string]*faiEntry
+	names []string
+
+	// bgzf and gzi are set for BGZF input.
+	bgzf *bgzfReader
+	gzi  []gziEntry
+}
+
+// OpenFasta opens the FASTA at path with its index at path + ".fai". If the
+// file is BGZF compressed, the block index at path + ".gzi" is read as well.
+// An index that does not exist is built by reading the file.
+func OpenFasta(path string) (*FastaReader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	var fai, gzi io.Reader
+	for _, ext := range []string{".fai", ".gzi"} {
+		idx, err := os.Open(path + ext)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		defer idx.Close()
+		if ext == ".fai" {
+			fai = idx
+		} else {
+			gzi = idx
+		}
+	}
+	fr, err := NewFastaReader(f, fai, gzi)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	fr.closer = f
+	return fr, nil
+}
+
+// NewFastaReader returns a FastaReader for the FASTA in r with the faidx index
+// in fai and, for BGZF input, the block index in gzi. Either index may be nil
+// to build it from r.
+func NewFastaReader(r io.ReadSeeker, fai, gzi io.Reader) (*FastaReader, error) {
+	fr := &FastaReader{r: r}
+	buf := bufio.NewReader(r)
+	if detectCompression(buf) == CompressionBGZF {
+		var err error
+		if gzi != nil {
+			fr.gzi, err = readGzi(gzi)
+		} else {
+			fr.gzi, err = buildGzi(buf)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if _, err := r.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		buf.Reset(r)
+		fr.bgzf = newBGZFReader(buf, r)
+	} else if detectCompression(buf) != CompressionNone {
+		return nil, errors.New("vcfgo: a compressed FASTA must be BGZF")
+	}
+
+	var entries []*faiEntry
+	var err error
+	if fai != nil {
+		entries, err = readFai(fai)
+	} else {
+		var src io.Reader = buf
+		if fr.bgzf != nil {
+			src = fr.bgzf
+		}
+		entries, err = buildFai(src)
+	}
+	if err != nil {
+		return nil, err
+	}
+	fr.index = make(map[string]*faiEntry, len(entries))
+	for _, e := range entries {
+		fr.index[e.name] = e
+		fr.names = append(fr.names, e.name)
+	}
+	return fr, nil
+}
+
+// readFai reads a samtools faidx index.
+func readFai(r io.Reader) ([]*faiEntry, error) {
+	var entries []*faiEntry
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		if scanner.Text() == "" {
+			continue
+		}
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) < 5 {
+			return nil, fmt.Errorf("vcfgo: bad fai line %d: %s", line, scanner.Text())
+		}
+		e := &faiEntry{name: fields[0]}
+		for i, p := range []*int64{&e.length, &e.offset, &e.lineBases, &e.lineWidth} {
+			n, err := strconv.ParseInt(fields[i+1], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("vcfgo: bad fai line %d: %w", line, err)
+			}
+			*p = n
+		}
+		// an empty sequence has no lines.
+		if e.length < 0 || e.offset < 0 || e.length > 0 && (e.lineBases <= 0 || e.lineWidth < e.lineBases) {
+			return nil, fmt.Errorf("vcfgo: bad fai line %d: %s", line, scanner.Text())
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+// buildFai indexes the FASTA in r as samtools faidx does.
+func buildFai(r io.Reader) ([]*faiEntry, error) {
+	var entries []*faiEntry
+	var e *faiEntry
+	br := bufio.NewReader(r)
+	var offset int64
+	// short is set after a line with fewer bases than the first of the sequence.
+	short := false
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			n := int64(len(line))
+			offset += n
+			if line[0] == '>' {
+				name := strings.TrimRight(string(line[1:]), "\r\n")
+				if i := strings.IndexAny(name, " \t"); i >= 0 {
+					name = name[:i]
+				}
+				e = &faiEntry{name: name, offset: offset}
+				entries = append(entries, e)
+				short = false
+			} else if e != nil {
+				bases := int64(len(bytes.TrimRight(line, "\r\n")))
+				switch {
+				case bases == 0:
+				case short:
+					return nil, fmt.Errorf("vcfgo: different line length in sequence %s", e.name)
+				case e.lineBases == 0:
+					e.lineBases, e.lineWidth = bases, n
+				case bases > e.lineBases || bases == e.lineBases && n != e.lineWidth:
+					return nil, fmt.Errorf("vcfgo: different line length in sequence %s", e.name)
+				}
+				short = short || bases < e.lineBases
+				e.length += bases
+			}
+		}
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// readGzi reads the block index of a BGZF file as written by bgzip -i.
+func readGzi(r io.Reader) ([]gziEntry, error) {
+	var n uint64
+	if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+		return nil, fmt.Errorf("vcfgo: error reading gzi: %w", err)
+	}
+	// the pairs are read one at a time as n may be corrupt.
+	br := bufio.NewReader(r)
+	gzi := []gziEntry{{0, 0}}
+	for i := uint64(0); i < n; i++ {
+		var pair [2]uint64
+		if err := binary.Read(br, binary.LittleEndian, &pair); err != nil {
+			return nil, fmt.Errorf("vcfgo: error reading gzi: %w", err)
+		}
+		last := gzi[len(gzi)-1]
+		if pair[0] > math.MaxInt64 || pair[1] > math.MaxInt64 || int64(pair[0]) <= last.compressed || int64(pair[1]) < last.uncompressed {
+			return nil, fmt.Errorf("vcfgo: error reading gzi: offsets of block %d are out of order", i+1)
+		}
+		gzi = append(gzi, gziEntry{int64(pair[0]), int64(pair[1])})
+	}
+	return gzi, nil
+}
+
+// buildGzi reads the headers of the BGZF blocks in r to index them.
+func buildGzi(r *bufio.Reader) ([]gziEntry, error) {
+	var gzi []gziEntry
+	var coff, uoff int64
+	for {
+		h, err := r.Peek(bgzfHeaderSize)
+		if len(h) == 0 && err == io.EOF {
+			return gzi, nil
+		}
+		if !isBGZFHeader(h) {
+			return nil, fmt.Errorf("bgzf: invalid block header at offset %d", coff)
+		}
+		bsize := int(binary.LittleEndian.Uint16(h[16:])) + 1
+		if _, err := r.Discard(bsize - 4); err != nil {
+			return nil, err
+		}
+		var isize uint32
+		if err := binary.Read(r, binary.LittleEndian, &isize); err != nil {
+			return nil, err
+		}
+		gzi = append(gzi, gziEntry{coff, uoff})
+		coff += int64(bsize)
+		uoff += int64(isize)
+	}
+}
+
+// Names returns the names of the sequences in the order of the index.
+func (fr *FastaReader) Names() []string {
+	return fr.names
+}
+
+// Length returns the length of the sequence chrom and whether it is in the index.
+func (fr *FastaReader) Length(chrom string) (int, bool) {
+	e, ok := fr.index[chrom]
+	if !ok {
+		return 0, false
+	}
+	return int(e.length), true
+}
+
+// Fetch returns the bases of chrom in the 0-based, half-open region [start, end).
+func (fr *FastaReader) Fetch(chrom string, start, end int) ([]byte, error) {
+	e, ok := fr.index[chrom]
+	if !ok {
+		return nil, fmt.Errorf("vcfgo: sequence not found in FASTA: %s", chrom)
+	}
+	if start < 0 || start > end || int64(end) > e.length {
+		return nil, fmt.Errorf("vcfgo: %s:%d-%d is outside of %s (length %d)", chrom, start, end, chrom, e.length)
+	}
+	if start == end {
+		return []byte{}, nil
+	}
+	from := e.offset + int64(start)/e.lineBases*e.lineWidth + int64(start)%e.lineBases
+	to := e.offset + int64(end-1)/e.lineBases*e.lineWidth + int64(end-1)%e.lineBases + 1
+
+	raw := make([]byte, to-from)
+	fr.mu.Lock()
+	err := fr.read(raw, from)
+	fr.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	seq := raw[:0]
+	for _, c := range raw {
+		if c != '\n' && c != '\r' {
+			seq = append(seq, c)
+		}
+	}
+	return seq, nil
+}
+
+// read fills p from the uncompressed offset off. fr.mu must be held.
+func (fr *FastaReader) read(p []byte, off int64) error {
+	if fr.bgzf == nil {
+		if _, err := fr.r.Seek(off, io.SeekStart); err != nil {
+			return err
+		}
+		_, err := io.ReadFull(fr.r, p)
+		return err
+	}
+	i := sort.Search(len(fr.gzi), func(i int) bool { return fr.gzi[i].uncompressed > off }) - 1
+	block := fr.gzi[i]
+	b := fr.bgzf
+	if b.coff == block.compressed && len(b.block) > 0 && b.err == nil {
+		// the block is already decompressed.
+		b.off = int(off - block.uncompressed)
+	} else if err := b.seek(NewVirtualOffset(block.compressed, int(off-block.uncompressed))); err != nil {
+		return err
+	}
+	_, err := io.ReadFull(b, p)
+	return err
+}
+
+// Close closes the file if the FastaReader was made by OpenFasta.
+func (fr *FastaReader) Close() error {
+	if fr.bgzf != nil {
+		fr.bgzf.Close()
+	}
+	if fr.closer != nil {
+		return fr.closer.Close()
+	}
+	return nil
+}
+
+// CheckContigs compares the ##contig lines of h that have a length with the
+// sequences of fa. It returns an error naming each contig that is not in fa or
+// whose length differs.
+func (h *Header) CheckContigs(fa *FastaReader) error {
+	var problems []string
+	for _, c := range h.Contigs {
+		l, ok := c["length"]
+		if !ok {
+			continue
+		}
+		length, ok := fa.Length(c["ID"])
+		switch {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("%s is not in the reference", c["ID"]))
+		case strconv.Itoa(length) != l:
+			problems = append(problems, fmt.Sprintf("%s has length %s but %d in the reference", c["ID"], l, length))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("vcfgo: contigs do not match the reference: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package vcfgo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	. "gopkg.in/check.v1"
+)
+
+type FastaSuite struct{}
+
+var _ = Suite(&FastaSuite{})
+
+const fasta = `>chr1 first
+ACGTACGTAC
+GTACGTACGT
+ACG
+>chr2
+TTTTGGGGCC
+CCAA
+`
+
+const fastaFai = "chr1\t23\t12\t10\t11\nchr2\t14\t44\t10\t11\n"
+
+func (s *FastaSuite) TestBuildFai(c *C) {
+	entries, err := buildFai(strings.NewReader(fasta))
+	c.Assert(err, IsNil)
+	want, err := readFai(strings.NewReader(fastaFai))
+	c.Assert(err, IsNil)
+	c.Assert(entries, DeepEquals, want)
+
+	_, err = buildFai(strings.NewReader(">a\nACGT\nAC\nACGT\n"))
+	c.Assert(err, ErrorMatches, "vcfgo: different line length in sequence a")
+	for _, bad := range []string{"chr1\t23\t13\n", "chr1\t23\t13\t0\t0\n", "chr1\t23\t13\t10\t9\n", "chr1\t-1\t13\t10\t11\n"} {
+		_, err = readFai(strings.NewReader(bad))
+		c.Assert(err, ErrorMatches, "vcfgo: bad fai line 1: .*", Commentf(bad))
+	}
+	// an empty sequence has no line length.
+	entries, err = readFai(strings.NewReader("empty\t0\t7\t0\t0\n"))
+	c.Assert(err, IsNil)
+	fr := &FastaReader{index: map[string]*faiEntry{"empty": entries[0]}}
+	seq, err := fr.Fetch("empty", 0, 0)
+	c.Assert(err, IsNil)
+	c.Assert(seq, HasLen, 0)
+	_, err = fr.Fetch("empty", 0, 1)
+	c.Assert(err, Not(IsNil))
+}
+
+func (s *FastaSuite) TestBadGzi(c *C) {
+	var gzi bytes.Buffer
+	// a count far beyond the data.
+	binary.Write(&gzi, binary.LittleEndian, []uint64{1 << 60, 100, 200})
+	_, err := readGzi(&gzi)
+	c.Assert(err, ErrorMatches, "vcfgo: error reading gzi: .*EOF")
+
+	gzi.Reset()
+	binary.Write(&gzi, binary.LittleEndian, []uint64{2, 100, 200, 50, 300})
+	_, err = readGzi(&gzi)
+	c.Assert(err, ErrorMatches, "vcfgo: error reading gzi: offsets of block 2 are out of order")
+}
+
+func checkFetch(c *C, fr *FastaReader) {
+	c.Assert(fr.Names(), DeepEquals, []string{"chr1", "chr2"})
+	for _, t := range []struct {
+		chrom      string
+		start, end int
+		exp        string
+	}{
+		{"chr1", 0, 4, "ACGT"},
+		{"chr1", 8, 13, "ACGTA"},
+		{"chr1", 0, 23, "ACGTACGTACGTACGTACGTACG"},
+		{"chr1", 20, 23, "ACG"},
+		{"chr2", 9, 14, "CCCAA"},
+		{"chr1", 5, 5, ""},
+	} {
+		seq, err := fr.Fetch(t.chrom, t.start, t.end)
+		c.Assert(err, IsNil)
+		c.Assert(string(seq), Equals, t.exp, Commentf("%s:%d-%d", t.chrom, t.start, t.end))
+	}
+	_, err := fr.Fetch("chr3", 0, 1)
+	c.Assert(err, ErrorMatches, "vcfgo: sequence not found in FASTA: chr3")
+	_, err = fr.Fetch("chr2", 10, 15)
+	c.Assert(err, ErrorMatches, ".*outside of chr2.*")
+
+	// concurrent lookups each get their own bases.
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				seq, err := fr.Fetch("chr1", i, i+12)
+				if err != nil || string(seq) != "ACGTACGTACGTACGTACGTACG"[i:i+12] {
+					c.Errorf("bad fetch at %d: %s %v", i, seq, err)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func (s *FastaSuite) TestPlain(c *C) {
+	path := filepath.Join(c.MkDir(), "ref.fa")
+	c.Assert(os.WriteFile(path, []byte(fasta), 0644), IsNil)
+
+	// without an index it is built.
+	fr, err := OpenFasta(path)
+	c.Assert(err, IsNil)
+	checkFetch(c, fr)
+	c.Assert(fr.Close(), IsNil)
+
+	c.Assert(os.WriteFile(path+".fai", []byte(fastaFai), 0644), IsNil)
+	fr, err = OpenFasta(path)
+	c.Assert(err, IsNil)
+	checkFetch(c, fr)
+	c.Assert(fr.Close(), IsNil)
+}
+
+func (s *FastaSuite) TestBGZF(c *C) {
+	data := makeBGZF(c, []byte(fasta), 7)
+	fr, err := NewFastaReader(bytes.NewReader(data), strings.NewReader(fastaFai), nil)
+	c.Assert(err, IsNil)
+	checkFetch(c, fr)
+	built := fr.gzi
+
+	// a .gzi as written by bgzip -i leaves out the first block.
+	var gzi bytes.Buffer
+	binary.Write(&gzi, binary.LittleEndian, uint64(len(built)-1))
+	for _, e := range built[1:] {
+		binary.Write(&gzi, binary.LittleEndian, []uint64{uint64(e.compressed), uint64(e.uncompressed)})
+	}
+	path := filepath.Join(c.MkDir(), "ref.fa.gz")
+	c.Assert(os.WriteFile(path, data, 0644), IsNil)
+	c.Assert(os.WriteFile(path+".gzi", gzi.Bytes(), 0644), IsNil)
+	fr, err = OpenFasta(path)
+	c.Assert(err, IsNil)
+	c.Assert(fr.gzi, DeepEquals, built)
+	checkFetch(c, fr)
+	c.Assert(fr.Close(), IsNil)
+}
+
+func (s *FastaSuite) TestCheckContigs(c *C) {
+	fr, err := NewFastaReader(strings.NewReader(fasta), strings.NewReader(fastaFai), nil)
+	c.Assert(err, IsNil)
+	h := NewHeader()
+	h.Contigs = append(h.Contigs, map[string]string{"ID": "chr1", "length": "23"}, map[string]string{"ID": "chr2"})
+	c.Assert(h.CheckContigs(fr), IsNil)
+
+	h.Contigs = append(h.Contigs, map[string]string{"ID": "chr2", "length": "15"}, map[string]string{"ID": "chrX", "length": "5"})
+	c.Assert(h.CheckContigs(fr), ErrorMatches, "vcfgo: contigs do not match the reference: chr2 has length 15 but 14 in the reference; chrX is not in the reference")
+}
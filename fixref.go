@@ -0,0 +1,387 @@
+package vcfgo
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// RefStatus is how the REF of a record compares with the reference genome.
+type RefStatus int
+
+const (
+	// RefMatch means that REF is the reference sequence.
+	RefMatch RefStatus = iota
+	// RefSwapped means that the ALT of a bi-allelic SNV is the reference base.
+	// An A/T or C/G SNV whose REF does not match is taken as swapped, as its
+	// strand can not be told.
+	RefSwapped
+	// RefFlipped means that the complement of the REF of a bi-allelic SNV is the
+	// reference base: the alleles are those of the other strand.
+	RefFlipped
+	// RefFlippedSwapped means that the complement of the ALT of a bi-allelic SNV
+	// is the reference base.
+	RefFlippedSwapped
+	// RefMismatch means that REF does not match and that is not explained by a
+	// swap or a strand flip.
+	RefMismatch
+	// RefSkipped means that the record could not be checked: REF is not made of
+	// bases, the reference is N or the region is not in the reference.
+	RefSkipped
+)
+
+func (s RefStatus) String() string {
+	switch s {
+	case RefMatch:
+		return "match"
+	case RefSwapped:
+		return "swapped"
+	case RefFlipped:
+		return "flipped"
+	case RefFlippedSwapped:
+		return "flipped+swapped"
+	case RefMismatch:
+		return "mismatch"
+	case RefSkipped:
+		return "skipped"
+	}
+	return fmt.Sprintf("RefStatus(%d)", int(s))
+}
+
+// complement holds the complement of each base.
+var complement = map[byte]byte{'A': 'T', 'C': 'G', 'G': 'C', 'T': 'A', 'N': 'N',
+	'a': 't', 'c': 'g', 'g': 'c', 't': 'a', 'n': 'n'}
+
+func complementAllele(allele string) string {
+	b := []byte(allele)
+	for i, c := range b {
+		b[i] = complement[c]
+	}
+	return string(b)
+}
+
+// CheckRef compares the REF of v with ref. Only bi-allelic SNVs can be found
+// to be swapped or flipped; other records either match or not. An error from
+// ref is returned with RefSkipped.
+func CheckRef(v *Variant, ref RefSeq) (RefStatus, error) {
+	status, _, err := checkRef(v, ref)
+	return status, err
+}
+
+// checkRef is CheckRef that also returns the reference sequence at REF.
+func checkRef(v *Variant, ref RefSeq) (RefStatus, []byte, error) {
+	if !isSequence(v.Reference) {
+		return RefSkipped, nil, nil
+	}
+	start := int(v.Pos) - 1
+	seq, err := ref.Fetch(v.Chromosome, start, start+len(v.Reference))
+	if err != nil {
+		return RefSkipped, nil, err
+	}
+	if strings.EqualFold(string(seq), v.Reference) {
+		return RefMatch, seq, nil
+	}
+	if bytes.ContainsAny(seq, "Nn") {
+		return RefSkipped, seq, nil
+	}
+	if !isBiallelicSNV(v) {
+		return RefMismatch, seq, nil
+	}
+	r, a := strings.ToUpper(v.Reference), strings.ToUpper(v.Alternate[0])
+	switch base := strings.ToUpper(string(seq)); base {
+	case a:
+		return RefSwapped, seq, nil
+	case complementAllele(r):
+		return RefFlipped, seq, nil
+	case complementAllele(a):
+		return RefFlippedSwapped, seq, nil
+	}
+	return RefMismatch, seq, nil
+}
+
+// isBiallelicSNV reports whether v has a single base REF and a single base ALT,
+// the only records whose alleles can be swapped or flipped.
+func isBiallelicSNV(v *Variant) bool {
+	return len(v.Reference) == 1 && len(v.Alternate) == 1 && len(v.Alternate[0]) == 1 && isSequence(v.Alternate[0])
+}
+
+// FixRef corrects v for the status that CheckRef returned, like bcftools
+// +fixref. Flipped alleles are complemented. Swapped alleles are exchanged:
+// GT is re-coded, Number=R and G values of INFO and FORMAT (such as AD and PL)
+// are reordered, and AC and AF of INFO become those of the new ALT. Values
+// whose count does not match their Number are kept and reported in Errors.
+// A record that matches is left as it is; one that does not match otherwise,
+// or that is not a bi-allelic SNV, can not be fixed and an error wrapping
+// ErrRefMismatch is returned.
+func FixRef(v *Variant, status RefStatus) error {
+	var swap bool
+	switch status {
+	case RefMatch:
+		return nil
+	case RefSwapped, RefFlippedSwapped:
+		swap = true
+	case RefFlipped:
+	default:
+		return fmt.Errorf("FixRef: can not fix REF %s at %s:%d (%s): %w", v.Reference, v.Chromosome, v.Pos, status, ErrRefMismatch)
+	}
+	if !isBiallelicSNV(v) {
+		return fmt.Errorf("FixRef: can not fix REF %s at %s:%d (%s) of a record that is not a bi-allelic SNV: %w", v.Reference, v.Chromosome, v.Pos, status, ErrRefMismatch)
+	}
+	if status == RefFlipped || status == RefFlippedSwapped {
+		v.Reference = complementAllele(v.Reference)
+		v.Alternate = []string{complementAllele(v.Alternate[0])}
+	}
+	if !swap {
+		return nil
+	}
+	v.Reference, v.Alternate = v.Alternate[0], []string{v.Reference}
+
+	if v.Info_ != nil {
+		v.Info_ = NewInfoByte([]byte(swapInfo(v)), v.Header)
+	}
+	var raw []string
+	if v.sampleString != "" {
+		raw = strings.Split(v.sampleString, "\t")
+	}
+	if v.Samples != nil {
+		var want map[string]bool
+		if sel := v.Header.selection; sel != nil {
+			want = sel.fields
+		}
+		for j, g := range v.Samples {
+			if g == nil {
+				continue
+			}
+			id := j
+			if v.parsed != nil {
+				id = v.parsed[j]
+			}
+			col := swapSample(v, v.sampleColumn(g, id, raw))
+			v.Samples[j], _ = v.Header.parseSampleFields(v.Format, col, want)
+		}
+	}
+	if raw != nil {
+		for j, col := range raw {
+			raw[j] = swapSample(v, col)
+		}
+		v.sampleString = strings.Join(raw, "\t")
+	}
+	return nil
+}
+
+// swapInfo returns the INFO of v as text for its swapped alleles. Errors go to v.
+func swapInfo(v *Variant) string {
+	text := v.Info_.String()
+	if text == "." || text == "" {
+		return text
+	}
+	fields := strings.Split(text, ";")
+	an := -1
+	for _, kv := range fields {
+		if key, val, _ := strings.Cut(kv, "="); key == "AN" {
+			if n, err := strconv.Atoi(val); err == nil {
+				an = n
+			}
+		}
+	}
+	for i, kv := range fields {
+		key, val, hasVal := strings.Cut(kv, "=")
+		def, ok := v.Header.Infos[key]
+		if !ok || !hasVal || val == "." {
+			continue
+		}
+		switch {
+		case key == "AC" && def.Number == "A":
+			if c, err := strconv.Atoi(val); err == nil && an >= 0 {
+				val = strconv.Itoa(an - c)
+			}
+		case key == "AF" && def.Number == "A":
+			if f, err := strconv.ParseFloat(val, 32); err == nil {
+				val = strconv.FormatFloat(float64(1-float32(f)), 'g', -1, 32)
+			}
+		case def.Number == "R" || def.Number == "G":
+			vals, err := swapValues(def.Number, strings.Split(val, ","))
+			if err != nil {
+				v.Errors = append(v.Errors, &ParseError{Line: v.LineNumber, Column: "INFO", Key: key, Sample: -1, Kind: ErrValueCount, Err: err})
+				continue
+			}
+			val = strings.Join(vals, ",")
+		}
+		fields[i] = key + "=" + val
+	}
+	return strings.Join(fields, ";")
+}
+
+// swapSample returns a sample column of the bi-allelic record v with REF and
+// ALT exchanged. Errors go to v.
+func swapSample(v *Variant, col string) string {
+	values := strings.Split(col, ":")
+	for j, key := range v.Format {
+		if j >= len(values) || values[j] == "." {
+			continue
+		}
+		if key == "GT" {
+			values[j] = swapGenotype(values[j])
+			continue
+		}
+		def, ok := v.Header.SampleFormats[key]
+		if !ok || def.Number != "R" && def.Number != "G" {
+			continue
+		}
+		vals, err := swapValues(def.Number, strings.Split(values[j], ","))
+		if err != nil {
+			v.Errors = append(v.Errors, &ParseError{Line: v.LineNumber, Column: "FORMAT", Key: key, Sample: -1, Kind: ErrValueCount, Err: err})
+			continue
+		}
+		values[j] = strings.Join(vals, ",")
+	}
+	return strings.Join(values, ":")
+}
+
+// swapGenotype exchanges the 0 and 1 alleles of a GT.
+func swapGenotype(gt string) string {
+	b := []byte(gt)
+	start := 0
+	for k := 0; k <= len(b); k++ {
+		if k < len(b) && !isGenotypeSep(rune(b[k])) {
+			continue
+		}
+		if k-start == 1 && (b[start] == '0' || b[start] == '1') {
+			b[start] ^= 1
+		}
+		start = k + 1
+	}
+	return string(b)
+}
+
+// swapValues reorders the values of a Number=R or G field of a bi-allelic
+// record for its swapped alleles. The genotypes of any ploidy are ordered by
+// their count of ALT alleles, so both are reversed.
+func swapValues(number string, vals []string) ([]string, error) {
+	if number == "R" && len(vals) != 2 {
+		return nil, fmt.Errorf("incorrect number of alleles in swapValues: %v", vals)
+	}
+	if number == "G" && len(vals) < 2 {
+		return nil, fmt.Errorf("incorrect number of genotypes in swapValues: %v", vals)
+	}
+	out := make([]string, len(vals))
+	for i, s := range vals {
+		out[len(vals)-1-i] = s
+	}
+	return out, nil
+}
+
+// RefProblem is a record whose REF did not match the reference.
+type RefProblem struct {
+	Chromosome string
+	Pos        uint64
+	// Reference and Alternate are the alleles as they were read and Expected
+	// is the sequence of the reference at REF.
+	Reference string
+	Alternate []string
+	Expected  string
+	Status    RefStatus
+	// Fixed is set if the record was corrected.
+	Fixed bool
+}
+
+// RefReport summarizes the records read by a RefChecker.
+type RefReport struct {
+	// Counts holds the number of records of each RefStatus.
+	Counts map[RefStatus]int
+	// Fixed is the number of records that were corrected.
+	Fixed int
+	// Problems lists the first records that did not match, in the order they
+	// were read. Once it holds maxRefProblems, the others are only counted in
+	// Dropped.
+	Problems []RefProblem
+	Dropped  int
+}
+
+// maxRefProblems bounds the memory of a RefReport, as a whole genome checked
+// against the wrong build mismatches at most sites.
+var maxRefProblems = 5000
+
+// Total returns the number of records that were read.
+func (r *RefReport) Total() int {
+	n := 0
+	for _, c := range r.Counts {
+		n += c
+	}
+	return n
+}
+
+// String returns a one line summary of the counts.
+func (r *RefReport) String() string {
+	parts := []string{fmt.Sprintf("total=%d", r.Total())}
+	for s := RefMatch; s <= RefSkipped; s++ {
+		parts = append(parts, fmt.Sprintf("%s=%d", s, r.Counts[s]))
+	}
+	parts = append(parts, fmt.Sprintf("fixed=%d", r.Fixed))
+	return strings.Join(parts, " ")
+}
+
+// RefChecker reads records from a VariantReader and checks their REF against
+// a reference genome with CheckRef, like bcftools +fixref. With fix set,
+// swapped and flipped records are corrected with FixRef as they are read.
+// All records are returned, so that the RefChecker can be written out as the
+// corrected stream; the findings are in Report and the errors from the
+// reference in Error.
+type RefChecker struct {
+	r      VariantReader
+	ref    RefSeq
+	fix    bool
+	report RefReport
+	verr   *VCFError
+}
+
+// NewRefChecker returns a RefChecker that reads from r and checks against ref.
+func NewRefChecker(r VariantReader, ref RefSeq, fix bool) *RefChecker {
+	return &RefChecker{r: r, ref: ref, fix: fix, report: RefReport{Counts: make(map[RefStatus]int)}, verr: NewVCFError()}
+}
+
+// Read returns the next record, corrected if needed, or nil when there are no more.
+func (rc *RefChecker) Read() *Variant {
+	v := rc.r.Read()
+	if v == nil {
+		return nil
+	}
+	status, seq, err := checkRef(v, rc.ref)
+	if err != nil {
+		rc.verr.Add(err, v.LineNumber)
+	}
+	rc.report.Counts[status]++
+	if status == RefMatch || status == RefSkipped {
+		return v
+	}
+	p := RefProblem{Chromosome: v.Chromosome, Pos: v.Pos, Reference: v.Reference,
+		Alternate: append([]string(nil), v.Alternate...), Expected: string(seq), Status: status}
+	if rc.fix && status != RefMismatch {
+		if err := FixRef(v, status); err != nil {
+			rc.verr.Add(err, v.LineNumber)
+		} else {
+			p.Fixed = true
+			rc.report.Fixed++
+		}
+	}
+	if len(rc.report.Problems) < maxRefProblems {
+		rc.report.Problems = append(rc.report.Problems, p)
+	} else {
+		rc.report.Dropped++
+	}
+	return v
+}
+
+// Report returns the findings for the records read so far.
+func (rc *RefChecker) Report() *RefReport {
+	return &rc.report
+}
+
+// Error returns the errors from the reference, such as a CHROM that is not in it.
+func (rc *RefChecker) Error() error {
+	if rc.verr.IsEmpty() {
+		return nil
+	}
+	return rc.verr
+}
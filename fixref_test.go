@@ -0,0 +1,145 @@
+package vcfgo
+
+import (
+	"errors"
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+type FixRefSuite struct{}
+
+var _ = Suite(&FixRefSuite{})
+
+const fixrefVCF = `##fileformat=VCFv4.2
+##INFO=<ID=AC,Number=A,Type=Integer,Description="Allele count">
+##INFO=<ID=AN,Number=1,Type=Integer,Description="Total number of alleles">
+##INFO=<ID=AF,Number=A,Type=Float,Description="Allele frequency">
+##FORMAT=<ID=GT,Number=1,Type=String,Description="Genotype">
+##FORMAT=<ID=AD,Number=R,Type=Integer,Description="Allele depths">
+##FORMAT=<ID=PL,Number=G,Type=Integer,Description="Phred-scaled likelihoods">
+#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO	FORMAT	S1	S2
+1	1	.	A	G	.	PASS	AC=1;AN=4;AF=0.25	GT:AD	0/1:3,7	0/0:9,0
+1	2	.	T	C	.	PASS	AC=1;AN=4;AF=0.25	GT:AD:PL	0/1:3,7:0,10,20	1|1:0,5:.
+1	3	.	C	T	.	PASS	AC=1	GT	0/1	0/0
+1	4	.	C	A	.	PASS	AC=1	GT:AD	0/1:2,3	./.:.
+1	5	.	G	C	.	PASS	AC=1	GT	0/1	0/0
+1	6	.	CG	C	.	PASS	AC=1	GT	0/1	0/0
+1	9	.	A	G	.	PASS	AC=1	GT	0/1	0/0
+2	1	.	A	G	.	PASS	AC=1	GT	0/1	0/0
+`
+
+var fixrefRef = testRef{"1": "ACGTACGTNA"}
+
+func (s *FixRefSuite) TestCheckRef(c *C) {
+	rdr, err := NewReader(strings.NewReader(fixrefVCF), true)
+	c.Assert(err, IsNil)
+	var got []RefStatus
+	for v := rdr.Read(); v != nil; v = rdr.Read() {
+		status, err := CheckRef(v, fixrefRef)
+		c.Assert(err != nil, Equals, v.Chromosome == "2")
+		got = append(got, status)
+	}
+	c.Assert(got, DeepEquals, []RefStatus{RefMatch, RefSwapped, RefFlipped, RefFlippedSwapped, RefMismatch, RefMatch, RefSkipped, RefSkipped})
+
+	v := &Variant{Chromosome: "1", Pos: 2, Reference: "G", Alternate: []string{"C"}}
+	status, _ := CheckRef(v, fixrefRef)
+	c.Assert(status, Equals, RefSwapped)
+	c.Assert(FixRef(v, RefMismatch), ErrorMatches, ".*REF G at 1:2 \\(mismatch\\).*")
+	c.Assert(errors.Is(FixRef(v, RefMismatch), ErrRefMismatch), Equals, true)
+
+	// only a bi-allelic SNV can be swapped or flipped.
+	for _, alts := range [][]string{nil, {"C", "T"}, {"CT"}} {
+		v = &Variant{Chromosome: "1", Pos: 2, Reference: "G", Alternate: alts}
+		for _, status := range []RefStatus{RefSwapped, RefFlipped, RefFlippedSwapped} {
+			c.Assert(errors.Is(FixRef(v, status), ErrRefMismatch), Equals, true, Commentf("%v %s", alts, status))
+			c.Assert(v.Alternate, DeepEquals, alts)
+		}
+	}
+}
+
+func (s *FixRefSuite) TestFix(c *C) {
+	for _, lazy := range []bool{false, true} {
+		rdr, err := NewReader(strings.NewReader(fixrefVCF), lazy)
+		c.Assert(err, IsNil)
+		rc := NewRefChecker(rdr, fixrefRef, true)
+		var out []string
+		var vars []*Variant
+		for v := rc.Read(); v != nil; v = rc.Read() {
+			out = append(out, v.String())
+			vars = append(vars, v)
+			c.Assert(v.Errors, HasLen, 0)
+		}
+		c.Assert(out[:5], DeepEquals, []string{
+			"1\t1\t.\tA\tG\t.\tPASS\tAC=1;AN=4;AF=0.25\tGT:AD\t0/1:3,7\t0/0:9,0",
+			"1\t2\t.\tC\tT\t.\tPASS\tAC=3;AN=4;AF=0.75\tGT:AD:PL\t1/0:7,3:20,10,0\t0|0:5,0:.",
+			"1\t3\t.\tG\tA\t.\tPASS\tAC=1\tGT\t0/1\t0/0",
+			"1\t4\t.\tT\tG\t.\tPASS\tAC=1\tGT:AD\t1/0:3,2\t./.:.",
+			"1\t5\t.\tG\tC\t.\tPASS\tAC=1\tGT\t0/1\t0/0",
+		})
+		if !lazy {
+			c.Assert(vars[1].Samples[0].GT, DeepEquals, []int{1, 0})
+			c.Assert(vars[1].Samples[1].GT, DeepEquals, []int{0, 0})
+			c.Assert(vars[1].Samples[0].GL, DeepEquals, []float64{-2, -1, 0})
+		}
+
+		r := rc.Report()
+		c.Assert(r.Total(), Equals, 8)
+		c.Assert(r.Fixed, Equals, 3)
+		c.Assert(r.String(), Equals, "total=8 match=2 swapped=1 flipped=1 flipped+swapped=1 mismatch=1 skipped=2 fixed=3")
+		c.Assert(r.Problems, HasLen, 4)
+		c.Assert(r.Problems[1], DeepEquals, RefProblem{Chromosome: "1", Pos: 3, Reference: "C", Alternate: []string{"T"}, Expected: "G", Status: RefFlipped, Fixed: true})
+		c.Assert(r.Problems[3].Fixed, Equals, false)
+		c.Assert(rc.Error(), ErrorMatches, "(?s).*no sequence for 2:0-1.*")
+	}
+}
+
+func (s *FixRefSuite) TestCheckOnly(c *C) {
+	rdr, err := NewReader(strings.NewReader(fixrefVCF), false)
+	c.Assert(err, IsNil)
+	rc := NewRefChecker(rdr, fixrefRef, false)
+	rdr.Read()
+	v := rc.Read()
+	c.Assert(v.Reference, Equals, "T")
+	c.Assert(v.Samples[0].GT, DeepEquals, []int{0, 1})
+	c.Assert(rc.Report().Problems, HasLen, 1)
+	c.Assert(rc.Report().Fixed, Equals, 0)
+	c.Assert(rc.Error(), IsNil)
+}
+
+// countingRef counts the calls to Fetch.
+type countingRef struct {
+	RefSeq
+	n int
+}
+
+func (r *countingRef) Fetch(chrom string, start, end int) ([]byte, error) {
+	r.n++
+	return r.RefSeq.Fetch(chrom, start, end)
+}
+
+func (s *FixRefSuite) TestReportLimit(c *C) {
+	defer func(n int) { maxRefProblems = n }(maxRefProblems)
+	maxRefProblems = 2
+	rdr, err := NewReader(strings.NewReader(fixrefVCF), false)
+	c.Assert(err, IsNil)
+	ref := &countingRef{RefSeq: fixrefRef}
+	rc := NewRefChecker(rdr, ref, true)
+	n := 0
+	for v := rc.Read(); v != nil; v = rc.Read() {
+		n++
+	}
+	// one fetch per record, the problems included.
+	c.Assert(ref.n, Equals, n)
+	r := rc.Report()
+	c.Assert(r.Problems, HasLen, 2)
+	c.Assert(r.Dropped, Equals, 2)
+	c.Assert(r.Fixed, Equals, 3)
+	c.Assert(r.Counts[RefMismatch], Equals, 1)
+}
+
+func (s *FixRefSuite) TestSwapGenotype(c *C) {
+	for gt, exp := range map[string]string{"0/1": "1/0", "1|1": "0|0", "./0": "./1", "0": "1", "2/0": "2/1", "10/1": "10/0"} {
+		c.Assert(swapGenotype(gt), Equals, exp)
+	}
+}
@@ -12,12 +12,15 @@ import (
 )
 
 var typeRe = `String|Integer|Float|Flag|Character|Unknown`
-var infoRegexp = regexp.MustCompile(fmt.Sprintf(`##INFO=<ID=(.+),Number=([\dAGR\.]*),Type=(%s),Description="(.*)">`, typeRe))
-var formatRegexp = regexp.MustCompile(fmt.Sprintf(`##FORMAT=<ID=(.+),Number=([\dAGR\.]*),Type=(%s),Description="(.*)">`, typeRe))
-var filterRegexp = regexp.MustCompile(`##FILTER=<ID=(.+),Description="(.*)">`)
+var typeRegexp = regexp.MustCompile(fmt.Sprintf(`^(%s)$`, typeRe))
+
+// numberRegexp also allows the local allele (LA, LR, LG), ploidy (P) and base
+// modification (M) numbers of VCF 4.4.
+var numberRegexp = regexp.MustCompile(`^([\dAGR\.]*|LA|LR|LG|P|M)$`)
 var contigRegexp = regexp.MustCompile(`contig=<.*((\w+)=([^,>]+))`)
-var sampleRegexp = regexp.MustCompile(`SAMPLE=<ID=([^,>]+)`)
-var pedRegexp = regexp.MustCompile(`PEDIGREE=<=([^,>]+)`)
+
+// BCF headers give dictionary entries an IDX.
+var idxRegexp = regexp.MustCompile(`,IDX=(\d+)`)
 
 // var headerIdRegexp = regexp.MustCompile(`##([^=]+)=<ID=([^,]+)`)
 var fileVersionRegexp = regexp.MustCompile(`##fileformat=VCFv(.+)`)
@@ -26,8 +29,10 @@ var fileVersionRegexp = regexp.MustCompile(`##fileformat=VCFv(.+)`)
 type Info struct {
 	Id          string
 	Description string
-	Number      string // A G R . ''
+	Number      string // A G R LA LR LG P M . ''
 	Type        string // STRING INTEGER FLOAT FLAG CHARACTER UNKONWN
+	// Extra holds the other attributes such as Source and Version.
+	Extra []Attr
 }
 
 // SampleFormat holds the type info for Format fields.
@@ -41,23 +46,60 @@ type Header struct {
 	Infos         map[string]*Info
 	SampleFormats map[string]*SampleFormat
 	Filters       map[string]string
-	Extras        []string
-	FileFormat    string
+	// Extras holds the unstructured ##key=value lines.
+	Extras     []string
+	FileFormat string
 	// Contigs is a list of maps of length, URL, etc.
 	Contigs []map[string]string
-	// ##SAMPLE
+	// Lines holds the other structured ##key=<...> lines such as ##ALT, ##META,
+	// ##SAMPLE and ##PEDIGREE in the order of the file.
+	Lines []*HeaderLine
+	// ##SAMPLE lines by ID and ##PEDIGREE lines as they were read. They are
+	// also in Lines, which takes precedence when the header is written.
 	Samples   map[string]string
 	Pedigrees []string
+	// PercentEncoding decides whether INFO and FORMAT values are percent-encoded.
+	// By default they are for VCF 4.3 and later.
+	PercentEncoding PercentEncoding
+
+	// selection, if set, limits the samples and fields decoded by ParseSamples.
+	selection *sampleSelection
+	// columns, once the samples were removed or added, holds the column in the
+	// input of each sample; see columnIDs. inputs is the number of sample
+	// columns in the input and nextColumn is the id of the next added sample.
+	columns            []int
+	inputs, nextColumn int
+
+	// order holds the lines that were read so they can be written back in place.
+	order []headerEntry
+}
+
+// headerEntry is a line of a header that was read. When the header is written
+// the raw line is kept unless the value it was parsed into has changed.
+type headerEntry struct {
+	// kind is INFO, FORMAT, FILTER, contig, line or extra.
+	kind, id string
+	raw      string
+	// rendered is the line as it would be written from its value when it was read.
+	rendered string
+	// keys is the order of the attributes of a contig line.
+	keys []string
+	line *HeaderLine
 }
 
 // String returns a string representation.
 func (i *Info) String() string {
-	return fmt.Sprintf("##INFO=<ID=%s,Number=%s,Type=%s,Description=\"%s\">", i.Id, i.Number, i.Type, i.Description)
+	return i.line("INFO").String()
 }
 
 // String returns a string representation.
 func (i *SampleFormat) String() string {
-	return fmt.Sprintf("##FORMAT=<ID=%s,Number=%s,Type=%s,Description=\"%s\">", i.Id, i.Number, i.Type, i.Description)
+	return (*Info)(i).line("FORMAT").String()
+}
+
+func (i *Info) line(key string) *HeaderLine {
+	attrs := append([]Attr{{"ID", i.Id, false}, {"Number", i.Number, false}, {"Type", i.Type, false}, {"Description", i.Description, true}}, i.Extra...)
+	return &HeaderLine{Key: key, Attrs: attrs}
 }
 
 /*
@@ -66,42 +108,66 @@ func (h *Header) Validate(verr *VCFError) []error {
 	return errs
 }*/
 
-func (h *Header) parseSample(format []string, s string) (*SampleGenotype, []error) {
+// parseSample returns the genotype of a sample column and any errors in it.
+// The Line and Sample of the errors are left to the caller.
+func (h *Header) parseSample(format []string, s string) (*SampleGenotype, []*ParseError) {
+	return h.parseSampleFields(format, s, nil)
+}
+
+// parseSampleFields is parseSample for only the fields in want, or all of them
+// if want is nil.
+func (h *Header) parseSampleFields(format []string, s string, want map[string]bool) (*SampleGenotype, []*ParseError) {
 	values := strings.Split(s, ":")
 	if len(format) != len(values) {
-		return NewSampleGenotype(), []error{fmt.Errorf("bad sample string: %s", s)}
+		return NewSampleGenotype(), []*ParseError{{Column: "FORMAT", Kind: ErrSampleFieldCount, Err: fmt.Errorf("bad sample string: %s", s)}}
 	}
 	//if geno == nil {
 	var value string
 	var geno = NewSampleGenotype()
-	var errs []error
+	var errs []*ParseError
 	//}
 	var e error
 
 	for i, field := range format {
-		value = values[i]
-		switch field {
-		case "GT":
-			e = h.setSampleGT(geno, value)
-		case "DP":
-			e = h.setSampleDP(geno, value)
-		case "GL":
-			e = h.setSampleGL(geno, value, false)
-		case "PL":
-			e = h.setSampleGL(geno, value, true)
-		case "GQ":
-			if format, ok := h.SampleFormats[field]; ok {
-				e = h.setSampleGQ(geno, value, format.Type)
-			}
+		if want != nil && !want[field] {
+			continue
 		}
-		geno.Fields[field] = value
+		value = values[i]
+		e = h.setSampleField(geno, field, value)
 		if e != nil {
-			errs = append(errs, e)
+			kind := ErrInvalidValue
+			if e == errGQFloat {
+				kind = ErrTypeMismatch
+			}
+			errs = append(errs, &ParseError{Column: "FORMAT", Key: field, Kind: kind, Err: e})
 		}
 	}
 	return geno, errs
 }
 
+// setSampleField sets field of geno to value and, for GT, DP, GL, PL and GQ, the
+// pre-parsed struct field.
+func (h *Header) setSampleField(geno *SampleGenotype, field, value string) error {
+	var e error
+	switch field {
+	case "GT":
+		geno.GT = geno.GT[:0]
+		e = h.setSampleGT(geno, value)
+	case "DP":
+		e = h.setSampleDP(geno, value)
+	case "GL":
+		e = h.setSampleGL(geno, value, false)
+	case "PL":
+		e = h.setSampleGL(geno, value, true)
+	case "GQ":
+		if format, ok := h.SampleFormats[field]; ok {
+			e = h.setSampleGQ(geno, value, format.Type)
+		}
+	}
+	geno.Fields[field] = value
+	return e
+}
+
 func (h *Header) setSampleDP(geno *SampleGenotype, value string) error {
 	var err error
 	geno.DP, err = strconv.Atoi(value)
@@ -111,6 +177,8 @@ func (h *Header) setSampleDP(geno *SampleGenotype, value string) error {
 	return err
 }
 
+var errGQFloat = errors.New("setSampleGQ: GQ reported as float. rounding to int")
+
 func (h *Header) setSampleGQ(geno *SampleGenotype, value string, Type string) error {
 	var err error
 	if Type == "Integer" {
@@ -119,7 +187,7 @@ func (h *Header) setSampleGQ(geno *SampleGenotype, value string, Type string) er
 		var v float64
 		v, err = strconv.ParseFloat(value, 32)
 		if err == nil {
-			err = errors.New("setSampleGQ: GQ reported as float. rounding to int")
+			err = errGQFloat
 			geno.GQ = int(math.Floor(v + 0.5))
 		}
 	}
@@ -141,6 +209,10 @@ func (h *Header) setSampleGL(geno *SampleGenotype, value string, isPL bool) erro
 	vals := strings.Split(value, ",")
 	var v float64
 	for _, val := range vals {
+		if val == "." {
+			geno.GL = append(geno.GL, math.NaN())
+			continue
+		}
 		v, err = strconv.ParseFloat(val, 64)
 		if isPL {
 			v /= -10.0
@@ -193,15 +265,34 @@ func NewHeader() *Header {
 }
 
 func parseHeaderInfo(info string) (*Info, error) {
-	res := infoRegexp.FindStringSubmatch(info)
-	if len(res) != 5 {
-		return nil, fmt.Errorf("INFO error: %s, %v", info, res)
+	return parseHeaderDefinition(info, "INFO")
+}
+
+// parseHeaderDefinition parses an INFO or FORMAT line.
+func parseHeaderDefinition(line, key string) (*Info, error) {
+	l, err := parseHeaderLine(line)
+	if err != nil || l.Key != key {
+		return nil, fmt.Errorf("%s error: %s", key, line)
 	}
 	var i Info
-	i.Id = res[1]
-	i.Number = res[2]
-	i.Type = res[3]
-	i.Description = res[4]
+	var seen int
+	for _, a := range l.Attrs {
+		switch a.Key {
+		case "ID":
+			i.Id, seen = a.Value, seen|1
+		case "Number":
+			i.Number, seen = a.Value, seen|2
+		case "Type":
+			i.Type, seen = a.Value, seen|4
+		case "Description":
+			i.Description, seen = a.Value, seen|8
+		default:
+			i.Extra = append(i.Extra, a)
+		}
+	}
+	if seen != 15 || i.Id == "" || !numberRegexp.MatchString(i.Number) || !typeRegexp.MatchString(i.Type) {
+		return nil, fmt.Errorf("%s error: %s", key, line)
+	}
 	return &i, nil
 }
 
@@ -233,33 +324,29 @@ func parseHeaderExtraKV(kv string) ([]string, error) {
 }
 
 func parseHeaderFormat(info string) (*SampleFormat, error) {
-	res := formatRegexp.FindStringSubmatch(info)
-	if len(res) != 5 {
-		return nil, fmt.Errorf("FORMAT error: %s", info)
-	}
-	var i SampleFormat
-	i.Id = res[1]
-	i.Number = res[2]
-	i.Type = res[3]
-	i.Description = res[4]
-	return &i, nil
+	i, err := parseHeaderDefinition(info, "FORMAT")
+	return (*SampleFormat)(i), err
 }
 
 func parseHeaderFilter(info string) ([]string, error) {
-	res := filterRegexp.FindStringSubmatch(info)
-	if len(res) != 3 {
+	l, err := parseHeaderLine(info)
+	if err != nil || l.Key != "FILTER" {
 		return nil, fmt.Errorf("FILTER error: %s", info)
 	}
-	return res[1:3], nil
+	id, ok := l.Get("ID")
+	desc, ok2 := l.Get("Description")
+	if !ok || !ok2 || id == "" {
+		return nil, fmt.Errorf("FILTER error: %s", info)
+	}
+	return []string{id, desc}, nil
 }
 
-// return just the sample id.
-func parseHeaderSample(line string) (string, error) {
-	res := sampleRegexp.FindStringSubmatch(line)
-	if len(res) != 2 {
-		return "", fmt.Errorf("error parsing ##SAMPLE")
+// stripIDX removes the IDX attribute that BCF adds to FILTER, INFO, FORMAT and contig lines.
+func stripIDX(line string) string {
+	if !strings.Contains(line, ",IDX=") {
+		return line
 	}
-	return res[1], nil
+	return idxRegexp.ReplaceAllString(line, "")
 }
 
 func parseHeaderFileVersion(format string) (string, error) {
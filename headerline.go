@@ -0,0 +1,177 @@
+package vcfgo
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Attr is one key=value pair of a structured header line.
+type Attr struct {
+	Key   string
+	Value string
+	// Quoted is true if the value is written in double quotes.
+	Quoted bool
+}
+
+// HeaderLine is a structured header line of the form ##Key=<k1=v1,k2="v 2",...>
+// such as ##ALT, ##META, ##SAMPLE and ##PEDIGREE. The attributes are kept in
+// the order of the file.
+type HeaderLine struct {
+	Key   string
+	Attrs []Attr
+}
+
+// Get returns the value of the attribute key and whether it was present.
+func (l *HeaderLine) Get(key string) (string, bool) {
+	for _, a := range l.Attrs {
+		if a.Key == key {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// ID returns the value of the ID attribute or "" if there is none.
+func (l *HeaderLine) ID() string {
+	id, _ := l.Get("ID")
+	return id
+}
+
+// Set changes the value of the attribute key or adds it at the end. A new
+// Description is quoted as the spec requires.
+func (l *HeaderLine) Set(key, value string) {
+	for i := range l.Attrs {
+		if l.Attrs[i].Key == key {
+			l.Attrs[i].Value = value
+			return
+		}
+	}
+	l.Attrs = append(l.Attrs, Attr{Key: key, Value: value, Quoted: key == "Description"})
+}
+
+// String returns the line as it appears in the header, without the newline.
+func (l *HeaderLine) String() string {
+	var b strings.Builder
+	b.WriteString("##")
+	b.WriteString(l.Key)
+	b.WriteString("=<")
+	for i, a := range l.Attrs {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(a.Key)
+		b.WriteByte('=')
+		if a.Quoted || !isList(a.Value) && strings.ContainsAny(a.Value, `,"<>`) {
+			b.WriteByte('"')
+			b.WriteString(quoteEscaper.Replace(a.Value))
+			b.WriteByte('"')
+		} else {
+			b.WriteString(a.Value)
+		}
+	}
+	b.WriteByte('>')
+	return b.String()
+}
+
+var quoteEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// isList reports whether an unquoted value is a [list, of, values].
+func isList(value string) bool {
+	return strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]") && !strings.ContainsAny(value, `"<>`)
+}
+
+// isStructured reports whether line is of the form ##key=<...>.
+func isStructured(line string) bool {
+	i := strings.Index(line, "=")
+	return strings.HasPrefix(line, "##") && i > 2 && strings.HasPrefix(line[i+1:], "<") && strings.HasSuffix(line, ">")
+}
+
+// parseHeaderLine parses a ##key=<...> line. Quoted values may contain commas
+// and escape a double quote or backslash with a backslash; other backslashes
+// are kept. As many files do not escape the quotes inside a Description, there
+// a quote only ends the value if it is followed by the end of the line or by
+// another attribute.
+func parseHeaderLine(line string) (*HeaderLine, error) {
+	if !isStructured(line) {
+		return nil, fmt.Errorf("header line is not of the form ##key=<...>: %s", line)
+	}
+	i := strings.Index(line, "=")
+	l := &HeaderLine{Key: line[2:i]}
+	s := line[i+2 : len(line)-1]
+	for len(s) > 0 {
+		eq := strings.IndexByte(s, '=')
+		if eq < 1 {
+			return nil, fmt.Errorf("header line has an attribute without a key or value: %s", line)
+		}
+		a := Attr{Key: strings.TrimSpace(s[:eq])}
+		s = s[eq+1:]
+		if strings.HasPrefix(s, `"`) {
+			var b strings.Builder
+			j := 1
+			for ; j < len(s) && (s[j] != '"' || a.Key == "Description" && !closesQuote(s[j+1:])); j++ {
+				if s[j] == '\\' && j+1 < len(s) && (s[j+1] == '"' || s[j+1] == '\\') {
+					j++
+				}
+				b.WriteByte(s[j])
+			}
+			if j == len(s) {
+				return nil, fmt.Errorf("header line has an unterminated quote: %s", line)
+			}
+			a.Value, a.Quoted, s = b.String(), true, s[j+1:]
+			if len(s) > 0 && s[0] != ',' {
+				return nil, fmt.Errorf("header line has text after a quoted value: %s", line)
+			}
+		} else {
+			// a [list, of, values] as in ##META may contain commas.
+			start := 0
+			if strings.HasPrefix(s, "[") {
+				if start = strings.IndexByte(s, ']'); start == -1 {
+					return nil, fmt.Errorf("header line has an unterminated list: %s", line)
+				}
+			}
+			j := len(s)
+			if k := strings.IndexByte(s[start:], ','); k != -1 {
+				j = start + k
+			}
+			a.Value, s = s[:j], s[j:]
+		}
+		s = strings.TrimPrefix(s, ",")
+		l.Attrs = append(l.Attrs, a)
+	}
+	return l, nil
+}
+
+// closesQuote reports whether rest, the text after a double quote, starts
+// with the end of the line or with another key=value attribute.
+func closesQuote(rest string) bool {
+	return rest == "" || attrStartRegexp.MatchString(rest)
+}
+
+var attrStartRegexp = regexp.MustCompile(`^,\s*\w+=`)
+
+// LinesOf returns the structured header lines with the given key, e.g. "ALT" or "SAMPLE".
+func (h *Header) LinesOf(key string) []*HeaderLine {
+	var lines []*HeaderLine
+	for _, l := range h.Lines {
+		if l.Key == key {
+			lines = append(lines, l)
+		}
+	}
+	return lines
+}
+
+// AltAlleles returns the ##ALT lines that describe symbolic alleles such as <DEL>.
+func (h *Header) AltAlleles() []*HeaderLine {
+	return h.LinesOf("ALT")
+}
+
+// Meta returns the ##META line with the given ID or nil.
+func (h *Header) Meta(id string) *HeaderLine {
+	for _, l := range h.LinesOf("META") {
+		if l.ID() == id {
+			return l
+		}
+	}
+	return nil
+}
@@ -0,0 +1,221 @@
+package vcfgo
+
+import (
+	"bytes"
+	"os"
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+type HeaderLineSuite struct{}
+
+var _ = Suite(&HeaderLineSuite{})
+
+const structuredHeader = `##fileformat=VCFv4.3
+##ALT=<ID=DEL,Description="Deletion relative to the reference">
+##ALT=<ID=INS:ME,Description="Insertion of a mobile element, e.g. \"ALU\"">
+##META=<ID=Assay,Type=String,Number=.,Values=[WholeGenome, Exome]>
+##META=<ID=Disease,Type=String,Number=.,Values=[None, Cancer]>
+##SAMPLE=<ID=S1,Assay=WholeGenome,Disease=Cancer>
+##PEDIGREE=<ID=S1,Father=F,Mother=M>
+##GATKCommandLine=<ID=HaplotypeCaller,CommandLine="HaplotypeCaller -I in.bam --max-alt 6",Version=4.1>
+##source=test
+#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO	FORMAT	S1
+`
+
+func (s *HeaderLineSuite) TestParse(c *C) {
+	l, err := parseHeaderLine(`##ALT=<ID=INS:ME,Description="Insertion, \"ALU\" or \\L1">`)
+	c.Assert(err, IsNil)
+	c.Assert(l.Key, Equals, "ALT")
+	c.Assert(l.Attrs, DeepEquals, []Attr{{"ID", "INS:ME", false}, {"Description", `Insertion, "ALU" or \L1`, true}})
+	c.Assert(l.String(), Equals, `##ALT=<ID=INS:ME,Description="Insertion, \"ALU\" or \\L1">`)
+
+	l, err = parseHeaderLine(`##META=<ID=Assay,Values=[WholeGenome, Exome],Number=.>`)
+	c.Assert(err, IsNil)
+	v, ok := l.Get("Values")
+	c.Assert(ok, Equals, true)
+	c.Assert(v, Equals, "[WholeGenome, Exome]")
+	c.Assert(l.String(), Equals, `##META=<ID=Assay,Values=[WholeGenome, Exome],Number=.>`)
+
+	// unescaped quotes inside a Description are kept.
+	l, err = parseHeaderLine(`##INFO=<ID=Q,Number=1,Type=String,Description="the "quoted", word",Source="x">`)
+	c.Assert(err, IsNil)
+	c.Assert(l.Attrs[3], DeepEquals, Attr{"Description", `the "quoted", word`, true})
+	c.Assert(l.Attrs[4], DeepEquals, Attr{"Source", "x", true})
+
+	for _, bad := range []string{
+		`##ALT=<ID=DEL,Description="Deletion>`,
+		`##ALT=<ID=DEL,Description="a"b>`,
+		`##ALT=<ID>`,
+		`##META=<ID=Assay,Values=[a, b>`,
+		`##source=x`,
+	} {
+		_, err := parseHeaderLine(bad)
+		c.Assert(err, Not(IsNil), Commentf(bad))
+	}
+}
+
+func (s *HeaderLineSuite) TestInnerQuotes(c *C) {
+	rdr, err := NewReader(strings.NewReader(`##fileformat=VCFv4.2
+##INFO=<ID=Q,Number=1,Type=String,Description="the "quoted" word">
+#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO
+1	1	.	A	C	.	.	Q=x
+`), false)
+	c.Assert(err, IsNil)
+	c.Assert(rdr.Header.Infos["Q"], Not(IsNil))
+	c.Assert(rdr.Header.Infos["Q"].Description, Equals, `the "quoted" word`)
+	q, err := rdr.Read().Info().Get("Q")
+	c.Assert(err, IsNil)
+	c.Assert(q, Equals, "x")
+}
+
+func (s *HeaderLineSuite) TestSet(c *C) {
+	l := &HeaderLine{Key: "ALT"}
+	l.Set("ID", "DUP")
+	l.Set("Description", "Duplication")
+	l.Set("Note", "a,b")
+	c.Assert(l.String(), Equals, `##ALT=<ID=DUP,Description="Duplication",Note="a,b">`)
+	l.Set("ID", "DUP:TANDEM")
+	c.Assert(l.ID(), Equals, "DUP:TANDEM")
+	_, ok := l.Get("Other")
+	c.Assert(ok, Equals, false)
+}
+
+func (s *HeaderLineSuite) TestReader(c *C) {
+	rdr, err := NewReader(strings.NewReader(structuredHeader), false)
+	c.Assert(err, IsNil)
+	h := rdr.Header
+	c.Assert(h.Lines, HasLen, 7)
+	c.Assert(h.Extras, DeepEquals, []string{"##source=test"})
+
+	alts := h.AltAlleles()
+	c.Assert(alts, HasLen, 2)
+	c.Assert(alts[1].ID(), Equals, "INS:ME")
+	d, _ := alts[1].Get("Description")
+	c.Assert(d, Equals, `Insertion of a mobile element, e.g. "ALU"`)
+
+	c.Assert(h.Meta("Disease"), Equals, h.Lines[3])
+	c.Assert(h.Meta("Other"), IsNil)
+	c.Assert(h.LinesOf("PEDIGREE")[0].ID(), Equals, "S1")
+	cmd, _ := h.LinesOf("GATKCommandLine")[0].Get("CommandLine")
+	c.Assert(cmd, Equals, "HaplotypeCaller -I in.bam --max-alt 6")
+
+	// the raw fields are still filled.
+	c.Assert(h.Samples, HasLen, 1)
+	c.Assert(h.Pedigrees, HasLen, 1)
+}
+
+func (s *HeaderLineSuite) TestWrite(c *C) {
+	rdr, err := NewReader(strings.NewReader(structuredHeader), false)
+	c.Assert(err, IsNil)
+	h := rdr.Header
+	h.Meta("Assay").Set("Values", "[WholeGenome, Exome, RNA]")
+	h.LinesOf("SAMPLE")[0].Set("Assay", "RNA")
+	h.Lines = append(h.Lines, &HeaderLine{Key: "ALT", Attrs: []Attr{{"ID", "DUP", false}, {"Description", "Duplication", true}}})
+
+	var out bytes.Buffer
+	_, err = NewWriter(&out, h)
+	c.Assert(err, IsNil)
+	lines := strings.Split(out.String(), "\n")
+	c.Assert(lines[1:10], DeepEquals, []string{
+		`##ALT=<ID=DEL,Description="Deletion relative to the reference">`,
+		`##ALT=<ID=INS:ME,Description="Insertion of a mobile element, e.g. \"ALU\"">`,
+		`##META=<ID=Assay,Type=String,Number=.,Values=[WholeGenome, Exome, RNA]>`,
+		`##META=<ID=Disease,Type=String,Number=.,Values=[None, Cancer]>`,
+		`##SAMPLE=<ID=S1,Assay=RNA,Disease=Cancer>`,
+		`##PEDIGREE=<ID=S1,Father=F,Mother=M>`,
+		`##GATKCommandLine=<ID=HaplotypeCaller,CommandLine="HaplotypeCaller -I in.bam --max-alt 6",Version=4.1>`,
+		`##source=test`,
+		`##ALT=<ID=DUP,Description="Duplication">`,
+	})
+
+	rdr, err = NewReader(strings.NewReader(out.String()), false)
+	c.Assert(err, IsNil)
+	c.Assert(rdr.Header.Lines, DeepEquals, h.Lines)
+}
+
+// headerOf returns the header lines of a VCF, including the #CHROM line.
+func headerOf(text string) string {
+	i := strings.Index(text, "\n#CHROM")
+	j := strings.Index(text[i+1:], "\n")
+	return text[:i+1+j+1]
+}
+
+func (s *HeaderLineSuite) TestRoundTrip(c *C) {
+	for _, path := range []string{"test-weird-header.vcf", "test-h.vcf", "test-dp.vcf", "test-multi-allelic.vcf", "test-issue-20.vcf", "examples/test.query.vcf", "examples/test.auto_dom.no_parents.vcf"} {
+		data, err := os.ReadFile(path)
+		c.Assert(err, IsNil)
+		rdr, err := NewReader(bytes.NewReader(data), false)
+		c.Assert(rdr, Not(IsNil), Commentf("%s: %v", path, err))
+		var out bytes.Buffer
+		_, err = NewWriter(&out, rdr.Header)
+		c.Assert(err, IsNil)
+		c.Assert(out.String(), Equals, headerOf(string(data)), Commentf(path))
+	}
+}
+
+func (s *HeaderLineSuite) TestKeepIDX(c *C) {
+	// the IDX of a text header is kept.
+	rdr, err := NewReader(strings.NewReader(bcfTestHeader), false)
+	c.Assert(err, IsNil)
+	var out bytes.Buffer
+	_, err = NewWriter(&out, rdr.Header)
+	c.Assert(err, IsNil)
+	c.Assert(out.String(), Equals, bcfTestHeader)
+
+	// that of a BCF header is not.
+	rdr, err = NewReader(bytes.NewReader(bcfTestFile(bcfTestRecords())), false)
+	c.Assert(err, IsNil)
+	out.Reset()
+	_, err = NewWriter(&out, rdr.Header)
+	c.Assert(err, IsNil)
+	c.Assert(out.String(), Equals, stripIDX(bcfTestHeader))
+}
+
+const orderedHeader = `##fileformat=VCFv4.2
+##source=test
+##INFO=<ID=DP,Number=1,Type=Integer,Description="Depth",Source="caller",Version="1.2">
+##contig=<ID=1,length=249250621,assembly=b37>
+##FILTER=<ID=q10,Description="Quality below 10",Source=gatk>
+##INFO=<ID=AF,Number=A,Type=Float,Description="Allele frequency">
+##ALT=<ID=DEL,Description="Deletion">
+##contig=<ID=2,length=243199373,assembly=b37>
+##FORMAT=<ID=GT,Number=1,Type=String,Description="Genotype">
+##reference=file:///ref.fa
+#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO	FORMAT	S1
+`
+
+func (s *HeaderLineSuite) TestModified(c *C) {
+	rdr, err := NewReader(strings.NewReader(orderedHeader), false)
+	c.Assert(err, IsNil)
+	h := rdr.Header
+	c.Assert(h.Infos["DP"].Extra, DeepEquals, []Attr{{"Source", "caller", true}, {"Version", "1.2", true}})
+	c.Assert(h.Infos["DP"].Description, Equals, "Depth")
+
+	h.Infos["DP"].Description = "Total depth"
+	h.Filters["q10"] = "Quality < 10"
+	h.Contigs[1]["length"] = "243199374"
+	delete(h.Infos, "AF")
+	h.Extras = h.Extras[1:]
+	h.Infos["AN"] = &Info{Id: "AN", Number: "1", Type: "Integer", Description: "Allele number"}
+	h.Contigs = append(h.Contigs, map[string]string{"ID": "3", "length": "10", "assembly": "b37"})
+	h.Filters["LowQual"] = "Low quality"
+
+	var out bytes.Buffer
+	_, err = NewWriter(&out, h)
+	c.Assert(err, IsNil)
+	c.Assert(out.String(), Equals, `##fileformat=VCFv4.2
+##INFO=<ID=DP,Number=1,Type=Integer,Description="Total depth",Source="caller",Version="1.2">
+##contig=<ID=1,length=249250621,assembly=b37>
+##FILTER=<ID=q10,Description="Quality < 10",Source=gatk>
+##ALT=<ID=DEL,Description="Deletion">
+##contig=<ID=2,length=243199374,assembly=b37>
+##FORMAT=<ID=GT,Number=1,Type=String,Description="Genotype">
+##reference=file:///ref.fa
+##contig=<ID=3,assembly=b37,length=10>
+##FILTER=<ID=LowQual,Description="Low quality">
+##INFO=<ID=AN,Number=1,Type=Integer,Description="Allele number">
+#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO	FORMAT	S1
+`)
+}
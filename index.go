@@ -0,0 +1,716 @@
+package vcfgo
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// tabix uses a fixed binning scheme: 16kb leaf bins and 5 levels above them.
+// That limits it to positions below 2^29; CSI makes the scheme configurable.
+const (
+	tabixMinShift = 14
+	tabixDepth    = 5
+)
+
+// tabix configuration for VCF: the preset, the sequence, begin and end columns, the comment character and lines to skip.
+var vcfTabixConf = [6]int32{2, 1, 2, 0, '#', 0}
+
+// IndexFormat is the on-disk format of an Index.
+type IndexFormat int
+
+const (
+	// TBI is the tabix format.
+	TBI IndexFormat = iota + 1
+	// CSI is the coordinate-sorted index format which allows a configurable
+	// binning scheme and so longer sequences.
+	CSI
+)
+
+// String returns a string representation.
+func (f IndexFormat) String() string {
+	switch f {
+	case TBI:
+		return "tbi"
+	case CSI:
+		return "csi"
+	}
+	return fmt.Sprintf("IndexFormat(%d)", int(f))
+}
+
+// Index is a binning index over a BGZF compressed VCF that maps a region to the
+// chunks of the file that may hold records overlapping it.
+type Index struct {
+	Format IndexFormat
+	// MinShift and Depth describe the binning scheme.
+	MinShift int
+	Depth    int
+	// Names holds the sequence names in the order of the references in the index.
+	// A CSI index over BCF does not store names; the header contigs give the order instead.
+	Names []string
+
+	// conf is the tabix configuration of the indexed columns.
+	conf [6]int32
+
+	refs  []refIndex
+	names map[string]int
+	// NoCoor is the number of records without a coordinate if it was recorded.
+	NoCoor    uint64
+	hasNoCoor bool
+}
+
+type chunk struct {
+	beg, end VirtualOffset
+}
+
+type bin struct {
+	// loffset is the lowest offset of a record in or below this bin. It is only stored by CSI.
+	loffset VirtualOffset
+	chunks  []chunk
+}
+
+type refIndex struct {
+	bins map[uint32]*bin
+	// intervals is the linear index of tabix: the lowest offset of a record overlapping each 16kb window.
+	intervals []VirtualOffset
+}
+
+// maxPos returns the first position that the binning scheme can not index.
+func (idx *Index) maxPos() int64 {
+	return 1 << uint(idx.MinShift+idx.Depth*3)
+}
+
+// reg2bins returns the bins that may hold records overlapping the 0-based half-open region [beg, end).
+func reg2bins(beg, end int64, minShift, depth int) []uint32 {
+	if end <= beg {
+		end = beg + 1
+	}
+	end--
+	var bins []uint32
+	s := uint(minShift + depth*3)
+	t := int64(0)
+	for l := 0; l <= depth; l++ {
+		b, e := t+(beg>>s), t+(end>>s)
+		for i := b; i <= e; i++ {
+			bins = append(bins, uint32(i))
+		}
+		s -= 3
+		t += 1 << (uint(l) * 3)
+	}
+	return bins
+}
+
+// reg2bin returns the smallest bin that holds the whole region [beg, end).
+func reg2bin(beg, end int64, minShift, depth int) uint32 {
+	if end <= beg {
+		end = beg + 1
+	}
+	end--
+	s := uint(minShift)
+	t := int64(((1 << (uint(depth) * 3)) - 1) / 7)
+	for l := depth; l > 0; l-- {
+		if beg>>s == end>>s {
+			return uint32(t + (beg >> s))
+		}
+		s += 3
+		t -= 1 << (uint(l-1) * 3)
+	}
+	return 0
+}
+
+// RefID returns the index of the sequence named chrom or -1 if it is not in the index.
+func (idx *Index) RefID(chrom string) int {
+	if idx.names == nil {
+		idx.names = make(map[string]int, len(idx.Names))
+		for i, n := range idx.Names {
+			idx.names[n] = i
+		}
+	}
+	if i, ok := idx.names[chrom]; ok && i < len(idx.refs) {
+		return i
+	}
+	return -1
+}
+
+// minOffset returns the lowest offset of a record that may overlap a region starting at beg.
+func (idx *Index) minOffset(r *refIndex, beg int64) VirtualOffset {
+	if idx.Format == TBI {
+		n := len(r.intervals)
+		if n == 0 {
+			return 0
+		}
+		if i := beg >> tabixMinShift; i < int64(n) {
+			return r.intervals[i]
+		}
+		return r.intervals[n-1]
+	}
+	// as htslib, use the closest bin at or to the left of beg on the leaf level, moving up a level when there is none.
+	b := binFirst(idx.Depth) + uint32(beg>>uint(idx.MinShift))
+	for b != 0 {
+		if bn, ok := r.bins[b]; ok {
+			return bn.loffset
+		}
+		if first := (binParent(b) << 3) + 1; b > first {
+			b--
+		} else {
+			b = binParent(b)
+		}
+	}
+	if bn, ok := r.bins[0]; ok {
+		return bn.loffset
+	}
+	return 0
+}
+
+// binFirst returns the first bin on the leaf level of a scheme with the given depth.
+func binFirst(depth int) uint32 {
+	return uint32(((1 << (uint(depth) * 3)) - 1) / 7)
+}
+
+func binParent(b uint32) uint32 {
+	return (b - 1) >> 3
+}
+
+// chunks returns the sorted, merged chunks that may hold records overlapping [beg, end) on ref.
+func (idx *Index) chunks(ref int, beg, end int64) []chunk {
+	if ref < 0 || ref >= len(idx.refs) || beg >= idx.maxPos() {
+		return nil
+	}
+	if end > idx.maxPos() {
+		end = idx.maxPos()
+	}
+	r := &idx.refs[ref]
+	minOff := idx.minOffset(r, beg)
+	var chunks []chunk
+	for _, b := range reg2bins(beg, end, idx.MinShift, idx.Depth) {
+		bn, ok := r.bins[b]
+		if !ok {
+			continue
+		}
+		for _, c := range bn.chunks {
+			if c.end > minOff {
+				// records before minOff end before the region.
+				if c.beg < minOff {
+					c.beg = minOff
+				}
+				chunks = append(chunks, c)
+			}
+		}
+	}
+	if len(chunks) == 0 {
+		return nil
+	}
+	sort.Slice(chunks, func(i, j int) bool { return chunks[i].beg < chunks[j].beg })
+	merged := chunks[:1]
+	for _, c := range chunks[1:] {
+		last := &merged[len(merged)-1]
+		if c.beg <= last.end {
+			if c.end > last.end {
+				last.end = c.end
+			}
+			continue
+		}
+		merged = append(merged, c)
+	}
+	return merged
+}
+
+// OpenIndex reads the index at path.
+func OpenIndex(path string) (*Index, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadIndex(f)
+}
+
+// ReadIndex reads a tabix or CSI index from r. The index may be BGZF compressed,
+// as written by tabix and bcftools, or not.
+func ReadIndex(r io.Reader) (*Index, error) {
+	buf := bufio.NewReader(r)
+	if detectCompression(buf) != CompressionNone {
+		gz, err := gzip.NewReader(buf)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		buf = bufio.NewReader(gz)
+	}
+	magic, err := buf.Peek(4)
+	if err != nil {
+		return nil, fmt.Errorf("vcfgo: error reading index: %w", err)
+	}
+	switch {
+	case bytes.Equal(magic, []byte("TBI\x01")):
+		return readTabix(buf)
+	case bytes.Equal(magic, []byte("CSI\x01")):
+		return readCSI(buf)
+	}
+	return nil, fmt.Errorf("vcfgo: unknown index format: %q", magic)
+}
+
+// indexReader reads little-endian values, remembering the first error.
+type indexReader struct {
+	r   io.Reader
+	err error
+	b   [8]byte
+}
+
+func (ir *indexReader) read(n int) []byte {
+	if ir.err != nil {
+		return ir.b[:n]
+	}
+	if _, ir.err = io.ReadFull(ir.r, ir.b[:n]); ir.err == io.EOF {
+		ir.err = io.ErrUnexpectedEOF
+	}
+	return ir.b[:n]
+}
+
+func (ir *indexReader) int32() int32 {
+	return int32(binary.LittleEndian.Uint32(ir.read(4)))
+}
+
+func (ir *indexReader) uint32() uint32 {
+	return binary.LittleEndian.Uint32(ir.read(4))
+}
+
+func (ir *indexReader) uint64() uint64 {
+	return binary.LittleEndian.Uint64(ir.read(8))
+}
+
+func (ir *indexReader) bytes(n int32) []byte {
+	if ir.err != nil {
+		return nil
+	}
+	if n < 0 {
+		ir.err = fmt.Errorf("negative length: %d", n)
+		return nil
+	}
+	b := make([]byte, n)
+	if _, ir.err = io.ReadFull(ir.r, b); ir.err == io.EOF {
+		ir.err = io.ErrUnexpectedEOF
+	}
+	return b
+}
+
+// count reads an int32 length and checks that it is sane.
+func (ir *indexReader) count() int {
+	n := ir.int32()
+	if ir.err == nil && n < 0 {
+		ir.err = fmt.Errorf("negative count: %d", n)
+	}
+	if ir.err != nil {
+		return 0
+	}
+	return int(n)
+}
+
+// readBins reads the bins of a single reference. CSI stores an loffset with each bin.
+func (ir *indexReader) readBins(csi bool) map[uint32]*bin {
+	nBin := ir.count()
+	bins := make(map[uint32]*bin, nBin)
+	for i := 0; i < nBin && ir.err == nil; i++ {
+		b := &bin{}
+		id := ir.uint32()
+		if csi {
+			b.loffset = VirtualOffset(ir.uint64())
+		}
+		b.chunks = ir.readChunks()
+		bins[id] = b
+	}
+	return bins
+}
+
+func (ir *indexReader) readChunks() []chunk {
+	n := ir.count()
+	chunks := make([]chunk, 0, n)
+	for j := 0; j < n && ir.err == nil; j++ {
+		beg := VirtualOffset(ir.uint64())
+		chunks = append(chunks, chunk{beg, VirtualOffset(ir.uint64())})
+	}
+	return chunks
+}
+
+// readNames splits the NUL terminated sequence names stored by tabix.
+func readNames(b []byte) []string {
+	var names []string
+	for len(b) > 0 {
+		i := bytes.IndexByte(b, 0)
+		if i == -1 {
+			i = len(b)
+		}
+		names = append(names, string(b[:i]))
+		if i == len(b) {
+			break
+		}
+		b = b[i+1:]
+	}
+	return names
+}
+
+// readConf reads the tabix configuration and the sequence names that follow it.
+func (ir *indexReader) readConf(idx *Index) {
+	for i := range idx.conf {
+		idx.conf[i] = ir.int32()
+	}
+	idx.Names = readNames(ir.bytes(ir.int32()))
+}
+
+// readNoCoor reads the optional count of records without a coordinate.
+func (ir *indexReader) readNoCoor(idx *Index) {
+	if n := ir.uint64(); ir.err == nil {
+		idx.NoCoor, idx.hasNoCoor = n, true
+	}
+}
+
+func readTabix(r io.Reader) (*Index, error) {
+	ir := &indexReader{r: r}
+	ir.read(4)
+	idx := &Index{Format: TBI, MinShift: tabixMinShift, Depth: tabixDepth}
+	nRef := ir.count()
+	ir.readConf(idx)
+	if ir.err == nil && len(idx.Names) != nRef {
+		return nil, fmt.Errorf("vcfgo: tabix index has %d names for %d references", len(idx.Names), nRef)
+	}
+
+	idx.refs = make([]refIndex, nRef)
+	for i := 0; i < nRef && ir.err == nil; i++ {
+		idx.refs[i].bins = ir.readBins(false)
+		nIntv := ir.count()
+		intervals := make([]VirtualOffset, 0, nIntv)
+		for j := 0; j < nIntv && ir.err == nil; j++ {
+			intervals = append(intervals, VirtualOffset(ir.uint64()))
+		}
+		idx.refs[i].intervals = intervals
+	}
+	if ir.err != nil {
+		return nil, fmt.Errorf("vcfgo: error reading tabix index: %w", ir.err)
+	}
+	ir.readNoCoor(idx)
+	return idx, nil
+}
+
+func readCSI(r io.Reader) (*Index, error) {
+	ir := &indexReader{r: r}
+	ir.read(4)
+	idx := &Index{Format: CSI}
+	idx.MinShift, idx.Depth = int(ir.int32()), int(ir.int32())
+	if ir.err == nil && (idx.MinShift < 0 || idx.Depth < 0 || idx.MinShift+idx.Depth*3 > 62) {
+		return nil, fmt.Errorf("vcfgo: invalid CSI binning scheme: min_shift=%d depth=%d", idx.MinShift, idx.Depth)
+	}
+	// bcftools stores the tabix configuration and names in the auxiliary data
+	// when indexing VCF and leaves it empty for BCF.
+	aux := ir.bytes(ir.int32())
+	if len(aux) > 0 {
+		ar := &indexReader{r: bytes.NewReader(aux)}
+		ar.readConf(idx)
+		if ar.err != nil {
+			return nil, fmt.Errorf("vcfgo: error reading CSI auxiliary data: %w", ar.err)
+		}
+	}
+	nRef := ir.count()
+	if ir.err == nil && len(idx.Names) > 0 && len(idx.Names) != nRef {
+		return nil, fmt.Errorf("vcfgo: CSI index has %d names for %d references", len(idx.Names), nRef)
+	}
+	idx.refs = make([]refIndex, nRef)
+	for i := 0; i < nRef && ir.err == nil; i++ {
+		idx.refs[i].bins = ir.readBins(true)
+	}
+	if ir.err != nil {
+		return nil, fmt.Errorf("vcfgo: error reading CSI index: %w", ir.err)
+	}
+	ir.readNoCoor(idx)
+	return idx, nil
+}
+
+// WriteTo writes the index, BGZF compressed, in its Format.
+func (idx *Index) WriteTo(w io.Writer) (int64, error) {
+	cw := &countWriter{w: w}
+	bw := NewBGZFWriter(cw)
+	iw := &indexWriter{w: bw}
+	switch idx.Format {
+	case TBI:
+		iw.write([]byte("TBI\x01"))
+		iw.int32(int32(len(idx.refs)))
+		iw.writeConf(idx)
+	case CSI:
+		iw.write([]byte("CSI\x01"))
+		iw.int32(int32(idx.MinShift))
+		iw.int32(int32(idx.Depth))
+		if len(idx.Names) > 0 {
+			var aux bytes.Buffer
+			(&indexWriter{w: &aux}).writeConf(idx)
+			iw.int32(int32(aux.Len()))
+			iw.write(aux.Bytes())
+		} else {
+			iw.int32(0)
+		}
+		iw.int32(int32(len(idx.refs)))
+	default:
+		return 0, fmt.Errorf("vcfgo: unknown index format: %s", idx.Format)
+	}
+	for i := range idx.refs {
+		r := &idx.refs[i]
+		ids := make([]uint32, 0, len(r.bins))
+		for id := range r.bins {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		iw.int32(int32(len(ids)))
+		for _, id := range ids {
+			b := r.bins[id]
+			iw.uint32(id)
+			if idx.Format == CSI {
+				iw.uint64(uint64(b.loffset))
+			}
+			iw.int32(int32(len(b.chunks)))
+			for _, c := range b.chunks {
+				iw.uint64(uint64(c.beg))
+				iw.uint64(uint64(c.end))
+			}
+		}
+		if idx.Format == TBI {
+			iw.int32(int32(len(r.intervals)))
+			for _, o := range r.intervals {
+				iw.uint64(uint64(o))
+			}
+		}
+	}
+	if idx.hasNoCoor {
+		iw.uint64(idx.NoCoor)
+	}
+	if iw.err == nil {
+		iw.err = bw.Close()
+	}
+	return cw.n, iw.err
+}
+
+// indexWriter writes little-endian values, remembering the first error.
+type indexWriter struct {
+	w   io.Writer
+	err error
+	b   [8]byte
+}
+
+func (iw *indexWriter) write(p []byte) {
+	if iw.err == nil {
+		_, iw.err = iw.w.Write(p)
+	}
+}
+
+func (iw *indexWriter) int32(v int32) {
+	iw.uint32(uint32(v))
+}
+
+func (iw *indexWriter) uint32(v uint32) {
+	binary.LittleEndian.PutUint32(iw.b[:4], v)
+	iw.write(iw.b[:4])
+}
+
+func (iw *indexWriter) uint64(v uint64) {
+	binary.LittleEndian.PutUint64(iw.b[:8], v)
+	iw.write(iw.b[:8])
+}
+
+// writeConf writes the tabix configuration followed by the NUL terminated names.
+func (iw *indexWriter) writeConf(idx *Index) {
+	for _, v := range idx.conf {
+		iw.int32(v)
+	}
+	var n int32
+	for _, name := range idx.Names {
+		n += int32(len(name)) + 1
+	}
+	iw.int32(n)
+	for _, name := range idx.Names {
+		iw.write(append([]byte(name), 0))
+	}
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// IndexBuilder builds an Index from the records of a sorted, BGZF compressed
+// file as they are written.
+type IndexBuilder struct {
+	idx *Index
+	// intervals is the linear index of the current reference.
+	intervals []VirtualOffset
+	// refStart is the offset of the first record on the current reference.
+	refStart VirtualOffset
+	lastEnd  VirtualOffset
+	nRecords uint64
+
+	chrom string
+	beg   int64
+	seen  map[string]bool
+	done  bool
+
+	// contigs is set for BCF, whose index has no names: references are
+	// numbered by the contig dictionary of the header instead.
+	contigs map[string]int
+}
+
+// NewIndexBuilder returns an IndexBuilder for format. minShift and depth set the binning
+// scheme of a CSI index; when they are 0, or for TBI, the tabix scheme is used.
+func NewIndexBuilder(format IndexFormat, minShift, depth int) (*IndexBuilder, error) {
+	if format == TBI || minShift == 0 && depth == 0 {
+		minShift, depth = tabixMinShift, tabixDepth
+	}
+	if format != TBI && format != CSI {
+		return nil, fmt.Errorf("vcfgo: unknown index format: %s", format)
+	}
+	if minShift <= 0 || depth <= 0 || minShift+depth*3 > 62 {
+		return nil, fmt.Errorf("vcfgo: invalid binning scheme: min_shift=%d depth=%d", minShift, depth)
+	}
+	idx := &Index{Format: format, MinShift: minShift, Depth: depth, conf: vcfTabixConf}
+	return &IndexBuilder{idx: idx, seen: make(map[string]bool)}, nil
+}
+
+// check returns an error if a record at chrom:beg can not follow the previous one.
+func (b *IndexBuilder) check(chrom string, beg, end int64) error {
+	if beg < 0 || end > b.idx.maxPos() {
+		return fmt.Errorf("vcfgo: index: %s:%d-%d is outside the range of the %s binning scheme", chrom, beg+1, end, b.idx.Format)
+	}
+	if chrom == b.chrom {
+		if beg < b.beg {
+			return fmt.Errorf("vcfgo: index: records are not sorted: %s:%d follows %s:%d", chrom, beg+1, chrom, b.beg+1)
+		}
+		return nil
+	}
+	if b.seen[chrom] {
+		return fmt.Errorf("vcfgo: index: records are not sorted: %s appears again after %s", chrom, b.chrom)
+	}
+	if b.contigs != nil {
+		id, ok := b.contigs[chrom]
+		if !ok {
+			return fmt.Errorf("vcfgo: index: %s is not a contig in the header", chrom)
+		}
+		if id < len(b.idx.refs) {
+			return fmt.Errorf("vcfgo: index: records are not sorted: %s follows %s but comes before it in the header", chrom, b.chrom)
+		}
+	}
+	return nil
+}
+
+// Add records that the record on chrom covering the 0-based half-open region
+// [beg, end) is stored in the file from start up to stop.
+func (b *IndexBuilder) Add(chrom string, beg, end int64, start, stop VirtualOffset) error {
+	if b.done {
+		return errors.New("vcfgo: index: Add called after Index")
+	}
+	if end <= beg {
+		end = beg + 1
+	}
+	if err := b.check(chrom, beg, end); err != nil {
+		return err
+	}
+	idx := b.idx
+	if chrom != b.chrom {
+		b.finishRef()
+		b.chrom = chrom
+		b.seen[chrom] = true
+		b.refStart = start
+		b.nRecords = 0
+		if b.contigs != nil {
+			b.pad(b.contigs[chrom])
+		} else {
+			idx.Names = append(idx.Names, chrom)
+		}
+		idx.refs = append(idx.refs, refIndex{bins: make(map[uint32]*bin)})
+	}
+	b.beg = beg
+	b.lastEnd = stop
+	b.nRecords++
+
+	r := &idx.refs[len(idx.refs)-1]
+	id := reg2bin(beg, end, idx.MinShift, idx.Depth)
+	bn, ok := r.bins[id]
+	if !ok {
+		bn = &bin{loffset: start}
+		r.bins[id] = bn
+	}
+	if n := len(bn.chunks); n > 0 && bn.chunks[n-1].end == start {
+		bn.chunks[n-1].end = stop
+	} else {
+		bn.chunks = append(bn.chunks, chunk{start, stop})
+	}
+	for w := beg >> uint(idx.MinShift); w <= (end-1)>>uint(idx.MinShift); w++ {
+		for int64(len(b.intervals)) <= w {
+			b.intervals = append(b.intervals, 0)
+		}
+		if b.intervals[w] == 0 {
+			b.intervals[w] = start
+		}
+	}
+	return nil
+}
+
+// finishRef completes the linear index of the current reference and adds the
+// pseudo-bin that htslib uses to store the extent of the reference and its record count.
+func (b *IndexBuilder) finishRef() {
+	idx := b.idx
+	if len(idx.refs) == 0 {
+		return
+	}
+	r := &idx.refs[len(idx.refs)-1]
+	lin := b.intervals
+	for j := 1; j < len(lin); j++ {
+		if lin[j] == 0 {
+			lin[j] = lin[j-1]
+		}
+	}
+	if idx.Format == TBI {
+		r.intervals = lin
+	} else {
+		// the loffset of a bin is the linear index at its first window.
+		for id, bn := range r.bins {
+			first, shift := uint32(0), uint(idx.MinShift+idx.Depth*3)
+			for l := 0; id >= first+(1<<(uint(l)*3)); l++ {
+				first += 1 << (uint(l) * 3)
+				shift -= 3
+			}
+			if w := int64(id-first) << shift >> uint(idx.MinShift); w < int64(len(lin)) {
+				bn.loffset = lin[w]
+			}
+		}
+	}
+	meta := binFirst(idx.Depth+1) + 1
+	r.bins[meta] = &bin{chunks: []chunk{{b.refStart, b.lastEnd}, {VirtualOffset(b.nRecords), 0}}}
+	b.intervals = nil
+}
+
+// pad adds empty references until there are n.
+func (b *IndexBuilder) pad(n int) {
+	for len(b.idx.refs) < n {
+		b.idx.refs = append(b.idx.refs, refIndex{bins: make(map[uint32]*bin)})
+	}
+}
+
+// Index completes and returns the index. No records may be added afterwards.
+func (b *IndexBuilder) Index() *Index {
+	if !b.done {
+		b.finishRef()
+		for _, id := range b.contigs {
+			b.pad(id + 1)
+		}
+		b.idx.hasNoCoor = true
+		b.done = true
+	}
+	return b.idx
+}
@@ -3,6 +3,7 @@ package vcfgo
 import (
 	"bytes"
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
 )
@@ -219,8 +220,19 @@ func (i InfoByte) SGet(key string) []byte {
 	return val
 }
 
-// Get a value from the bytes typed according to the header.
+// Get a value from the bytes typed according to the header. String and
+// Character values are percent-decoded if the header says so.
 func (i InfoByte) Get(key string) (interface{}, error) {
+	val, err := i.get(key)
+	if i.header.percentEncoded() {
+		if _, ok := val.(bool); !ok {
+			val = decodeValue(val)
+		}
+	}
+	return val, err
+}
+
+func (i InfoByte) get(key string) (interface{}, error) {
 	v := string(i.SGet(key))
 	skey := string(key)
 	var ok bool
@@ -357,7 +369,11 @@ func (i *InfoByte) UpdateHeader(key string, value interface{}) {
 	}
 }
 
+// Set a value in the INFO field. Strings are percent-encoded if the header says so.
 func (i *InfoByte) Set(key string, value interface{}) error {
+	if i.header.percentEncoded() {
+		value = encodeValue(value)
+	}
 	if len(i.Info) == 0 {
 		if v, ok := value.(bool); ok {
 			if v {
@@ -400,3 +416,206 @@ func (i *InfoByte) Set(key string, value interface{}) error {
 func (i *InfoByte) Add(key string, value interface{}) {
 	i.Set(key, value)
 }
+
+// MISSING_INT represents a missing value in an Integer INFO field, as in BCF.
+const MISSING_INT = math.MinInt32
+
+// raw returns the value of key as it is in the INFO field. flag is true if the
+// key is present without a value.
+func (i InfoByte) raw(key string) (val []byte, flag bool) {
+	if key == "" || len(i.Info) == 0 {
+		return nil, false
+	}
+	start, end := getpositions(i.Info, key)
+	if start == -1 {
+		return nil, false
+	}
+	if end == -1 || end >= len(i.Info) {
+		end = len(i.Info) - 1
+	}
+	return i.Info[start : end+1], start == 0 || i.Info[start-1] != '='
+}
+
+// typed returns the raw value of key after checking that the header declares
+// it with one of types. The value is returned with the error for an undeclared key.
+func (i InfoByte) typed(key string, types ...string) ([]byte, bool, error) {
+	val, flag := i.raw(key)
+	var hi *Info
+	if i.header != nil {
+		i.header.RLock()
+		hi = i.header.Infos[key]
+		i.header.RUnlock()
+	}
+	if hi == nil {
+		return val, flag, fmt.Errorf("Info Error: %s not found in header", key)
+	}
+	for _, t := range types {
+		if hi.Type == t {
+			return val, flag, nil
+		}
+	}
+	return nil, false, fmt.Errorf("Info Error: %s has Type=%s: %w", key, hi.Type, ErrTypeMismatch)
+}
+
+// parseInt parses a decimal integer without converting b to a string.
+func parseInt[T string | []byte](b T) (int, bool) {
+	if len(b) == 0 {
+		return 0, false
+	}
+	i, neg := 0, b[0] == '-'
+	if neg || b[0] == '+' {
+		if i = 1; len(b) == 1 {
+			return 0, false
+		}
+	}
+	n := 0
+	for ; i < len(b); i++ {
+		c := b[i]
+		if c < '0' || c > '9' || n > (math.MaxInt-9)/10 {
+			return 0, false
+		}
+		n = n*10 + int(c-'0')
+	}
+	if neg {
+		n = -n
+	}
+	return n, true
+}
+
+// nextValue splits the first comma-separated value off val.
+func nextValue(val []byte) (value, rest []byte) {
+	if j := bytes.IndexByte(val, ','); j != -1 {
+		return val[:j], val[j+1:]
+	}
+	return val, nil
+}
+
+// GetInt returns the value of an Integer field. ok is false if the key is
+// absent or its value is missing.
+func (i InfoByte) GetInt(key string) (v int, ok bool, err error) {
+	val, flag, err := i.typed(key, "Integer")
+	if len(val) == 0 || flag || (len(val) == 1 && val[0] == '.') {
+		return 0, false, err
+	}
+	if bytes.IndexByte(val, ',') != -1 {
+		return 0, false, fmt.Errorf("Info Error: %s has more than one value: %w", key, ErrValueCount)
+	}
+	if v, ok = parseInt(val); !ok {
+		return 0, false, fmt.Errorf("Info Error: bad Integer for %s: %s: %w", key, val, ErrInvalidValue)
+	}
+	return v, true, err
+}
+
+// GetInts appends the values of an Integer field to dst[:0] and returns it.
+// Missing values are MISSING_INT; the result is empty if the key is absent.
+func (i InfoByte) GetInts(key string, dst []int) ([]int, error) {
+	dst = dst[:0]
+	val, flag, err := i.typed(key, "Integer")
+	if len(val) == 0 || flag {
+		return dst, err
+	}
+	for {
+		var s []byte
+		s, val = nextValue(val)
+		if len(s) == 1 && s[0] == '.' {
+			dst = append(dst, MISSING_INT)
+		} else if n, ok := parseInt(s); ok {
+			dst = append(dst, n)
+		} else {
+			return dst, fmt.Errorf("Info Error: bad Integer for %s: %s: %w", key, s, ErrInvalidValue)
+		}
+		if val == nil {
+			break
+		}
+	}
+	return dst, err
+}
+
+// GetFloat32 returns the value of a Float or Integer field. ok is false if the
+// key is absent or its value is missing.
+func (i InfoByte) GetFloat32(key string) (v float32, ok bool, err error) {
+	val, flag, err := i.typed(key, "Float", "Integer")
+	if len(val) == 0 || flag || (len(val) == 1 && val[0] == '.') {
+		return 0, false, err
+	}
+	if bytes.IndexByte(val, ',') != -1 {
+		return 0, false, fmt.Errorf("Info Error: %s has more than one value: %w", key, ErrValueCount)
+	}
+	f, perr := strconv.ParseFloat(string(val), 32)
+	if perr != nil {
+		return 0, false, fmt.Errorf("Info Error: bad Float for %s: %w", key, ErrInvalidValue)
+	}
+	return float32(f), true, err
+}
+
+// GetFloat32s appends the values of a Float or Integer field to dst[:0] and
+// returns it. Missing values are MISSING_VAL; the result is empty if the key is absent.
+func (i InfoByte) GetFloat32s(key string, dst []float32) ([]float32, error) {
+	dst = dst[:0]
+	val, flag, err := i.typed(key, "Float", "Integer")
+	if len(val) == 0 || flag {
+		return dst, err
+	}
+	for {
+		var s []byte
+		s, val = nextValue(val)
+		if len(s) == 1 && s[0] == '.' {
+			dst = append(dst, MISSING_VAL)
+		} else if f, perr := strconv.ParseFloat(string(s), 32); perr == nil {
+			dst = append(dst, float32(f))
+		} else {
+			return dst, fmt.Errorf("Info Error: bad Float for %s: %w", key, ErrInvalidValue)
+		}
+		if val == nil {
+			break
+		}
+	}
+	return dst, err
+}
+
+// GetString returns the value of a String or Character field, percent-decoded
+// if the header says so. ok is false if the key is absent or its value is missing.
+func (i InfoByte) GetString(key string) (v string, ok bool, err error) {
+	val, flag, err := i.typed(key, "String", "Character")
+	if len(val) == 0 || flag || (len(val) == 1 && val[0] == '.') {
+		return "", false, err
+	}
+	if i.header.percentEncoded() {
+		return PercentDecode(string(val)), true, err
+	}
+	return string(val), true, err
+}
+
+// GetStrings appends the comma-separated values of a String or Character
+// field to dst[:0] and returns it. Missing values are "."; the result is
+// empty if the key is absent.
+func (i InfoByte) GetStrings(key string, dst []string) ([]string, error) {
+	dst = dst[:0]
+	val, flag, err := i.typed(key, "String", "Character")
+	if len(val) == 0 || flag {
+		return dst, err
+	}
+	decode := i.header.percentEncoded()
+	for {
+		var s []byte
+		s, val = nextValue(val)
+		if decode {
+			dst = append(dst, PercentDecode(string(s)))
+		} else {
+			dst = append(dst, string(s))
+		}
+		if val == nil {
+			break
+		}
+	}
+	return dst, err
+}
+
+// GetFlag reports whether a Flag field is set.
+func (i InfoByte) GetFlag(key string) (bool, error) {
+	val, flag, err := i.typed(key, "Flag")
+	if len(val) > 0 && !flag {
+		return true, fmt.Errorf("Info Error: flag field (%s) had value", key)
+	}
+	return flag, err
+}
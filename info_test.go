@@ -1,7 +1,10 @@
 package vcfgo
 
 import (
+	"errors"
 	"io"
+	"math"
+	"testing"
 
 	. "gopkg.in/check.v1"
 )
@@ -121,3 +124,101 @@ func (s *InfoSuite) TestInfoFlag(c *C) {
 	i.Set("AAA", false)
 	c.Assert(i.String(), Equals, "asdf=123;FLAG1;ddd=123;ggg;gga")
 }
+
+func typedInfoHeader() *Header {
+	h := NewHeader()
+	for _, i := range []*Info{
+		{Id: "DP", Number: "1", Type: "Integer"},
+		{Id: "AC", Number: "A", Type: "Integer"},
+		{Id: "AF", Number: "A", Type: "Float"},
+		{Id: "MQ", Number: "1", Type: "Float"},
+		{Id: "DB", Number: "0", Type: "Flag"},
+		{Id: "GENE", Number: "1", Type: "String"},
+		{Id: "CSQ", Number: ".", Type: "String"},
+		{Id: "SB", Number: "1", Type: "Character"},
+	} {
+		h.Infos[i.Id] = i
+	}
+	return h
+}
+
+func (s *InfoSuite) TestTypedGetters(c *C) {
+	h := typedInfoHeader()
+	i := NewInfoByte([]byte("DP=14;AC=1,.,-3;AF=0.5,.;MQ=.;DB;GENE=BRCA2;CSQ=a|b,c|d;SB=+;XX=7"), h)
+
+	dp, ok, err := i.GetInt("DP")
+	c.Assert(err, IsNil)
+	c.Assert(ok, Equals, true)
+	c.Assert(dp, Equals, 14)
+	_, ok, err = i.GetInt("NONE")
+	c.Assert(ok, Equals, false)
+	c.Assert(err, ErrorMatches, "Info Error: NONE not found in header")
+	xx, ok, err := i.GetInt("XX")
+	c.Assert(xx, Equals, 7)
+	c.Assert(ok, Equals, true)
+	c.Assert(err, Not(IsNil))
+	_, _, err = i.GetInt("AF")
+	c.Assert(errors.Is(err, ErrTypeMismatch), Equals, true)
+	_, _, err = i.GetInt("AC")
+	c.Assert(errors.Is(err, ErrValueCount), Equals, true)
+
+	ac, err := i.GetInts("AC", nil)
+	c.Assert(err, IsNil)
+	c.Assert(ac, DeepEquals, []int{1, MISSING_INT, -3})
+	ac, err = i.GetInts("DP", ac)
+	c.Assert(err, IsNil)
+	c.Assert(ac, DeepEquals, []int{14})
+	ac, err = i.GetInts("AC2", ac)
+	c.Assert(ac, HasLen, 0)
+	c.Assert(err, Not(IsNil))
+
+	af, err := i.GetFloat32s("AF", nil)
+	c.Assert(err, IsNil)
+	c.Assert(af, HasLen, 2)
+	c.Assert(af[0], Equals, float32(0.5))
+	c.Assert(math.Float32bits(af[1]), Equals, missingBits)
+	_, ok, err = i.GetFloat32("MQ")
+	c.Assert(ok, Equals, false)
+	c.Assert(err, IsNil)
+	f, ok, err := i.GetFloat32("DP")
+	c.Assert(f, Equals, float32(14))
+	c.Assert(ok, Equals, true)
+
+	gene, ok, err := i.GetString("GENE")
+	c.Assert(gene, Equals, "BRCA2")
+	c.Assert(ok, Equals, true)
+	c.Assert(err, IsNil)
+	sb, _, _ := i.GetString("SB")
+	c.Assert(sb, Equals, "+")
+	csq, err := i.GetStrings("CSQ", nil)
+	c.Assert(err, IsNil)
+	c.Assert(csq, DeepEquals, []string{"a|b", "c|d"})
+
+	db, err := i.GetFlag("DB")
+	c.Assert(db, Equals, true)
+	c.Assert(err, IsNil)
+	i.Delete("DB")
+	db, err = i.GetFlag("DB")
+	c.Assert(db, Equals, false)
+	c.Assert(err, IsNil)
+	_, err = i.GetFlag("DP")
+	c.Assert(errors.Is(err, ErrTypeMismatch), Equals, true)
+
+	i = NewInfoByte([]byte("DP=x;AF=0.1,y"), h)
+	_, _, err = i.GetInt("DP")
+	c.Assert(errors.Is(err, ErrInvalidValue), Equals, true)
+	_, err = i.GetFloat32s("AF", nil)
+	c.Assert(errors.Is(err, ErrInvalidValue), Equals, true)
+}
+
+func (s *InfoSuite) TestTypedGettersAllocs(c *C) {
+	i := NewInfoByte([]byte("DP=14;AC=1,2,3;AF=0.5,0.25,0.125;DB;GENE=BRCA2"), typedInfoHeader())
+	ints, floats := make([]int, 0, 4), make([]float32, 0, 4)
+	allocs := testing.AllocsPerRun(100, func() {
+		i.GetInt("DP")
+		ints, _ = i.GetInts("AC", ints)
+		floats, _ = i.GetFloat32s("AF", floats)
+		i.GetFlag("DB")
+	})
+	c.Assert(allocs, Equals, float64(0))
+}
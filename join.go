@@ -0,0 +1,516 @@
+package vcfgo
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// VariantReader is implemented by Reader, QueryIterator and Joiner.
+type VariantReader interface {
+	// Read returns the next variant or nil when there are no more.
+	Read() *Variant
+}
+
+// Joiner reads consecutive records at the same CHROM and POS from a
+// VariantReader and joins them into multi-allelic records with JoinAlts, like
+// bcftools norm -m+. Records at a position whose REFs differ, other than by
+// one extending the other, are joined in separate groups, returned in the
+// order of their first record. Records that can not be joined are returned as
+// they are and the reason is available from Error.
+type Joiner struct {
+	r       VariantReader
+	next    *Variant
+	pending []*Variant
+	verr    *VCFError
+}
+
+// NewJoiner returns a Joiner that reads from r.
+func NewJoiner(r VariantReader) *Joiner {
+	return &Joiner{r: r, verr: NewVCFError()}
+}
+
+// Read returns the next joined record or nil when there are no more.
+func (j *Joiner) Read() *Variant {
+	if len(j.pending) > 0 {
+		v := j.pending[0]
+		j.pending = j.pending[1:]
+		return v
+	}
+	v := j.next
+	if v == nil {
+		if v = j.r.Read(); v == nil {
+			return nil
+		}
+	}
+	group := []*Variant{v}
+	for {
+		j.next = j.r.Read()
+		if j.next == nil || j.next.Chromosome != v.Chromosome || j.next.Pos != v.Pos {
+			break
+		}
+		group = append(group, j.next)
+	}
+	if len(group) == 1 {
+		return v
+	}
+	for _, refGroup := range groupByRef(group) {
+		if len(refGroup) == 1 {
+			j.pending = append(j.pending, refGroup[0])
+			continue
+		}
+		joined, err := JoinAlts(refGroup)
+		if err != nil {
+			j.verr.Add(err, refGroup[0].LineNumber)
+			j.pending = append(j.pending, refGroup...)
+			continue
+		}
+		j.pending = append(j.pending, joined)
+	}
+	return j.Read()
+}
+
+// groupByRef splits records at one position into groups that JoinAlts can
+// join: in each, the REF of every record starts the longest REF of the group.
+func groupByRef(vars []*Variant) [][]*Variant {
+	var groups [][]*Variant
+	// longest holds the longest REF of each group.
+	var longest []string
+	for _, v := range vars {
+		k := 0
+		for ; k < len(groups); k++ {
+			if strings.HasPrefix(longest[k], v.Reference) {
+				break
+			}
+			if strings.HasPrefix(v.Reference, longest[k]) {
+				longest[k] = v.Reference
+				break
+			}
+		}
+		if k == len(groups) {
+			groups = append(groups, nil)
+			longest = append(longest, v.Reference)
+		}
+		groups[k] = append(groups[k], v)
+	}
+	return groups
+}
+
+// Error returns the errors of the records that could not be joined.
+func (j *Joiner) Error() error {
+	if j.verr.IsEmpty() {
+		return nil
+	}
+	return j.verr
+}
+
+// JoinAlts joins records at the same position into one record with the ALT
+// alleles of all of them. It is the inverse of SplitAlts. REF is the longest
+// of the REFs, which must all start like it, and the ALTs of the shorter ones
+// are padded with the rest of it. GT alleles are re-indexed, taking the ALT
+// allele of a sample from whichever record called it, and Number=A, R and G
+// fields of INFO and FORMAT are merged with "." where a record has no value.
+// Other fields are taken from the first record that has them. If the records
+// have an OLD_MULTIALLELIC tag it is dropped and AN is restored.
+func JoinAlts(vars []*Variant) (*Variant, error) {
+	if len(vars) == 0 {
+		return nil, errors.New("JoinAlts: no variants")
+	}
+	first := vars[0]
+	ref := first.Reference
+	for _, v := range vars[1:] {
+		if v.Chromosome != first.Chromosome || v.Pos != first.Pos {
+			return nil, fmt.Errorf("JoinAlts: %s:%d and %s:%d are not at the same position", first.Chromosome, first.Pos, v.Chromosome, v.Pos)
+		}
+		if len(v.Reference) > len(ref) {
+			ref = v.Reference
+		}
+	}
+
+	// alleles maps the alleles of each record to those of the joined record.
+	alleles := make([][]int, len(vars))
+	var alts []string
+	index := make(map[string]int)
+	for k, v := range vars {
+		if !strings.HasPrefix(ref, v.Reference) {
+			return nil, fmt.Errorf("JoinAlts: REF %s at %s:%d does not match %s", v.Reference, v.Chromosome, v.Pos, ref)
+		}
+		alleles[k] = []int{0}
+		for _, alt := range v.Alt() {
+			if alt == "." || alt == "" {
+				continue
+			}
+			if pad := ref[len(v.Reference):]; pad != "" {
+				if alt[0] == '<' || strings.ContainsAny(alt, "[]") || alt == "*" {
+					return nil, fmt.Errorf("JoinAlts: can not pad ALT %s at %s:%d", alt, v.Chromosome, v.Pos)
+				}
+				alt += pad
+			}
+			i, ok := index[alt]
+			if !ok {
+				alts = append(alts, alt)
+				i = len(alts)
+				index[alt] = i
+			}
+			alleles[k] = append(alleles[k], i)
+		}
+	}
+	if len(alts) == 0 {
+		alts = []string{"."}
+	}
+
+	j := &Variant{Chromosome: first.Chromosome, Pos: first.Pos, Reference: ref, Alternate: alts,
+		Quality: MISSING_VAL, Header: first.Header, LineNumber: first.LineNumber}
+	var ids, filters []string
+	for _, v := range vars {
+		ids = appendUnique(ids, v.Id_, ";")
+		if v.Filter != "PASS" {
+			filters = appendUnique(filters, v.Filter, ";")
+		}
+		if q := v.Quality; !math.IsNaN(float64(q)) && (math.IsNaN(float64(j.Quality)) || q > j.Quality) {
+			j.Quality = q
+		}
+	}
+	j.Id_ = strings.Join(ids, ";")
+	if j.Id_ == "" {
+		j.Id_ = "."
+	}
+	j.Filter = strings.Join(filters, ";")
+	if j.Filter == "" {
+		j.Filter = first.Filter
+	}
+
+	info, err := joinInfo(vars, alleles, len(alts))
+	if err != nil {
+		return nil, err
+	}
+	j.Info_ = NewInfoByte([]byte(info), j.Header)
+	if err := joinSamples(j, vars, alleles); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// appendUnique appends the values of the sep-separated list s that are not
+// missing and not yet in list.
+func appendUnique(list []string, s, sep string) []string {
+	for _, x := range strings.Split(s, sep) {
+		if x == "." || x == "" {
+			continue
+		}
+		found := false
+		for _, y := range list {
+			found = found || x == y
+		}
+		if !found {
+			list = append(list, x)
+		}
+	}
+	return list
+}
+
+// joinInfo returns the INFO of the joined record as text.
+func joinInfo(vars []*Variant, alleles [][]int, nAlts int) (string, error) {
+	h := vars[0].Header
+	var keys []string
+	values := make(map[string][]string, 8)
+	flags := make(map[string]bool)
+	split := false
+	for k, v := range vars {
+		if v.Info_ == nil {
+			continue
+		}
+		for _, kv := range strings.Split(v.Info_.String(), ";") {
+			if kv == "" || kv == "." {
+				continue
+			}
+			key, val, hasVal := strings.Cut(kv, "=")
+			if key == oldMultiallelic {
+				split = true
+				continue
+			}
+			if _, ok := values[key]; !ok && !flags[key] {
+				keys = append(keys, key)
+			}
+			if !hasVal {
+				flags[key] = true
+				continue
+			}
+			if values[key] == nil {
+				values[key] = make([]string, len(vars))
+			}
+			values[key][k] = val
+		}
+	}
+
+	fields := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if flags[key] {
+			fields = append(fields, key)
+			continue
+		}
+		number := ""
+		if def, ok := h.Infos[key]; ok {
+			number = def.Number
+		}
+		val, err := joinValues(number, values[key], alleles, nAlts)
+		if err != nil {
+			return "", fmt.Errorf("JoinAlts: INFO %s: %w", key, err)
+		}
+		if key == "AN" && split {
+			var ok bool
+			if val, ok = joinAN(values["AN"], values["AC"], h); !ok {
+				continue
+			}
+		}
+		fields = append(fields, key+"="+val)
+	}
+	return strings.Join(fields, ";"), nil
+}
+
+// joinAN undoes the adjustment of AN by SplitAlts: the AN of the first record
+// that has one plus the AC of the others. It returns false if no record has an AN.
+func joinAN(an, ac []string, h *Header) (string, bool) {
+	first := -1
+	for k, s := range an {
+		if s != "" && s != "." {
+			first = k
+			break
+		}
+	}
+	if first < 0 {
+		return "", false
+	}
+	n, err := strconv.Atoi(an[first])
+	if err != nil || ac == nil || h.Infos["AC"] == nil || h.Infos["AC"].Number != "A" {
+		return an[first], true
+	}
+	for k, s := range ac {
+		if k == first {
+			continue
+		}
+		for _, x := range strings.Split(s, ",") {
+			if c, err := strconv.Atoi(x); err == nil {
+				n += c
+			}
+		}
+	}
+	return strconv.Itoa(n), true
+}
+
+// joinValues merges the value of a field in each record, "" where a record
+// lacks it, according to its Number.
+func joinValues(number string, vals []string, alleles [][]int, nAlts int) (string, error) {
+	var out []string
+	for k, val := range vals {
+		if val == "" || val == "." {
+			continue
+		}
+		n := len(alleles[k]) - 1
+		items := strings.Split(val, ",")
+		switch number {
+		case "A", "R":
+			if number == "A" {
+				items = append([]string{"."}, items...)
+			}
+			if len(items) != n+1 {
+				return "", fmt.Errorf("expected %d values but found %d in '%s': %w", n+1, len(items), val, ErrValueCount)
+			}
+			if out == nil {
+				out = missingValues(nAlts + 1)
+			}
+			for a, x := range items {
+				setValue(out, alleles[k][a], x)
+			}
+		case "G":
+			switch len(items) {
+			case n + 1:
+				// haploid
+				if out == nil {
+					out = missingValues(nAlts + 1)
+				}
+				for a, x := range items {
+					setValue(out, alleles[k][a], x)
+				}
+			case genotypeCount(n+1, 2):
+				if out == nil {
+					out = missingValues(genotypeCount(nAlts+1, 2))
+				}
+				for b := 0; b <= n; b++ {
+					for a := 0; a <= b; a++ {
+						ga, gb := alleles[k][a], alleles[k][b]
+						if ga > gb {
+							ga, gb = gb, ga
+						}
+						setValue(out, gb*(gb+1)/2+ga, items[b*(b+1)/2+a])
+					}
+				}
+			default:
+				return "", fmt.Errorf("expected %d or %d values but found %d in '%s': %w", n+1, genotypeCount(n+1, 2), len(items), val, ErrValueCount)
+			}
+		default:
+			return val, nil
+		}
+	}
+	if out == nil {
+		return ".", nil
+	}
+	if number == "A" {
+		out = out[1:]
+	}
+	return strings.Join(out, ","), nil
+}
+
+func missingValues(n int) []string {
+	out := make([]string, n)
+	for i := range out {
+		out[i] = "."
+	}
+	return out
+}
+
+// setValue sets out[i] to x unless x is missing or out[i] was already set.
+func setValue(out []string, i int, x string) {
+	if i < len(out) && out[i] == "." {
+		out[i] = x
+	}
+}
+
+// joinGenotype merges the GT of a sample in each record: an allele is the ALT
+// allele of whichever record called one there, else REF if any record did.
+func joinGenotype(gts []string, alleles [][]int) string {
+	var out []int
+	sep := byte(0)
+	for k, gt := range gts {
+		if gt == "" {
+			continue
+		}
+		p, start := 0, 0
+		for i := 0; i <= len(gt); i++ {
+			if i < len(gt) && !isGenotypeSep(rune(gt[i])) {
+				continue
+			}
+			if i < len(gt) && sep == 0 {
+				sep = gt[i]
+			}
+			for p >= len(out) {
+				out = append(out, -1)
+			}
+			if a, err := strconv.Atoi(gt[start:i]); err == nil && a < len(alleles[k]) {
+				if g := alleles[k][a]; g > 0 || out[p] == -1 {
+					out[p] = g
+				}
+			}
+			p++
+			start = i + 1
+		}
+	}
+	if sep == 0 {
+		sep = '/'
+	}
+	s := make([]string, len(out))
+	for i, a := range out {
+		s[i] = "."
+		if a >= 0 {
+			s[i] = strconv.Itoa(a)
+		}
+	}
+	if len(s) == 0 {
+		return "."
+	}
+	return strings.Join(s, string(sep))
+}
+
+// joinSamples sets the FORMAT and samples of j from those of vars.
+func joinSamples(j *Variant, vars []*Variant, alleles [][]int) error {
+	h := j.Header
+	var format []string
+	columns := make([][]string, len(vars))
+	nSamples := -1
+	parse := h != nil && h.columns != nil
+	for k, v := range vars {
+		if len(v.Format) == 0 {
+			continue
+		}
+		for _, key := range v.Format {
+			if key == "LAA" {
+				return fmt.Errorf("JoinAlts: local alleles are not supported")
+			}
+			if key == "GT" && (len(format) == 0 || format[0] != "GT") {
+				format = append([]string{"GT"}, format...)
+			} else {
+				format = appendUnique(format, key, ":")
+			}
+		}
+		columns[k] = v.sampleColumns()
+		if nSamples >= 0 && len(columns[k]) != nSamples {
+			return fmt.Errorf("JoinAlts: %d samples at line %d but %d at line %d: %w", len(columns[k]), v.LineNumber, nSamples, vars[0].LineNumber, ErrSampleCount)
+		}
+		nSamples = len(columns[k])
+		parse = parse || v.Samples != nil
+	}
+	if format == nil || nSamples <= 0 {
+		j.Format = format
+		return nil
+	}
+	j.Format = format
+
+	samples := make([]string, nSamples)
+	vals := make([]string, len(vars))
+	out := make([]string, len(format))
+	for i := range samples {
+		// fields holds the values of sample i in each record by key.
+		fields := make([]map[string]string, len(vars))
+		for k, v := range vars {
+			if columns[k] == nil {
+				continue
+			}
+			fields[k] = make(map[string]string, len(v.Format))
+			for f, x := range strings.Split(columns[k][i], ":") {
+				if f < len(v.Format) {
+					fields[k][v.Format[f]] = x
+				}
+			}
+		}
+		for f, key := range format {
+			for k := range vars {
+				vals[k] = fields[k][key]
+			}
+			if key == "GT" {
+				out[f] = joinGenotype(vals, alleles)
+				continue
+			}
+			number := ""
+			if def, ok := h.SampleFormats[key]; ok {
+				number = def.Number
+			}
+			x, err := joinValues(number, vals, alleles, len(j.Alternate))
+			if err != nil {
+				return fmt.Errorf("JoinAlts: FORMAT %s sample %d: %w", key, i, err)
+			}
+			out[f] = x
+		}
+		samples[i] = strings.Join(out, ":")
+	}
+
+	if !parse {
+		j.sampleString = strings.Join(samples, "\t")
+		return nil
+	}
+	// the columns follow the samples of the header, whose columns may have changed.
+	if h.columns != nil {
+		j.parsed = h.columnIDs()
+	}
+	j.Samples = make([]*SampleGenotype, len(samples))
+	for i, s := range samples {
+		g, errs := h.parseSample(format, s)
+		for _, e := range errs {
+			e.Line, e.Sample = j.LineNumber, i
+			j.Errors = append(j.Errors, e)
+		}
+		j.Samples[i] = g
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package vcfgo
+
+import (
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+type JoinSuite struct{}
+
+var _ = Suite(&JoinSuite{})
+
+func (s *JoinSuite) TestRoundTrip(c *C) {
+	for _, lazy := range []bool{false, true} {
+		rdr, err := NewReader(strings.NewReader(splitVCF), lazy)
+		c.Assert(err, IsNil)
+		j, err := JoinAlts(SplitAlts(rdr.Read()))
+		c.Assert(err, IsNil)
+		c.Assert(j.String(), Equals, "1\t100\trs1\tA\tC,G\t50.0\tPASS\tAC=2,1;AN=6;AF=0.33,0.17;DP=30\tGT:AD:PL:DP\t0/1:5,4,0:40,0,50,60,.,80:9\t1|2:0,6,5:90,80,70,10,.,0:11\t0:5,.,1:0,.,9:10")
+		c.Assert(j.Samples != nil, Equals, !lazy)
+		if !lazy {
+			c.Assert(j.Samples[1].GT, DeepEquals, []int{1, 2})
+		}
+	}
+}
+
+func (s *JoinSuite) TestMissingAN(c *C) {
+	rdr, err := NewReader(strings.NewReader(splitVCF), true)
+	c.Assert(err, IsNil)
+	vars := SplitAlts(rdr.Read())
+	// the AN of the second record, 4, plus the AC of the first.
+	vars[0].Info().Delete("AN")
+	j, err := JoinAlts(vars)
+	c.Assert(err, IsNil)
+	c.Assert(strings.Split(j.String(), "\t")[7], Equals, "AC=2,1;AF=0.33,0.17;DP=30;AN=6")
+
+	vars[1].Info().Delete("AN")
+	j, err = JoinAlts(vars)
+	c.Assert(err, IsNil)
+	c.Assert(strings.Split(j.String(), "\t")[7], Equals, "AC=2,1;AF=0.33,0.17;DP=30")
+}
+
+const joinVCF = `##fileformat=VCFv4.2
+##INFO=<ID=AC,Number=A,Type=Integer,Description="Allele count">
+##INFO=<ID=DB,Number=0,Type=Flag,Description="dbSNP">
+##FORMAT=<ID=GT,Number=1,Type=String,Description="Genotype">
+##FORMAT=<ID=AD,Number=R,Type=Integer,Description="Allele depths">
+##FORMAT=<ID=GQ,Number=1,Type=Integer,Description="Genotype quality">
+#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO	FORMAT	S1	S2
+1	100	rs1	AT	A	10.0	PASS	AC=1	GT:AD	0/1:3,4	0/0:9,0
+1	100	rs2	A	G	20.0	LowQual	AC=2;DB	GT:GQ	0/0:30	1/1:40
+1	200	.	C	T	.	PASS	AC=1	GT	0/1	0/0
+1	300	.	C	T	.	PASS	AC=1	GT	0/1	0/0
+1	300	.	G	T	.	PASS	AC=1	GT	0/1	0/0
+`
+
+func (s *JoinSuite) TestJoiner(c *C) {
+	rdr, err := NewReader(strings.NewReader(joinVCF), true)
+	c.Assert(err, IsNil)
+	j := NewJoiner(rdr)
+	var got []string
+	for v := j.Read(); v != nil; v = j.Read() {
+		got = append(got, v.String())
+	}
+	c.Assert(got, DeepEquals, []string{
+		"1\t100\trs1;rs2\tAT\tA,GT\t20.0\tLowQual\tAC=1,2;DB\tGT:AD:GQ\t0/1:3,4,.:30\t2/2:9,0,.:40",
+		"1\t200\t.\tC\tT\t.\tPASS\tAC=1\tGT\t0/1\t0/0",
+		"1\t300\t.\tC\tT\t.\tPASS\tAC=1\tGT\t0/1\t0/0",
+		"1\t300\t.\tG\tT\t.\tPASS\tAC=1\tGT\t0/1\t0/0",
+	})
+	// the REFs at 300 differ so they are not joined, which is not an error.
+	c.Assert(j.Error(), IsNil)
+}
+
+func (s *JoinSuite) TestJoinerGroupsByRef(c *C) {
+	text := strings.Split(joinVCF, "1\t100\t")[0] + `1	300	.	C	T	.	PASS	AC=1	GT	0/1	0/0
+1	300	.	G	T	.	PASS	AC=1	GT	0/1	0/0
+1	300	.	CA	C	.	PASS	AC=1	GT	0/0	0/1
+1	300	.	G	A	.	PASS	AC=2	GT	0/0	1/1
+1	400	.	C	T	.	PASS	AC=1	GT	0/1	0/0
+`
+	rdr, err := NewReader(strings.NewReader(text), true)
+	c.Assert(err, IsNil)
+	j := NewJoiner(rdr)
+	var got []string
+	for v := j.Read(); v != nil; v = j.Read() {
+		got = append(got, v.String())
+	}
+	c.Assert(got, DeepEquals, []string{
+		"1\t300\t.\tCA\tTA,C\t.\tPASS\tAC=1,1\tGT\t0/1\t0/2",
+		"1\t300\t.\tG\tT,A\t.\tPASS\tAC=1,2\tGT\t0/1\t2/2",
+		"1\t400\t.\tC\tT\t.\tPASS\tAC=1\tGT\t0/1\t0/0",
+	})
+	c.Assert(j.Error(), IsNil)
+}
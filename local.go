@@ -0,0 +1,196 @@
+package vcfgo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// UnspecifiedAllele is the symbolic allele of VCF 4.4 that stands for any
+// allele not listed in ALT, as in gVCF reference blocks. GATK writes <NON_REF>
+// for the same purpose.
+const UnspecifiedAllele = "<*>"
+
+// IsUnspecified reports whether an ALT allele is <*> or <NON_REF>.
+func IsUnspecified(alt string) bool {
+	return alt == UnspecifiedAllele || alt == "<NON_REF>"
+}
+
+// samplePloidy returns the number of alleles in the GT or, without one, the LGT of g.
+func samplePloidy(g *SampleGenotype) int {
+	if len(g.GT) > 0 {
+		return len(g.GT)
+	}
+	if lgt, ok := g.Fields["LGT"]; ok {
+		return len(strings.FieldsFunc(lgt, isGenotypeSep))
+	}
+	return 0
+}
+
+func isGenotypeSep(r rune) bool { return r == '/' || r == '|' }
+
+// LocalAlleles returns the LAA field of g: the 1-based indexes in ALT of the
+// alleles that the local allele fields (Number=LA, LR or LG) of the sample
+// refer to. It is empty when the sample has no LAA or it is missing.
+func (v *Variant) LocalAlleles(g *SampleGenotype) ([]int, error) {
+	laa, ok := g.Fields["LAA"]
+	if !ok || laa == "." || laa == "" {
+		return nil, nil
+	}
+	fields := strings.Split(laa, ",")
+	alleles := make([]int, len(fields))
+	for i, f := range fields {
+		a, err := strconv.Atoi(f)
+		if err != nil || a < 1 || a > len(v.Alternate) {
+			return nil, fmt.Errorf("bad LAA: %s", laa)
+		}
+		alleles[i] = a
+	}
+	return alleles, nil
+}
+
+// ExpandLocal returns a local allele field of g in global allele indexing.
+// For a field with Number LA, LR or LG the result has one value per ALT, per
+// allele or per genotype of the record, as for A, R and G, and the values of
+// alleles that are not local to the sample are set to missing. The result is
+// a []int or []float32 as with GetGenotypeField. LGT is returned as the
+// equivalent GT string. LG is supported for haploid and diploid samples.
+func (v *Variant) ExpandLocal(g *SampleGenotype, field string, missing interface{}) (interface{}, error) {
+	laa, err := v.LocalAlleles(g)
+	if err != nil {
+		return nil, err
+	}
+	// global maps the local allele indexes to the global ones.
+	global := append([]int{0}, laa...)
+	if field == "LGT" {
+		lgt, ok := g.Fields["LGT"]
+		if !ok {
+			return nil, fmt.Errorf("ExpandLocal: field not found in genotypes: LGT")
+		}
+		var b strings.Builder
+		start := 0
+		for i := 0; i <= len(lgt); i++ {
+			if i < len(lgt) && !isGenotypeSep(rune(lgt[i])) {
+				continue
+			}
+			if a := lgt[start:i]; a == "." {
+				b.WriteString(a)
+			} else if l, err := strconv.Atoi(a); err == nil && l >= 0 && l < len(global) {
+				b.WriteString(strconv.Itoa(global[l]))
+			} else {
+				return nil, fmt.Errorf("ExpandLocal: bad LGT: %s", lgt)
+			}
+			if i < len(lgt) {
+				b.WriteByte(lgt[i])
+			}
+			start = i + 1
+		}
+		return b.String(), nil
+	}
+
+	format, ok := v.Header.SampleFormats[field]
+	if !ok {
+		return nil, fmt.Errorf("ExpandLocal: field not found in formats: %s", field)
+	}
+	val, err := v.GetGenotypeField(g, field, missing)
+	if err != nil {
+		return nil, err
+	}
+	nAlleles := len(v.Alternate) + 1
+	// pos holds the position in the global field of each local value.
+	var pos []int
+	var n int
+	switch format.Number {
+	case "LA":
+		n = nAlleles - 1
+		for _, a := range laa {
+			pos = append(pos, a-1)
+		}
+	case "LR":
+		n, pos = nAlleles, global
+	case "LG":
+		ploidy := samplePloidy(g)
+		n = genotypeCount(nAlleles, ploidy)
+		switch ploidy {
+		case 1:
+			pos = global
+		case 2:
+			// genotype j/k with j <= k is at k*(k+1)/2 + j.
+			for k := range global {
+				for j := 0; j <= k; j++ {
+					a, b := global[j], global[k]
+					if a > b {
+						a, b = b, a
+					}
+					pos = append(pos, b*(b+1)/2+a)
+				}
+			}
+		default:
+			return nil, fmt.Errorf("ExpandLocal: LG is not supported for ploidy %d", ploidy)
+		}
+	default:
+		return nil, fmt.Errorf("ExpandLocal: %s has Number=%s, not a local allele number", field, format.Number)
+	}
+
+	switch vals := val.(type) {
+	case int:
+		val = []int{vals}
+	case float32:
+		val = []float32{vals}
+	case float64:
+		val = []float32{float32(vals)}
+	}
+	switch local := val.(type) {
+	case []int:
+		if len(local) > len(pos) {
+			return nil, fmt.Errorf("ExpandLocal: %s has more values than the %d local alleles allow", field, len(laa))
+		}
+		mv, ok := missing.(int)
+		if !ok {
+			return nil, fmt.Errorf("ExpandLocal: bad non-int missing value: %v", missing)
+		}
+		out := make([]int, n)
+		for i := range out {
+			out[i] = mv
+		}
+		for i, x := range local {
+			out[pos[i]] = x
+		}
+		return out, nil
+	case []float32:
+		if len(local) > len(pos) {
+			return nil, fmt.Errorf("ExpandLocal: %s has more values than the %d local alleles allow", field, len(laa))
+		}
+		mv, ok := missing.(float32)
+		if !ok {
+			return nil, fmt.Errorf("ExpandLocal: bad non-float missing value: %v", missing)
+		}
+		out := make([]float32, n)
+		for i := range out {
+			out[i] = mv
+		}
+		for i, x := range local {
+			out[pos[i]] = x
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("ExpandLocal: %s is not an Integer or Float field", field)
+}
+
+// SVClaim returns the SVCLAIM of each ALT of a VCF 4.4 structural variant: "D"
+// if the record claims a change in the copy number of the region, "J" if it
+// claims the breakend junctions, and "DJ" for both. It returns nil without an
+// SVCLAIM.
+func (v *Variant) SVClaim() []string {
+	if v.Info_ == nil {
+		return nil
+	}
+	claim, _ := v.Info().Get("SVCLAIM")
+	switch claim := claim.(type) {
+	case []string:
+		return claim
+	case string:
+		return strings.Split(claim, ",")
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package vcfgo
+
+import (
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+type LocalSuite struct{}
+
+var _ = Suite(&LocalSuite{})
+
+const vcf44 = `##fileformat=VCFv4.4
+##INFO=<ID=END,Number=1,Type=Integer,Description="End position">
+##INFO=<ID=SVLEN,Number=A,Type=Integer,Description="Length of the SV">
+##INFO=<ID=SVCLAIM,Number=A,Type=String,Description="Claim made by the structural variant call">
+##FORMAT=<ID=LGT,Number=1,Type=String,Description="Local genotype">
+##FORMAT=<ID=LAA,Number=.,Type=Integer,Description="Local alternate alleles">
+##FORMAT=<ID=LAD,Number=LR,Type=Integer,Description="Local allele depths">
+##FORMAT=<ID=LPL,Number=LG,Type=Integer,Description="Local phred-scaled likelihoods">
+##FORMAT=<ID=LAF,Number=LA,Type=Float,Description="Local allele fractions">
+##FORMAT=<ID=PS,Number=P,Type=Integer,Description="Per allele phase set">
+#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO	FORMAT	S1	S2	S3
+1	100	.	A	C,G,T	.	PASS	.	LGT:LAA:LAD:LPL:LAF:PS	0/1:3:5,7:0,10,20:0.5:1,1	1/2:1,2:0,4,6:50,40,30,20,10,0:0.4,0.6:1,1	0/0:.:9:0:.:1,1
+1	200	.	A	<*>	.	PASS	END=250	LGT	0/0	0/0	0/0
+1	300	.	A	<DEL>	.	PASS	SVLEN=100;SVCLAIM=DJ	LGT	0/1	0/0	0/0
+`
+
+func (s *LocalSuite) TestRead(c *C) {
+	rdr, err := NewReaderWithOptions(strings.NewReader(vcf44), ReaderOptions{Policy: Lenient})
+	c.Assert(err, IsNil)
+	c.Assert(rdr.Header.SampleFormats["LPL"].Number, Equals, "LG")
+
+	v := rdr.Read()
+	c.Assert(v.Errors, HasLen, 0)
+	laa, err := v.LocalAlleles(v.Samples[1])
+	c.Assert(err, IsNil)
+	c.Assert(laa, DeepEquals, []int{1, 2})
+
+	lpl, err := v.GetGenotypeField(v.Samples[1], "LPL", -1)
+	c.Assert(err, IsNil)
+	c.Assert(lpl, DeepEquals, []int{50, 40, 30, 20, 10, 0})
+	ps, err := v.GetGenotypeField(v.Samples[0], "PS", -1)
+	c.Assert(err, IsNil)
+	c.Assert(ps, DeepEquals, []int{1, 1})
+	_, err = v.GetGenotypeField(v.Samples[0], "LPL", -1)
+	c.Assert(err, IsNil)
+
+	c.Assert(rdr.Read().End(), Equals, uint32(250))
+	v = rdr.Read()
+	c.Assert(v.SVClaim(), DeepEquals, []string{"DJ"})
+	c.Assert(v.End(), Equals, uint32(400))
+	c.Assert(IsUnspecified("<*>"), Equals, true)
+	c.Assert(IsUnspecified("<NON_REF>"), Equals, true)
+	c.Assert(IsUnspecified("<DEL>"), Equals, false)
+}
+
+func (s *LocalSuite) TestNumbers(c *C) {
+	rdr, err := NewReader(strings.NewReader(`##fileformat=VCFv4.4
+##FORMAT=<ID=GT,Number=1,Type=String,Description="Genotype">
+##FORMAT=<ID=PL,Number=G,Type=Integer,Description="Phred-scaled likelihoods">
+##FORMAT=<ID=MC,Number=M,Type=Integer,Description="Variable count">
+#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO	FORMAT	S1	S2
+1	100	.	A	C,G	.	PASS	.	GT:PL:MC	0/1:0,10,20,30,40,50:3	1:0,10,20:4,5,6
+`), false)
+	c.Assert(err, IsNil)
+	v := rdr.Read()
+
+	// G depends on the alleles and the ploidy.
+	pl, err := v.GetGenotypeField(v.Samples[0], "PL", -1)
+	c.Assert(err, IsNil)
+	c.Assert(pl, DeepEquals, []int{0, 10, 20, 30, 40, 50})
+	pl, err = v.GetGenotypeField(v.Samples[1], "PL", -1)
+	c.Assert(err, IsNil)
+	c.Assert(pl, DeepEquals, []int{0, 10, 20})
+
+	mc, err := v.GetGenotypeField(v.Samples[0], "MC", -1)
+	c.Assert(err, IsNil)
+	c.Assert(mc, Equals, 3)
+	mc, err = v.GetGenotypeField(v.Samples[1], "MC", -1)
+	c.Assert(err, IsNil)
+	c.Assert(mc, DeepEquals, []int{4, 5, 6})
+}
+
+func (s *LocalSuite) TestExpand(c *C) {
+	rdr, err := NewReader(strings.NewReader(vcf44), false)
+	c.Assert(err, IsNil)
+	v := rdr.Read()
+	s0, s1, s2 := v.Samples[0], v.Samples[1], v.Samples[2]
+
+	for _, t := range []struct {
+		g       *SampleGenotype
+		field   string
+		missing interface{}
+		exp     interface{}
+	}{
+		{s0, "LGT", nil, "0/3"},
+		{s1, "LGT", nil, "1/2"},
+		{s2, "LGT", nil, "0/0"},
+		{s0, "LAD", -1, []int{5, -1, -1, 7}},
+		{s1, "LAD", -1, []int{0, 4, 6, -1}},
+		{s2, "LAD", -1, []int{9, -1, -1, -1}},
+		// genotypes 0/0 0/1 1/1 0/2 1/2 2/2 0/3 1/3 2/3 3/3
+		{s0, "LPL", -1, []int{0, -1, -1, -1, -1, -1, 10, -1, -1, 20}},
+		{s1, "LPL", -1, []int{50, 40, 30, 20, 10, 0, -1, -1, -1, -1}},
+		{s1, "LAF", float32(-1), []float32{0.4, 0.6, -1}},
+		{s0, "LAF", float32(-1), []float32{-1, -1, 0.5}},
+	} {
+		got, err := v.ExpandLocal(t.g, t.field, t.missing)
+		c.Assert(err, IsNil, Commentf("%s", t.field))
+		c.Assert(got, DeepEquals, t.exp, Commentf("%s", t.field))
+	}
+
+	_, err = v.ExpandLocal(s1, "LAD", "x")
+	c.Assert(err, ErrorMatches, ".*bad non-int missing value: x")
+	_, err = v.ExpandLocal(s1, "LAF", -1)
+	c.Assert(err, ErrorMatches, ".*bad non-float missing value: -1")
+	_, err = v.ExpandLocal(s0, "PS", -1)
+	c.Assert(err, ErrorMatches, ".*not a local allele number")
+	s0.Fields["LAA"] = "4"
+	_, err = v.ExpandLocal(s0, "LAD", -1)
+	c.Assert(err, ErrorMatches, "bad LAA: 4")
+	s0.Fields["LAA"] = "3"
+	s0.Fields["LAD"] = "1,2,3"
+	_, err = v.ExpandLocal(s0, "LAD", -1)
+	c.Assert(err, Not(IsNil))
+}
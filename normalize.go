@@ -1,52 +1,162 @@
 package vcfgo
 
-func leftalign(pos int, ref []byte, alt []byte, seq []byte) (int, []byte, []byte, error) {
-	/* actually this isn't necessary
-	if !bytes.HasSuffix(seq, ref) {
-		return 0, ref, alt, errors.New("leftalign: sequence should end with ref")
-	}
-	*/
-	subseq := seq[:len(seq)-len(ref)]
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
 
-	quit := false
-	j := len(subseq)
+// RefSeq gives access to the sequence of a reference genome. FastaReader
+// implements it for an indexed FASTA.
+type RefSeq interface {
+	// Fetch returns the bases of chrom in the 0-based, half-open region [start, end).
+	Fetch(chrom string, start, end int) ([]byte, error)
+}
 
-	for j > 0 && !quit {
-		quit = true
-		if ref[len(ref)-1] == alt[len(alt)-1] {
-			ref = ref[:len(ref)-1]
-			alt = alt[:len(alt)-1]
-			quit = false
+// Normalize left-aligns and trims the alleles of v against ref, like
+// bcftools norm -f or vt normalize, and reports whether Pos, Reference or
+// Alternate were changed. Multi-allelic records are normalized as a whole.
+// The alleles of a changed record are upper case; a record that only differs
+// from its normal form in case is left as it is and not reported as changed.
+// The REF of v must match ref or an error wrapping ErrRefMismatch is returned
+// and v is not changed. Records with a symbolic, breakend, "*" or missing ALT
+// are only checked.
+func Normalize(v *Variant, ref RefSeq) (bool, error) {
+	start := int(v.Pos) - 1
+	seq, err := ref.Fetch(v.Chromosome, start, start+len(v.Reference))
+	if err != nil {
+		return false, err
+	}
+	if !strings.EqualFold(string(seq), v.Reference) {
+		return false, fmt.Errorf("Normalize: REF %s at %s:%d does not match the reference %s: %w", v.Reference, v.Chromosome, v.Pos, seq, ErrRefMismatch)
+	}
+	alleles := [][]byte{[]byte(strings.ToUpper(v.Reference))}
+	for _, alt := range v.Alternate {
+		if !isSequence(alt) || strings.EqualFold(alt, v.Reference) {
+			return false, nil
 		}
-		if len(ref) == 0 || len(alt) == 0 {
-			j--
-			// use j + 1 to get a slice.
-			ref = append(subseq[j:j+1], ref...)
-			alt = append(subseq[j:j+1], alt...)
-			quit = false
+		alleles = append(alleles, []byte(strings.ToUpper(alt)))
+	}
 
+	pos := int(v.Pos)
+	var out [][]byte
+	for window := 64; ; window *= 4 {
+		from := start - window
+		if from < 0 {
+			from = 0
+		}
+		ctx, err := ref.Fetch(v.Chromosome, from, start)
+		if err != nil {
+			return false, err
 		}
+		var short bool
+		pos, out, short = leftalignAlleles(int(v.Pos), alleles, bytes.ToUpper(ctx), from == 0)
+		if !short {
+			break
+		}
+	}
+	pos, out = lefttrimAlleles(pos, out)
 
+	// a record that is already normal keeps the case of its alleles.
+	changed := uint64(pos) != v.Pos || !strings.EqualFold(string(out[0]), v.Reference)
+	for i, alt := range out[1:] {
+		changed = changed || !strings.EqualFold(string(alt), v.Alternate[i])
+	}
+	if !changed {
+		return false, nil
+	}
+	v.Pos = uint64(pos)
+	v.Reference = string(out[0])
+	alts := make([]string, len(out)-1)
+	for i, alt := range out[1:] {
+		alts[i] = string(alt)
 	}
-	return pos - (len(subseq) - j), ref, alt, nil
+	v.Alternate = alts
+	return true, nil
 }
 
-func lefttrim(pos int, ref []byte, alt []byte) (int, []byte, []byte, error) {
-	if 1 == len(ref) && len(alt) == 1 {
-		return pos, ref, alt, nil
+// isSequence reports whether allele is made of bases only.
+func isSequence(allele string) bool {
+	if allele == "" {
+		return false
+	}
+	for i := 0; i < len(allele); i++ {
+		switch allele[i] {
+		case 'A', 'C', 'G', 'T', 'N', 'a', 'c', 'g', 't', 'n':
+		default:
+			return false
+		}
 	}
+	return true
+}
 
-	n := 0
-	minLen := len(ref)
-	if len(alt) < minLen {
-		minLen = len(alt)
+// leftalignAlleles removes the last base of the alleles at pos while they all
+// end with it, extending them with the preceding bases in ctx when one of them
+// would be empty. If ctx starts at the start of the chromosome it stops there;
+// otherwise it reports that ctx was too short.
+func leftalignAlleles(pos int, alleles [][]byte, ctx []byte, atStart bool) (int, [][]byte, bool) {
+	out := make([][]byte, len(alleles))
+	for i, a := range alleles {
+		out[i] = append([]byte(nil), a...)
 	}
+	j := len(ctx)
+	for {
+		empty, same := false, true
+		for _, a := range out {
+			empty = empty || len(a) == 0
+			same = same && len(a) > 0 && a[len(a)-1] == out[0][len(out[0])-1]
+		}
+		if empty {
+			if j == 0 {
+				return pos, out, true
+			}
+			j--
+			pos--
+			for i, a := range out {
+				out[i] = append([]byte{ctx[j]}, a...)
+			}
+			continue
+		}
+		if !same {
+			return pos, out, false
+		}
+		if j == 0 && atStart {
+			// a base can not be taken from before the chromosome.
+			for _, a := range out {
+				if len(a) == 1 {
+					return pos, out, false
+				}
+			}
+		}
+		for i, a := range out {
+			out[i] = a[:len(a)-1]
+		}
+	}
+}
 
-	for n+1 < minLen && alt[n] == ref[n] {
-		n++
+// lefttrimAlleles removes the first base of the alleles at pos while they all
+// start with it and have another.
+func lefttrimAlleles(pos int, alleles [][]byte) (int, [][]byte) {
+	for {
+		for _, a := range alleles {
+			if len(a) < 2 || a[0] != alleles[0][0] {
+				return pos, alleles
+			}
+		}
+		for i, a := range alleles {
+			alleles[i] = a[1:]
+		}
+		pos++
 	}
+}
 
-	alt, ref = alt[n:], ref[n:]
-	pos += n
-	return pos, ref, alt, nil
+// leftalign left-aligns a bi-allelic indel; seq ends with ref.
+func leftalign(pos int, ref []byte, alt []byte, seq []byte) (int, []byte, []byte, error) {
+	pos, out, _ := leftalignAlleles(pos, [][]byte{ref, alt}, seq[:len(seq)-len(ref)], true)
+	return pos, out[0], out[1], nil
+}
+
+func lefttrim(pos int, ref []byte, alt []byte) (int, []byte, []byte, error) {
+	pos, out := lefttrimAlleles(pos, [][]byte{ref, alt})
+	return pos, out[0], out[1], nil
 }
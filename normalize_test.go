@@ -1,6 +1,9 @@
 package vcfgo
 
 import (
+	"errors"
+	"fmt"
+	"strings"
 	"testing"
 )
 
@@ -75,3 +78,66 @@ func TestLeftTrim(t *testing.T) {
 
 	}
 }
+
+// testRef is a RefSeq of in-memory sequences.
+type testRef map[string]string
+
+func (r testRef) Fetch(chrom string, start, end int) ([]byte, error) {
+	seq, ok := r[chrom]
+	if !ok || start < 0 || end > len(seq) || start > end {
+		return nil, fmt.Errorf("no sequence for %s:%d-%d", chrom, start, end)
+	}
+	return []byte(seq[start:end]), nil
+}
+
+var normalizetests = []struct {
+	pos  uint64
+	ref  string
+	alts []string
+
+	changed bool
+	outPos  uint64
+	outRef  string
+	outAlts []string
+}{
+	// 1-based positions in GGGCACACACTT
+	{8, "CAC", []string{"C"}, true, 3, "GCA", []string{"G"}},
+	{7, "ACA", []string{"A", "ACACA"}, true, 3, "GCA", []string{"G", "GCACA"}},
+	{3, "GCA", []string{"G"}, false, 3, "GCA", []string{"G"}},
+	{11, "T", []string{"C"}, false, 11, "T", []string{"C"}},
+	// lower case alleles that are already normal are kept.
+	{3, "gca", []string{"g"}, false, 3, "gca", []string{"g"}},
+	{11, "t", []string{"C"}, false, 11, "t", []string{"C"}},
+	{8, "cac", []string{"c"}, true, 3, "GCA", []string{"G"}},
+	{10, "CT", []string{"GT"}, true, 10, "C", []string{"G"}},
+	{4, "CA", []string{"<DEL>"}, false, 4, "CA", []string{"<DEL>"}},
+	// the repeat reaches the start of the chromosome.
+	{1, "GG", []string{"G"}, false, 1, "GG", []string{"G"}},
+	{2, "GG", []string{"G"}, true, 1, "GG", []string{"G"}},
+}
+
+func TestNormalize(t *testing.T) {
+	ref := testRef{"1": "GGGCACACACTT"}
+	for _, n := range normalizetests {
+		v := &Variant{Chromosome: "1", Pos: n.pos, Reference: n.ref, Alternate: n.alts}
+		changed, err := Normalize(v, ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if changed != n.changed || v.Pos != n.outPos || v.Reference != n.outRef || strings.Join(v.Alternate, ",") != strings.Join(n.outAlts, ",") {
+			t.Errorf("%d %s %v: got %v %d %s %v", n.pos, n.ref, n.alts, changed, v.Pos, v.Reference, v.Alternate)
+		}
+	}
+
+	// a long repeat needs more than one fetch of context.
+	long := testRef{"1": "T" + strings.Repeat("CA", 200) + "G"}
+	v := &Variant{Chromosome: "1", Pos: 398, Reference: "CAC", Alternate: []string{"C"}}
+	if changed, err := Normalize(v, long); err != nil || !changed || v.Pos != 1 || v.Reference != "TCA" {
+		t.Errorf("long repeat: got %v %v %d %s", changed, err, v.Pos, v.Reference)
+	}
+
+	v = &Variant{Chromosome: "1", Pos: 4, Reference: "GA", Alternate: []string{"G"}}
+	if _, err := Normalize(v, ref); !errors.Is(err, ErrRefMismatch) || v.Pos != 4 {
+		t.Errorf("expected a REF mismatch, got %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+package vcfgo
+
+import (
+	"strconv"
+	"strings"
+)
+
+// PercentEncoding controls the %XX escaping of INFO and FORMAT values that
+// VCF 4.3 introduced for characters with a special meaning such as ';'.
+type PercentEncoding int
+
+const (
+	// PercentEncodingAuto escapes values if Header.FileFormat is 4.3 or later.
+	PercentEncodingAuto PercentEncoding = iota
+	// PercentEncodingOn always escapes values.
+	PercentEncodingOn
+	// PercentEncodingOff never escapes values; they are used as they are in the file.
+	PercentEncodingOff
+)
+
+// percentEscaper encodes the characters that VCF 4.3 reserves in INFO and FORMAT values.
+var percentEscaper = strings.NewReplacer(
+	"%", "%25", ":", "%3A", ";", "%3B", "=", "%3D", ",", "%2C",
+	"\r", "%0D", "\n", "%0A", "\t", "%09")
+
+// PercentEncode escapes the characters of s that are reserved in an INFO or
+// FORMAT value: % : ; = , CR, LF and TAB.
+func PercentEncode(s string) string {
+	if !strings.ContainsAny(s, "%:;=,\r\n\t") {
+		return s
+	}
+	return percentEscaper.Replace(s)
+}
+
+// PercentDecode replaces each %XX in s by the byte with hex value XX. A '%'
+// that is not followed by two hex digits is kept.
+func PercentDecode(s string) string {
+	i := strings.IndexByte(s, '%')
+	if i == -1 {
+		return s
+	}
+	var b strings.Builder
+	b.Grow(len(s))
+	b.WriteString(s[:i])
+	for ; i < len(s); i++ {
+		if s[i] == '%' && i+2 < len(s) {
+			if c, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// percentEncoded reports whether the values of records with this header are percent-encoded.
+func (h *Header) percentEncoded() bool {
+	if h == nil {
+		return false
+	}
+	switch h.PercentEncoding {
+	case PercentEncodingOn:
+		return true
+	case PercentEncodingOff:
+		return false
+	}
+	major, minor, _ := strings.Cut(h.FileFormat, ".")
+	ma, err := strconv.Atoi(major)
+	if err != nil {
+		return false
+	}
+	mi, _ := strconv.Atoi(minor)
+	return ma > 4 || ma == 4 && mi >= 3
+}
+
+// encodeValue escapes the strings in an INFO value given to InfoByte.Set.
+func encodeValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return PercentEncode(v)
+	case []string:
+		out := make([]string, len(v))
+		for i, s := range v {
+			out[i] = PercentEncode(s)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, s := range v {
+			out[i] = encodeValue(s)
+		}
+		return out
+	}
+	return value
+}
+
+// decodeValue is the inverse of encodeValue for a value returned by InfoByte.Get.
+func decodeValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return PercentDecode(v)
+	case []string:
+		for i, s := range v {
+			v[i] = PercentDecode(s)
+		}
+	case []interface{}:
+		for i, s := range v {
+			v[i] = decodeValue(s)
+		}
+	}
+	return value
+}
@@ -0,0 +1,103 @@
+package vcfgo
+
+import (
+	"bytes"
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+type PercentSuite struct{}
+
+var _ = Suite(&PercentSuite{})
+
+func percentVCF(version string) string {
+	return `##fileformat=VCFv` + version + `
+##INFO=<ID=NOTE,Number=1,Type=String,Description="Note">
+##INFO=<ID=TAGS,Number=.,Type=String,Description="Tags">
+##INFO=<ID=DP,Number=1,Type=Integer,Description="Depth">
+##INFO=<ID=DB,Number=0,Type=Flag,Description="dbSNP">
+##FORMAT=<ID=GT,Number=1,Type=String,Description="Genotype">
+##FORMAT=<ID=FT,Number=1,Type=String,Description="Sample filter">
+#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO	FORMAT	S1
+1	100	.	A	C	.	PASS	NOTE=a%3Bb%3Dc%25;TAGS=x%2Cy,z;DP=3;DB	GT:FT	0/1:low%3Adepth
+`
+}
+
+func (s *PercentSuite) TestEncode(c *C) {
+	for _, t := range []struct{ plain, encoded string }{
+		{"abc", "abc"},
+		{"a;b=c", "a%3Bb%3Dc"},
+		{"50%,1:2", "50%25%2C1%3A2"},
+		{"tab\there\r\n", "tab%09here%0D%0A"},
+	} {
+		c.Assert(PercentEncode(t.plain), Equals, t.encoded)
+		c.Assert(PercentDecode(t.encoded), Equals, t.plain)
+	}
+	c.Assert(PercentDecode("%3b%zz%4"), Equals, ";%zz%4")
+	c.Assert(PercentDecode("100%"), Equals, "100%")
+}
+
+func (s *PercentSuite) TestRead(c *C) {
+	rdr, err := NewReader(strings.NewReader(percentVCF("4.3")), false)
+	c.Assert(err, IsNil)
+	v := rdr.Read()
+	c.Assert(v, Not(IsNil))
+
+	note, err := v.Info().Get("NOTE")
+	c.Assert(err, IsNil)
+	c.Assert(note, Equals, "a;b=c%")
+	tags, err := v.Info().Get("TAGS")
+	c.Assert(err, IsNil)
+	c.Assert(tags, DeepEquals, []string{"x,y", "z"})
+	dp, _ := v.Info().Get("DP")
+	c.Assert(dp, Equals, 3)
+	db, _ := v.Info().Get("DB")
+	c.Assert(db, Equals, true)
+
+	ft, err := v.GetGenotypeField(v.Samples[0], "FT", "")
+	c.Assert(err, IsNil)
+	c.Assert(ft, Equals, "low:depth")
+	c.Assert(v.Samples[0].Fields["FT"], Equals, "low%3Adepth")
+
+	// VCF 4.2 values are used as they are unless asked otherwise.
+	for _, t := range []struct {
+		version string
+		opt     PercentEncoding
+		note    string
+	}{
+		{"4.2", PercentEncodingAuto, "a%3Bb%3Dc%25"},
+		{"4.2", PercentEncodingOn, "a;b=c%"},
+		{"4.4", PercentEncodingAuto, "a;b=c%"},
+		{"4.3", PercentEncodingOff, "a%3Bb%3Dc%25"},
+	} {
+		rdr, err := NewReaderWithOptions(strings.NewReader(percentVCF(t.version)), ReaderOptions{PercentEncoding: t.opt})
+		c.Assert(err, IsNil)
+		note, _ := rdr.Read().Info().Get("NOTE")
+		c.Assert(note, Equals, t.note, Commentf("%+v", t))
+	}
+}
+
+func (s *PercentSuite) TestWrite(c *C) {
+	rdr, err := NewReader(strings.NewReader(percentVCF("4.3")), false)
+	c.Assert(err, IsNil)
+	v := rdr.Read()
+	c.Assert(v.Info().Set("NOTE", "x;y=1"), IsNil)
+	c.Assert(v.Info().Set("TAGS", []string{"p,q", "r%"}), IsNil)
+	c.Assert(v.Info().Set("DP", 4), IsNil)
+	c.Assert(v.Info().String(), Equals, "NOTE=x%3By%3D1;TAGS=p%2Cq,r%25;DP=4;DB")
+	note, _ := v.Info().Get("NOTE")
+	c.Assert(note, Equals, "x;y=1")
+
+	var out bytes.Buffer
+	w, err := NewWriter(&out, rdr.Header)
+	c.Assert(err, IsNil)
+	c.Assert(w.WriteVariant(v), IsNil)
+	c.Assert(strings.HasSuffix(out.String(), "\tNOTE=x%3By%3D1;TAGS=p%2Cq,r%25;DP=4;DB\tGT:FT\t0/1:low%3Adepth\n"), Equals, true)
+
+	rdr, err = NewReader(strings.NewReader(percentVCF("4.2")), false)
+	c.Assert(err, IsNil)
+	v = rdr.Read()
+	c.Assert(v.Info().Set("NOTE", "x;y"), IsNil)
+	c.Assert(strings.HasPrefix(v.Info().String(), "NOTE=x;y;"), Equals, true)
+}
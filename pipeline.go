@@ -0,0 +1,181 @@
+package vcfgo
+
+import (
+	"errors"
+	"io"
+	"math"
+	"sync"
+)
+
+// parseBatchSize is the number of records handed to a worker at a time.
+var parseBatchSize = 512
+
+// parseBatch is a run of consecutive records that is parsed by one worker.
+type parseBatch struct {
+	lines    [][]byte
+	first    int64
+	variants []*Variant
+	verr     *VCFError
+	// err is an error from reading the input after the last line, at line errLine.
+	err     error
+	errLine int64
+	done    chan struct{}
+}
+
+// pipeline reads records on one goroutine, parses them on several and lets
+// Read return them in the order of the file.
+type pipeline struct {
+	order chan *parseBatch
+	quit  chan struct{}
+	stop  sync.Once
+	// running tracks the producer and the workers so that close can wait for them.
+	running sync.WaitGroup
+
+	batchSize int
+
+	cur *parseBatch
+	// i is the next variant of cur to return and e the next of its errors to report.
+	i, e int
+}
+
+func (vr *Reader) startPipeline() *pipeline {
+	p := &pipeline{order: make(chan *parseBatch, 4*vr.workers), quit: make(chan struct{}), batchSize: parseBatchSize}
+	work := make(chan *parseBatch, vr.workers)
+	p.running.Add(vr.workers + 1)
+	for i := 0; i < vr.workers; i++ {
+		go func() {
+			defer p.running.Done()
+			for b := range work {
+				vr.parseBatch(b)
+			}
+		}()
+	}
+	go vr.produce(p, work)
+	vr.pipe = p
+	return p
+}
+
+// produce reads the records of the file in batches and sends each batch both
+// to the workers and, in order, to Read.
+func (vr *Reader) produce(p *pipeline, work chan<- *parseBatch) {
+	defer p.running.Done()
+	defer close(p.order)
+	defer close(work)
+	lineNumber, size := vr.LineNumber, p.batchSize
+	newBatch := func() *parseBatch {
+		return &parseBatch{lines: make([][]byte, 0, size), first: lineNumber + 1, done: make(chan struct{})}
+	}
+	send := func(b *parseBatch) bool {
+		select {
+		case p.order <- b:
+		case <-p.quit:
+			return false
+		}
+		select {
+		case work <- b:
+		case <-p.quit:
+			return false
+		}
+		return true
+	}
+	b := newBatch()
+	for {
+		line, err := vr.readRecord()
+		// as in the serial Read, a blank line is a record; only the end of the input is not.
+		if len(line) > 0 || err == nil {
+			lineNumber++
+			b.lines = append(b.lines, line)
+		}
+		if err != nil {
+			if err != io.EOF {
+				b.err, b.errLine = err, lineNumber
+			}
+			send(b)
+			return
+		}
+		if len(b.lines) == size {
+			if !send(b) {
+				return
+			}
+			b = newBatch()
+		}
+	}
+}
+
+// readRecord returns the next record as a line of VCF text without the newline.
+// The line is newly allocated as the Variant parsed from it keeps a reference to it.
+func (vr *Reader) readRecord() ([]byte, error) {
+	if vr.bcf != nil {
+		return vr.bcf.next(vr.buf)
+	}
+	line, err := vr.buf.ReadBytes('\n')
+	if n := len(line); n > 0 && line[n-1] == '\n' {
+		line = line[:n-1]
+	}
+	return line, err
+}
+
+func (vr *Reader) parseBatch(b *parseBatch) {
+	b.verr = NewVCFError()
+	b.variants = make([]*Variant, len(b.lines))
+	vr.Header.RLock()
+	for i, line := range b.lines {
+		b.variants[i] = vr.parse(makeFields(line), b.first+int64(i), b.verr)
+	}
+	vr.Header.RUnlock()
+	b.verr.Add(b.err, b.errLine)
+	b.lines = nil
+	close(b.done)
+}
+
+// readParallel is Read for a Reader with Workers set.
+func (vr *Reader) readParallel() *Variant {
+	p := vr.pipe
+	if p == nil {
+		p = vr.startPipeline()
+	}
+	for {
+		if b := p.cur; b != nil && p.i < len(b.variants) {
+			v := b.variants[p.i]
+			b.variants[p.i] = nil
+			p.i++
+			vr.LineNumber = v.LineNumber
+			p.report(vr, v.LineNumber)
+			return v
+		}
+		if p.cur != nil {
+			p.report(vr, math.MaxInt64)
+			p.cur = nil
+		}
+		var b *parseBatch
+		var ok bool
+		select {
+		case b, ok = <-p.order:
+		case <-p.quit:
+		}
+		if !ok {
+			return nil
+		}
+		select {
+		case <-b.done:
+		case <-p.quit:
+			return nil
+		}
+		p.cur, p.i, p.e = b, 0, 0
+	}
+}
+
+// report moves the errors of the current batch up to line to the Reader.
+func (p *pipeline) report(vr *Reader, line int64) {
+	e := p.cur.verr
+	for ; p.e < len(e.Msgs) && e.Lines[p.e] <= line; p.e++ {
+		vr.verr.Add(errors.New(e.Msgs[p.e]), e.Lines[p.e])
+	}
+}
+
+// close stops the goroutines of the pipeline and waits for them to return, so
+// that the input can be closed once nothing reads from it.
+func (p *pipeline) close() {
+	p.stop.Do(func() { close(p.quit) })
+	p.running.Wait()
+}
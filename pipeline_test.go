@@ -0,0 +1,108 @@
+package vcfgo
+
+import (
+	"bytes"
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+type PipelineSuite struct {
+	text string
+}
+
+var _ = Suite(&PipelineSuite{})
+
+func (s *PipelineSuite) SetUpSuite(c *C) {
+	fixture := readFixture(c)
+	i := strings.Index(fixture, "\nchr")
+	header, records := fixture[:i+1], fixture[i+1:]
+	// the fixture reports an error on every record as GQ is a Float. The blank
+	// line is a record with too few fields for both readers.
+	s.text = header + records + "\n" + strings.Repeat(records, 299)
+}
+
+func (s *PipelineSuite) SetUpTest(c *C) {
+	parseBatchSize = 7
+}
+
+func (s *PipelineSuite) TearDownTest(c *C) {
+	parseBatchSize = 512
+}
+
+// assertSameAsSerial reads data with and without workers in lock step.
+func assertSameAsSerial(c *C, data []byte, opts ReaderOptions) int {
+	serial, err := NewReaderWithOptions(bytes.NewReader(data), opts)
+	c.Assert(serial, Not(IsNil))
+	opts.Workers = 4
+	parallel, err2 := NewReaderWithOptions(bytes.NewReader(data), opts)
+	c.Assert(parallel, Not(IsNil))
+	c.Assert(err2, DeepEquals, err)
+	n := 0
+	for {
+		a, b := serial.Read(), parallel.Read()
+		if a == nil {
+			c.Assert(b, IsNil)
+			break
+		}
+		c.Assert(b, Not(IsNil))
+		c.Assert(b.String(), Equals, a.String())
+		c.Assert(b.LineNumber, Equals, a.LineNumber)
+		c.Assert(parallel.LineNumber, Equals, serial.LineNumber)
+		c.Assert(parallel.verr.Lines, DeepEquals, serial.verr.Lines)
+		c.Assert(parallel.verr.Msgs, DeepEquals, serial.verr.Msgs)
+		n++
+	}
+	c.Assert(parallel.Error(), DeepEquals, serial.Error())
+	c.Assert(parallel.Close(), IsNil)
+	return n
+}
+
+func (s *PipelineSuite) TestOrder(c *C) {
+	c.Assert(assertSameAsSerial(c, []byte(s.text), ReaderOptions{}), Equals, 1501)
+	c.Assert(assertSameAsSerial(c, []byte(s.text), ReaderOptions{LazySamples: true}), Equals, 1501)
+	c.Assert(assertSameAsSerial(c, makeBGZF(c, []byte(s.text), 1000), ReaderOptions{}), Equals, 1501)
+	// no trailing newline.
+	c.Assert(assertSameAsSerial(c, []byte(strings.TrimSuffix(s.text, "\n")), ReaderOptions{}), Equals, 1501)
+}
+
+func (s *PipelineSuite) TestBCF(c *C) {
+	rdr := bcfTextReader(c)
+	var out bytes.Buffer
+	w, err := NewWriterWithOptions(&out, rdr.Header, WriterOptions{BCF: true})
+	c.Assert(err, IsNil)
+	for v := rdr.Read(); v != nil; v = rdr.Read() {
+		for i := 0; i < 20; i++ {
+			c.Assert(w.WriteVariant(v), IsNil)
+		}
+	}
+	c.Assert(w.Close(), IsNil)
+	c.Assert(assertSameAsSerial(c, out.Bytes(), ReaderOptions{}), Equals, 40)
+}
+
+func (s *PipelineSuite) TestClose(c *C) {
+	rdr, err := NewReaderWithOptions(strings.NewReader(s.text), ReaderOptions{Workers: 3})
+	c.Assert(rdr, Not(IsNil), Commentf("%v", err))
+	for i := 0; i < 10; i++ {
+		c.Assert(rdr.Read(), Not(IsNil))
+	}
+	c.Assert(rdr.LineNumber, Equals, rdr.Read().LineNumber)
+	c.Assert(rdr.Close(), IsNil)
+	c.Assert(rdr.Close(), IsNil)
+	for v := rdr.Read(); v != nil; v = rdr.Read() {
+	}
+}
+
+func (s *PipelineSuite) TestCloseBGZF(c *C) {
+	// run with -race: Close must not release the decompressor while the
+	// producer is still reading from it.
+	data := makeBGZF(c, []byte(s.text), 1000)
+	for i := 0; i < 20; i++ {
+		rdr, err := NewReaderWithOptions(bytes.NewReader(data), ReaderOptions{Workers: 4})
+		c.Assert(rdr, Not(IsNil), Commentf("%v", err))
+		for j := 0; j < i; j++ {
+			c.Assert(rdr.Read(), Not(IsNil))
+		}
+		c.Assert(rdr.Close(), IsNil)
+	}
+}
@@ -0,0 +1,151 @@
+package vcfgo
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strconv"
+)
+
+// QueryIterator returns the variants overlapping a region. It is created by Reader.Query.
+type QueryIterator struct {
+	vr         *Reader
+	chunks     []chunk
+	i          int
+	inChunk    bool
+	chrom      string
+	start, end int64
+	done       bool
+}
+
+// SetIndex sets the index used by Query.
+func (vr *Reader) SetIndex(idx *Index) {
+	vr.index = idx
+}
+
+// loadIndex finds the index next to a file opened with Open.
+func (vr *Reader) loadIndex() error {
+	if vr.index != nil {
+		return nil
+	}
+	if vr.path == "" {
+		return errors.New("vcfgo: Query requires an index: use SetIndex or Open")
+	}
+	idx, err := OpenIndex(vr.path + ".tbi")
+	if os.IsNotExist(err) {
+		idx, err = OpenIndex(vr.path + ".csi")
+	}
+	if err != nil {
+		return err
+	}
+	vr.index = idx
+	return nil
+}
+
+// refID finds chrom in the index or, if the index has no names, in the contigs of the header.
+func (vr *Reader) refID(chrom string) int {
+	if len(vr.index.Names) > 0 {
+		return vr.index.RefID(chrom)
+	}
+	if vr.bcf != nil {
+		for i, c := range vr.bcf.contigs {
+			if c == chrom {
+				return i
+			}
+		}
+		return -1
+	}
+	for i, c := range vr.Header.Contigs {
+		if c["ID"] == chrom {
+			return i
+		}
+	}
+	return -1
+}
+
+// Query returns an iterator over the variants on chrom whose Start() and End()
+// overlap the 0-based, half-open region [start, end). The input must be BGZF
+// compressed and seekable. If no index was given with SetIndex, the index is
+// read from the path given to Open with ".tbi" or else ".csi" appended.
+// Query moves the position of the underlying file, so it should not be
+// interleaved with Read. The LineNumber of the variants it returns is not meaningful.
+func (vr *Reader) Query(chrom string, start, end int) (*QueryIterator, error) {
+	if vr.bgzf == nil {
+		return nil, errors.New("vcfgo: Query requires BGZF compressed input")
+	}
+	if _, ok := vr.r.(io.Seeker); !ok {
+		return nil, errNotSeekable
+	}
+	if err := vr.loadIndex(); err != nil {
+		return nil, err
+	}
+	if start < 0 {
+		start = 0
+	}
+	it := &QueryIterator{vr: vr, chrom: chrom, start: int64(start), end: int64(end)}
+	if end > start {
+		it.chunks = vr.index.chunks(vr.refID(chrom), it.start, it.end)
+	}
+	return it, nil
+}
+
+// Read returns the next overlapping variant or nil when there are no more.
+// As with Reader.Read, errors are available from Reader.Error.
+func (it *QueryIterator) Read() *Variant {
+	vr := it.vr
+	bg := vr.bgzf
+	for !it.done && it.i < len(it.chunks) {
+		c := it.chunks[it.i]
+		if !it.inChunk {
+			if err := bg.seek(c.beg); err != nil {
+				vr.verr.Add(err, vr.LineNumber)
+				it.done = true
+				break
+			}
+			it.inChunk = true
+		}
+		if bg.virtualOffset() >= c.end {
+			it.i++
+			it.inChunk = false
+			continue
+		}
+		var line []byte
+		var err error
+		if vr.bcf != nil {
+			line, err = vr.bcf.next(bg)
+		} else {
+			// the variant keeps a reference to the line so it can not be reused.
+			line, err = bg.readLine(nil)
+		}
+		if err != nil {
+			if err != io.EOF {
+				vr.verr.Add(err, vr.LineNumber)
+			}
+			it.done = true
+			break
+		}
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		fields := makeFields(line)
+		if len(fields) < 8 || unsafeString(fields[0]) != it.chrom {
+			continue
+		}
+		// the file is sorted so nothing after a record starting past the end can overlap.
+		if pos, err := strconv.ParseInt(unsafeString(fields[1]), 10, 64); err == nil && pos-1 >= it.end {
+			it.done = true
+			break
+		}
+		v := vr.Parse(fields)
+		if int64(v.Start()) >= it.end || int64(v.End()) <= it.start {
+			continue
+		}
+		switch vr.judge(v) {
+		case Warn:
+			return v
+		case Abort:
+			it.done = true
+		}
+	}
+	return nil
+}
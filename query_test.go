@@ -0,0 +1,536 @@
+package vcfgo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+type QuerySuite struct {
+	path  string
+	lines []string
+	vars  []*Variant
+	recs  []indexedRecord
+}
+
+var _ = Suite(&QuerySuite{})
+
+type indexedRecord struct {
+	chrom      string
+	beg, end   int64
+	start, off VirtualOffset
+}
+
+// buildIndex indexes recs with an IndexBuilder.
+func buildIndex(c *C, recs []indexedRecord, format IndexFormat, minShift, depth int) *Index {
+	b, err := NewIndexBuilder(format, minShift, depth)
+	c.Assert(err, IsNil)
+	for _, r := range recs {
+		c.Assert(b.Add(r.chrom, r.beg, r.end, r.start, r.off), IsNil)
+	}
+	return b.Index()
+}
+
+// htslibIndex encodes recs as htslib's hts_idx_save does for tabix and for
+// bcftools index on a VCF: chunks are merged within a BGZF block, bins whose
+// chunks span less than 64KB of compressed data are folded into their parents
+// (compress_binning), each reference has a pseudo-bin and the file ends with
+// n_no_coor. IndexBuilder writes leaf bins, so this checks that the reader
+// does not rely on them.
+func htslibIndex(c *C, recs []indexedRecord, format IndexFormat) []byte {
+	const minShift, depth = 14, 5
+	type ref struct {
+		name    string
+		bins    map[uint32][]chunk
+		loff    map[uint32]VirtualOffset
+		linear  []VirtualOffset
+		lastBin uint32
+		first   VirtualOffset
+		last    VirtualOffset
+		n       uint64
+	}
+	var refs []*ref
+	for _, r := range recs {
+		if len(refs) == 0 || refs[len(refs)-1].name != r.chrom {
+			refs = append(refs, &ref{name: r.chrom, bins: map[uint32][]chunk{}, loff: map[uint32]VirtualOffset{}, lastBin: 1 << 31, first: r.start})
+		}
+		x := refs[len(refs)-1]
+		b := reg2bin(r.beg, r.end, minShift, depth)
+		if cs := x.bins[b]; b == x.lastBin && len(cs) > 0 && cs[len(cs)-1].end == r.start {
+			cs[len(cs)-1].end = r.off
+		} else {
+			x.bins[b] = append(x.bins[b], chunk{r.start, r.off})
+		}
+		if _, ok := x.loff[b]; !ok {
+			x.loff[b] = r.start
+		}
+		x.lastBin = b
+		end := r.end
+		if end <= r.beg {
+			end = r.beg + 1
+		}
+		for w := r.beg >> minShift; w <= (end-1)>>minShift; w++ {
+			for int64(len(x.linear)) <= w {
+				x.linear = append(x.linear, 1<<63)
+			}
+			if x.linear[w] == 1<<63 {
+				x.linear[w] = r.start
+			}
+		}
+		x.last, x.n = r.off, x.n+1
+	}
+	for _, x := range refs {
+		// leading empty windows get the start of the reference, the others the window before.
+		for w := range x.linear {
+			if x.linear[w] != 1<<63 {
+				continue
+			}
+			if w == 0 {
+				x.linear[w] = x.first
+			} else {
+				x.linear[w] = x.linear[w-1]
+			}
+		}
+		for l := depth; l > 0; l-- {
+			first := binFirst(l)
+			var ids []uint32
+			for b := range x.bins {
+				if b >= first && b < binFirst(l+1) {
+					ids = append(ids, b)
+				}
+			}
+			sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+			for _, b := range ids {
+				cs := x.bins[b]
+				if cs[len(cs)-1].end.Compressed()-cs[0].beg.Compressed() >= 0x10000 {
+					continue
+				}
+				p := binParent(b)
+				x.bins[p] = append(x.bins[p], cs...)
+				if lo, ok := x.loff[p]; !ok || x.loff[b] < lo {
+					x.loff[p] = x.loff[b]
+				}
+				delete(x.bins, b)
+			}
+		}
+		for b, cs := range x.bins {
+			sort.Slice(cs, func(i, j int) bool { return cs[i].beg < cs[j].beg })
+			merged := cs[:1]
+			for _, ch := range cs[1:] {
+				if last := &merged[len(merged)-1]; last.end.Compressed() >= ch.beg.Compressed() {
+					if ch.end > last.end {
+						last.end = ch.end
+					}
+					continue
+				}
+				merged = append(merged, ch)
+			}
+			x.bins[b] = merged
+		}
+	}
+
+	var out bytes.Buffer
+	put := func(vals ...interface{}) {
+		for _, v := range vals {
+			c.Assert(binary.Write(&out, binary.LittleEndian, v), IsNil)
+		}
+	}
+	var names bytes.Buffer
+	for _, x := range refs {
+		names.WriteString(x.name)
+		names.WriteByte(0)
+	}
+	// TBX_VCF, sequence in column 1, begin in 2, no end column, '#' comments, no skipped lines.
+	conf := []int32{2, 1, 2, 0, '#', 0}
+	if format == TBI {
+		out.WriteString("TBI\x01")
+		put(int32(len(refs)), conf, int32(names.Len()))
+		out.Write(names.Bytes())
+	} else {
+		var aux bytes.Buffer
+		binary.Write(&aux, binary.LittleEndian, conf)
+		binary.Write(&aux, binary.LittleEndian, int32(names.Len()))
+		aux.Write(names.Bytes())
+		out.WriteString("CSI\x01")
+		put(int32(minShift), int32(depth), int32(aux.Len()))
+		out.Write(aux.Bytes())
+		put(int32(len(refs)))
+	}
+	meta := binFirst(depth+1) + 1
+	for _, x := range refs {
+		var ids []uint32
+		for b := range x.bins {
+			ids = append(ids, b)
+		}
+		// htslib writes the bins in hash order; any order other than IndexBuilder's will do.
+		sort.Slice(ids, func(i, j int) bool { return ids[i] > ids[j] })
+		put(int32(len(ids) + 1))
+		for _, b := range ids {
+			put(b)
+			if format == CSI {
+				put(uint64(x.loff[b]))
+			}
+			put(int32(len(x.bins[b])))
+			for _, ch := range x.bins[b] {
+				put(uint64(ch.beg), uint64(ch.end))
+			}
+		}
+		put(meta)
+		if format == CSI {
+			put(uint64(0))
+		}
+		put(int32(2), uint64(x.first), uint64(x.last), x.n, uint64(0))
+		if format == TBI {
+			put(int32(len(x.linear)))
+			for _, o := range x.linear {
+				put(uint64(o))
+			}
+		}
+	}
+	put(uint64(0))
+
+	var z bytes.Buffer
+	w := NewBGZFWriter(&z)
+	_, err := w.Write(out.Bytes())
+	c.Assert(err, IsNil)
+	c.Assert(w.Close(), IsNil)
+	return z.Bytes()
+}
+
+func (s *QuerySuite) SetUpSuite(c *C) {
+	rdr, err := Open("examples/test.query.vcf", true)
+	c.Assert(err, IsNil)
+	defer rdr.Close()
+
+	s.path = filepath.Join(c.MkDir(), "query.vcf.gz")
+	w, err := CreateWithOptions(s.path, rdr.Header, WriterOptions{Index: TBI})
+	c.Assert(err, IsNil)
+	// the suites are run once for each TestingT in the test binary.
+	s.vars, s.lines, s.recs = nil, nil, nil
+	for v := rdr.Read(); v != nil; v = rdr.Read() {
+		// put the second half on another chromosome to check that references are kept apart.
+		if len(s.vars) > 439 {
+			v.Chromosome = "chr2"
+		}
+		c.Assert(w.WriteVariant(v), IsNil)
+		start, end := w.Offsets()
+		s.recs = append(s.recs, indexedRecord{v.Chromosome, int64(v.Start()), int64(v.End()), start, end})
+		s.vars = append(s.vars, v)
+		s.lines = append(s.lines, v.String())
+	}
+	c.Assert(w.Close(), IsNil)
+}
+
+// expected finds the overlapping records by brute force.
+func (s *QuerySuite) expected(chrom string, start, end int) []string {
+	var out []string
+	for i, v := range s.vars {
+		if v.Chromosome == chrom && int(v.Start()) < end && int(v.End()) > start {
+			out = append(out, s.lines[i])
+		}
+	}
+	return out
+}
+
+func (s *QuerySuite) query(c *C, rdr *Reader, chrom string, start, end int) []string {
+	it, err := rdr.Query(chrom, start, end)
+	c.Assert(err, IsNil)
+	var out []string
+	for v := it.Read(); v != nil; v = it.Read() {
+		out = append(out, v.String())
+	}
+	return out
+}
+
+var queryRegions = []struct {
+	chrom      string
+	start, end int
+}{
+	{"chr1", 0, 1},
+	{"chr1", 30547, 30548},
+	{"chr1", 30000, 100000},
+	{"chr1", 0, 1 << 29},
+	{"chr1", 500000, 700000},
+	{"chr1", 753404, 753406},
+	{"chr2", 0, 1 << 29},
+	{"chr2", 900000, 1000000},
+	{"chr2", 1227195, 1227196},
+	{"chr3", 0, 1 << 29},
+}
+
+func (s *QuerySuite) TestQuery(c *C) {
+	rdr, err := Open(s.path, true)
+	c.Assert(err, IsNil)
+	defer rdr.Close()
+
+	total := 0
+	for _, r := range queryRegions {
+		exp := s.expected(r.chrom, r.start, r.end)
+		total += len(exp)
+		got := s.query(c, rdr, r.chrom, r.start, r.end)
+		c.Assert(got, DeepEquals, exp, Commentf("%v", r))
+	}
+	c.Assert(total > 879, Equals, true)
+	c.Assert(rdr.Error(), IsNil)
+}
+
+func (s *QuerySuite) TestQueryCSI(c *C) {
+	for _, scheme := range [][2]int{{14, 5}, {12, 7}, {18, 4}} {
+		idx := buildIndex(c, s.recs, CSI, scheme[0], scheme[1])
+		var buf bytes.Buffer
+		_, err := idx.WriteTo(&buf)
+		c.Assert(err, IsNil)
+		idx, err = ReadIndex(&buf)
+		c.Assert(err, IsNil)
+		c.Assert(idx.Format, Equals, CSI)
+		c.Assert(idx.MinShift, Equals, scheme[0])
+		c.Assert(idx.Depth, Equals, scheme[1])
+
+		rdr, err := Open(s.path, true)
+		c.Assert(err, IsNil)
+		rdr.SetIndex(idx)
+		for _, r := range queryRegions {
+			c.Assert(s.query(c, rdr, r.chrom, r.start, r.end), DeepEquals, s.expected(r.chrom, r.start, r.end), Commentf("%v %v", scheme, r))
+		}
+		rdr.Close()
+	}
+}
+
+func (s *QuerySuite) TestHtslibLayout(c *C) {
+	for _, format := range []IndexFormat{TBI, CSI} {
+		idx, err := ReadIndex(bytes.NewReader(htslibIndex(c, s.recs, format)))
+		c.Assert(err, IsNil)
+		c.Assert(idx.Format, Equals, format)
+		c.Assert(idx.Names, DeepEquals, []string{"chr1", "chr2"})
+		c.Assert(idx.NoCoor, Equals, uint64(0))
+
+		rdr, err := Open(s.path, true)
+		c.Assert(err, IsNil)
+		rdr.SetIndex(idx)
+		for _, r := range queryRegions {
+			c.Assert(s.query(c, rdr, r.chrom, r.start, r.end), DeepEquals, s.expected(r.chrom, r.start, r.end), Commentf("%v %v", format, r))
+		}
+		for i := 0; i < len(s.vars); i += 7 {
+			v := s.vars[i]
+			c.Assert(s.query(c, rdr, v.Chromosome, int(v.Start()), int(v.Start())+1), DeepEquals, s.expected(v.Chromosome, int(v.Start()), int(v.Start())+1))
+		}
+		rdr.Close()
+	}
+}
+
+// htslibFixture is examples/test.query.vcf compressed and indexed by htslib:
+//
+//	bgzip -c examples/test.query.vcf > examples/test.query.vcf.gz
+//	tabix -p vcf examples/test.query.vcf.gz
+//	bcftools index examples/test.query.vcf.gz
+const htslibFixture = "examples/test.query.vcf.gz"
+
+// checkFixture queries htslibFixture with the index at htslibFixture + ext
+// and compares the records with those of examples/test.query.vcf.
+func (s *QuerySuite) checkFixture(c *C, ext string) *Index {
+	if _, err := os.Stat(htslibFixture + ext); os.IsNotExist(err) {
+		c.Skip(htslibFixture + ext + " is missing; see htslibFixture")
+	}
+	idx, err := OpenIndex(htslibFixture + ext)
+	c.Assert(err, IsNil)
+	c.Assert(idx.Names, DeepEquals, []string{"chr1"})
+
+	plain, err := Open("examples/test.query.vcf", true)
+	c.Assert(err, IsNil)
+	defer plain.Close()
+	var vars []*Variant
+	var lines []string
+	for v := plain.Read(); v != nil; v = plain.Read() {
+		vars = append(vars, v)
+		lines = append(lines, v.String())
+	}
+	expected := func(start, end int) []string {
+		var out []string
+		for i, v := range vars {
+			if int(v.Start()) < end && int(v.End()) > start {
+				out = append(out, lines[i])
+			}
+		}
+		return out
+	}
+
+	rdr, err := Open(htslibFixture, true)
+	c.Assert(err, IsNil)
+	defer rdr.Close()
+	rdr.SetIndex(idx)
+	for _, r := range queryRegions {
+		if r.chrom == "chr1" {
+			c.Assert(s.query(c, rdr, r.chrom, r.start, r.end), DeepEquals, expected(r.start, r.end), Commentf("%s %v", ext, r))
+		}
+	}
+	for i := 0; i < len(vars); i += 7 {
+		start := int(vars[i].Start())
+		c.Assert(s.query(c, rdr, "chr1", start, start+1), DeepEquals, expected(start, start+1), Commentf("%s %d", ext, start))
+	}
+	return idx
+}
+
+func (s *QuerySuite) TestTabixFixture(c *C) {
+	idx := s.checkFixture(c, ".tbi")
+	c.Assert(idx.Format, Equals, TBI)
+}
+
+func (s *QuerySuite) TestCSIFixture(c *C) {
+	idx := s.checkFixture(c, ".csi")
+	c.Assert(idx.Format, Equals, CSI)
+	c.Assert(idx.MinShift, Equals, 14)
+	c.Assert(idx.Depth, Equals, 5)
+}
+
+func (s *QuerySuite) TestQueryCSIWithoutNames(c *C) {
+	// bcftools writes no names for BCF so the contigs in the header give the order.
+	idx := buildIndex(c, s.recs, CSI, 14, 6)
+	idx.Names = nil
+	var buf bytes.Buffer
+	_, err := idx.WriteTo(&buf)
+	c.Assert(err, IsNil)
+	idx, err = ReadIndex(&buf)
+	c.Assert(err, IsNil)
+	c.Assert(len(idx.Names), Equals, 0)
+
+	rdr, err := Open(s.path, true)
+	c.Assert(err, IsNil)
+	defer rdr.Close()
+	rdr.SetIndex(idx)
+	rdr.Header.Contigs = []map[string]string{{"ID": "chr1"}, {"ID": "chr2"}}
+	c.Assert(s.query(c, rdr, "chr2", 900000, 1000000), DeepEquals, s.expected("chr2", 900000, 1000000))
+	c.Assert(s.query(c, rdr, "chr1", 0, 100000), DeepEquals, s.expected("chr1", 0, 100000))
+}
+
+func (s *QuerySuite) TestLongChromosome(c *C) {
+	// a record past the 512Mbp limit of tabix.
+	recs := []indexedRecord{{"chr1", 1 << 30, 1<<30 + 1, NewVirtualOffset(100, 0), NewVirtualOffset(100, 50)}}
+	idx := buildIndex(c, recs, CSI, 14, 6)
+	c.Assert(idx.chunks(0, 1<<30, 1<<30+10), DeepEquals, []chunk{{recs[0].start, recs[0].off}})
+	c.Assert(idx.chunks(0, 0, 1<<29), IsNil)
+
+	tbi := buildIndex(c, []indexedRecord{{"chr1", 10, 11, NewVirtualOffset(100, 0), NewVirtualOffset(100, 50)}}, TBI, tabixMinShift, tabixDepth)
+	c.Assert(tbi.chunks(0, 0, 100), HasLen, 1)
+	c.Assert(tbi.chunks(0, 1<<30, 1<<30+10), IsNil)
+}
+
+func (s *QuerySuite) TestQueryEveryRecord(c *C) {
+	rdr, err := Open(s.path, true)
+	c.Assert(err, IsNil)
+	defer rdr.Close()
+	for i := 0; i < len(s.vars); i += 3 {
+		v := s.vars[i]
+		got := s.query(c, rdr, v.Chromosome, int(v.Start()), int(v.Start())+1)
+		c.Assert(len(got) > 0, Equals, true)
+		found := false
+		for _, g := range got {
+			found = found || g == s.lines[i]
+		}
+		c.Assert(found, Equals, true, Commentf("%s", s.lines[i]))
+	}
+}
+
+func (s *QuerySuite) TestQueryErrors(c *C) {
+	rdr, err := Open("examples/test.query.vcf", true)
+	c.Assert(err, IsNil)
+	_, err = rdr.Query("chr1", 0, 100)
+	c.Assert(err, ErrorMatches, ".*BGZF.*")
+	rdr.Close()
+
+	f, err := os.Open(s.path)
+	c.Assert(err, IsNil)
+	rdr, err = NewReader(f, true)
+	c.Assert(err, IsNil)
+	_, err = rdr.Query("chr1", 0, 100)
+	c.Assert(err, ErrorMatches, ".*requires an index.*")
+
+	idx, err := OpenIndex(s.path + ".tbi")
+	c.Assert(err, IsNil)
+	c.Assert(idx.Format, Equals, TBI)
+	c.Assert(idx.Names, DeepEquals, []string{"chr1", "chr2"})
+	rdr.SetIndex(idx)
+	c.Assert(s.query(c, rdr, "chr2", 0, 1<<29), DeepEquals, s.expected("chr2", 0, 1<<29))
+	rdr.Close()
+
+	_, err = ReadIndex(strings.NewReader("not an index"))
+	c.Assert(err, ErrorMatches, "vcfgo: unknown index format.*")
+}
+
+func (s *QuerySuite) TestBins(c *C) {
+	c.Assert(reg2bin(0, 1, 14, 5), Equals, uint32(4681))
+	c.Assert(reg2bin(0, 1<<14+1, 14, 5), Equals, uint32(585))
+	c.Assert(reg2bin(0, 1<<29, 14, 5), Equals, uint32(0))
+	c.Assert(reg2bins(0, 1, 14, 5), DeepEquals, []uint32{0, 1, 9, 73, 585, 4681})
+	for _, r := range [][2]int64{{0, 1}, {16383, 16385}, {1 << 20, 1<<20 + 100000}} {
+		bins := reg2bins(r[0], r[1], 14, 5)
+		found := false
+		for _, b := range bins {
+			found = found || b == reg2bin(r[0], r[1], 14, 5)
+		}
+		c.Assert(found, Equals, true)
+	}
+}
+
+func (s *QuerySuite) TestIndexBuilderOrder(c *C) {
+	b, err := NewIndexBuilder(CSI, 14, 6)
+	c.Assert(err, IsNil)
+	c.Assert(b.Add("chr1", 100, 101, 10, 20), IsNil)
+	c.Assert(b.Add("chr1", 100, 110, 20, 30), IsNil)
+	c.Assert(b.Add("chr1", 99, 110, 30, 40), ErrorMatches, "vcfgo: index: records are not sorted: chr1:100 follows chr1:101")
+	c.Assert(b.Add("chr2", 10, 11, 30, 40), IsNil)
+	c.Assert(b.Add("chr1", 200, 201, 40, 50), ErrorMatches, "vcfgo: index: records are not sorted: chr1 appears again after chr2")
+	c.Assert(b.Index().Names, DeepEquals, []string{"chr1", "chr2"})
+	c.Assert(b.Add("chr3", 10, 11, 50, 60), Not(IsNil))
+
+	tb, err := NewIndexBuilder(TBI, 0, 0)
+	c.Assert(err, IsNil)
+	c.Assert(tb.Add("chr1", 1<<29, 1<<29+1, 10, 20), ErrorMatches, ".*outside the range of the tbi binning scheme")
+
+	_, err = NewIndexBuilder(CSI, 20, 20)
+	c.Assert(err, Not(IsNil))
+}
+
+func (s *QuerySuite) TestWriterIndex(c *C) {
+	rdr, err := Open("examples/test.query.vcf", true)
+	c.Assert(err, IsNil)
+	defer rdr.Close()
+
+	var out, idx bytes.Buffer
+	w, err := NewWriterWithOptions(NewBGZFWriter(&out), rdr.Header, WriterOptions{Index: CSI, MinShift: 12, Depth: 7, IndexWriter: &idx})
+	c.Assert(err, IsNil)
+	first := rdr.Read()
+	second := rdr.Read()
+	c.Assert(w.WriteVariant(second), IsNil)
+	start, end := w.Offsets()
+	c.Assert(w.WriteVariant(first), ErrorMatches, "vcfgo: index: records are not sorted.*")
+	// nothing was written for the rejected variant.
+	s2, e2 := w.Offsets()
+	c.Assert(s2, Equals, start)
+	c.Assert(e2, Equals, end)
+	c.Assert(w.Close(), IsNil)
+
+	index, err := ReadIndex(&idx)
+	c.Assert(err, IsNil)
+	c.Assert(index.Format, Equals, CSI)
+	c.Assert(index.MinShift, Equals, 12)
+	c.Assert(index.Names, DeepEquals, []string{"chr1"})
+
+	_, err = NewWriterWithOptions(&out, rdr.Header, WriterOptions{Index: TBI})
+	c.Assert(err, ErrorMatches, ".*BGZF.*")
+
+	path := filepath.Join(c.MkDir(), "x.vcf.gz")
+	w, err = CreateWithOptions(path, rdr.Header, WriterOptions{Index: CSI})
+	c.Assert(err, IsNil)
+	c.Assert(w.WriteVariant(first), IsNil)
+	c.Assert(w.Close(), IsNil)
+	r2, err := Open(path, false)
+	c.Assert(err, IsNil)
+	c.Assert(s.query(c, r2, "chr1", 0, 1<<30), HasLen, 1)
+	c.Assert(r2.index.Format, Equals, CSI)
+	r2.Close()
+}
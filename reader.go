@@ -25,9 +25,9 @@ package vcfgo
 import (
 	"bufio"
 	"bytes"
+	"compress/gzip"
 	"fmt"
 	"io"
-	"log"
 	"math"
 	"os"
 	"strconv"
@@ -49,21 +49,160 @@ type Reader struct {
 	LineNumber  int64
 	lazySamples bool
 	r           io.Reader
+
+	compression Compression
+	bgzf        *bgzfReader
+	// closers are the decompressors between r and buf, innermost first.
+	closers []io.Closer
+	// path is set by Open so that the index can be found for Query.
+	path  string
+	index *Index
+	// bcf is set when the input is BCF rather than text.
+	bcf *bcfDecoder
+
+	workers int
+	pipe    *pipeline
+
+	policy Policy
+	abort  *ParseError
+}
+
+// ReaderOptions configures a Reader created by NewReaderWithOptions or OpenWithOptions.
+type ReaderOptions struct {
+	// LazySamples defers parsing of the sample columns until Header.ParseSamples is called.
+	LazySamples bool
+	// Compression forces the encoding of the input. The default, CompressionAuto,
+	// peeks at the first bytes to choose between plain text, gzip and BGZF.
+	Compression Compression
+	// Workers, if more than 1, is the number of goroutines that parse records.
+	// Read still returns the variants in the order of the file with their
+	// LineNumber and errors as they would be without workers, but the input is
+	// read ahead of the caller, so Query must not be used on the same Reader.
+	// The workers hold a read lock on the Header while parsing; change it only
+	// while holding its write lock. Reader.Close stops the workers.
+	Workers int
+	// Policy, if set, validates records against the header and decides what to
+	// do about each error; see Policy. Errors found by a later call to
+	// Header.ParseSamples, with LazySamples, are not subject to it.
+	Policy Policy
+	// Samples and Fields, if set, are the names of the only samples and FORMAT
+	// fields that Header.ParseSamples decodes; see there. Samples must be in
+	// the header.
+	Samples []string
+	Fields  []string
+	// PercentEncoding sets Header.PercentEncoding, which decides whether INFO
+	// and FORMAT values are percent-decoded by InfoByte.Get and
+	// Variant.GetGenotypeField and encoded by InfoByte.Set.
+	PercentEncoding PercentEncoding
+}
+
+// setInput wraps r in the decompressor required by c and buffers the result.
+func (vr *Reader) setInput(r io.Reader, c Compression) error {
+	vr.r = r
+	buffered := bufio.NewReaderSize(r, 32768*2)
+	if c == CompressionAuto {
+		c = detectCompression(buffered)
+	}
+	switch c {
+	case CompressionGzip:
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			return err
+		}
+		vr.closers = append(vr.closers, gz)
+		buffered = bufio.NewReaderSize(gz, 32768*2)
+	case CompressionBGZF:
+		vr.bgzf = newBGZFReader(buffered, r)
+		vr.closers = append(vr.closers, vr.bgzf)
+		buffered = bufio.NewReaderSize(vr.bgzf, 32768*2)
+	}
+	vr.compression = c
+	vr.buf = buffered
+	return nil
 }
 
 func NewWithHeader(r io.Reader, h *Header, lazySamples bool) (*Reader, error) {
-	buf := bufio.NewReaderSize(r, 32768*2)
-	var verr = NewVCFError()
-	return &Reader{buf, h, verr, 1, lazySamples, r}, nil
+	vr := &Reader{Header: h, verr: NewVCFError(), LineNumber: 1, lazySamples: lazySamples}
+	if err := vr.setInput(r, CompressionAuto); err != nil {
+		return nil, err
+	}
+	return vr, nil
 }
 
 // NewReader returns a Reader.
 // If lazySamples is true, then the user will have to call Reader.ParseSamples()
 // in order to access simple info.
+// gzip and BGZF compressed input is detected and decompressed transparently.
+// BCF input is detected as well and its records are returned as Variants.
 func NewReader(r io.Reader, lazySamples bool) (*Reader, error) {
-	buffered := bufio.NewReaderSize(r, 32768*2)
+	return NewReaderWithOptions(r, ReaderOptions{LazySamples: lazySamples})
+}
+
+// Open opens the VCF at path, which may be plain text, gzip or BGZF, or a BCF.
+// Reader.Close closes the file.
+func Open(path string, lazySamples bool) (*Reader, error) {
+	return OpenWithOptions(path, ReaderOptions{LazySamples: lazySamples})
+}
+
+// OpenWithOptions opens the VCF at path and reads its header according to opts.
+func OpenWithOptions(path string, opts ReaderOptions) (*Reader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	rdr, err := NewReaderWithOptions(f, opts)
+	if rdr == nil {
+		f.Close()
+		return nil, err
+	}
+	rdr.path = path
+	return rdr, err
+}
 
+// NewReaderWithOptions returns a Reader configured by opts after reading the header.
+func NewReaderWithOptions(r io.Reader, opts ReaderOptions) (*Reader, error) {
+	vr := &Reader{lazySamples: opts.LazySamples, workers: opts.Workers, policy: opts.Policy}
+	if err := vr.setInput(r, opts.Compression); err != nil {
+		return nil, err
+	}
+	buffered := vr.buf
+	if isBCF(buffered) {
+		text, err := readBCFHeader(buffered)
+		if err != nil {
+			return nil, err
+		}
+		vr.bcf = newBCFDecoder(text)
+		buffered = bufio.NewReader(strings.NewReader(stripHeaderIDX(text)))
+	}
+	h, verr, LineNumber, errs, err := readHeader(buffered)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Policy != nil {
+		for _, e := range errs {
+			if opts.Policy(e) == Abort {
+				return nil, e
+			}
+		}
+	}
+	h.PercentEncoding = opts.PercentEncoding
+	if err := h.selectSamples(opts.Samples, opts.Fields); err != nil {
+		return nil, err
+	}
+	vr.Header, vr.verr, vr.LineNumber = h, verr, LineNumber
+	return vr, vr.Error()
+}
+
+// readHeader parses the header lines up to and including the #CHROM line.
+func readHeader(buffered *bufio.Reader) (*Header, *VCFError, int64, []*ParseError, error) {
 	var verr = NewVCFError()
+	var errs []*ParseError
+	fail := func(err error, line int64) {
+		if err != nil {
+			errs = append(errs, &ParseError{Line: line, Column: "HEADER", Sample: -1, Kind: ErrInvalidHeader, Err: err})
+			verr.Add(err, line)
+		}
+	}
 
 	var LineNumber int64
 	h := NewHeader()
@@ -73,7 +212,7 @@ func NewReader(r io.Reader, lazySamples bool) (*Reader, error) {
 		LineNumber++
 		line, err := buffered.ReadString('\n')
 		if err != nil && err != io.EOF {
-			verr.Add(err, LineNumber)
+			fail(err, LineNumber)
 		}
 		if len(line) > 1 && line[len(line)-1] == '\n' {
 			line = line[:len(line)-1]
@@ -81,72 +220,99 @@ func NewReader(r io.Reader, lazySamples bool) (*Reader, error) {
 
 		if LineNumber == 1 {
 			v, err := parseHeaderFileVersion(line)
-			verr.Add(err, LineNumber)
+			fail(err, LineNumber)
 			h.FileFormat = v
 
 		} else if strings.HasPrefix(line, "##FORMAT") {
 			format, err := parseHeaderFormat(line)
-			verr.Add(err, LineNumber)
+			fail(err, LineNumber)
 			if format != nil {
 				h.SampleFormats[format.Id] = format
+				h.order = append(h.order, headerEntry{kind: "FORMAT", id: format.Id, raw: line, rendered: format.String()})
+			} else {
+				h.order = append(h.order, headerEntry{kind: "extra", raw: line})
+				h.Extras = append(h.Extras, line)
 			}
 
 		} else if strings.HasPrefix(line, "##INFO") {
 			info, err := parseHeaderInfo(line)
-			verr.Add(err, LineNumber)
+			fail(err, LineNumber)
 			if info != nil {
 				h.Infos[info.Id] = info
+				h.order = append(h.order, headerEntry{kind: "INFO", id: info.Id, raw: line, rendered: info.String()})
+			} else {
+				h.order = append(h.order, headerEntry{kind: "extra", raw: line})
+				h.Extras = append(h.Extras, line)
 			}
 
 		} else if strings.HasPrefix(line, "##FILTER") {
 			filter, err := parseHeaderFilter(line)
-			verr.Add(err, LineNumber)
+			fail(err, LineNumber)
 			if filter != nil && len(filter) == 2 {
 				h.Filters[filter[0]] = filter[1]
+				l, _ := parseHeaderLine(line)
+				h.order = append(h.order, headerEntry{kind: "FILTER", id: filter[0], raw: line, rendered: filterString(filter[0], filter[1], l), line: l})
+			} else {
+				h.order = append(h.order, headerEntry{kind: "extra", raw: line})
+				h.Extras = append(h.Extras, line)
 			}
 
 		} else if strings.HasPrefix(line, "##contig") {
 			contig, err := parseHeaderContig(line)
-			verr.Add(err, LineNumber)
+			fail(err, LineNumber)
 			if contig != nil {
 				if _, ok := contig["ID"]; ok {
 					h.Contigs = append(h.Contigs, contig)
+					var keys []string
+					if l, err := parseHeaderLine(line); err == nil {
+						for _, a := range l.Attrs {
+							keys = append(keys, a.Key)
+						}
+					}
+					h.order = append(h.order, headerEntry{kind: "contig", id: contig["ID"], raw: line, rendered: contigString(contig, keys), keys: keys})
 				} else {
-					verr.Add(fmt.Errorf("bad contig: %v", line), LineNumber)
+					fail(fmt.Errorf("bad contig: %v", line), LineNumber)
 				}
 			}
-		} else if strings.HasPrefix(line, "##SAMPLE") {
-			sample, err := parseHeaderSample(line)
-			verr.Add(err, LineNumber)
-			if sample != "" {
-				h.Samples[sample] = line
+		} else if isStructured(line) {
+			l, err := parseHeaderLine(line)
+			fail(err, LineNumber)
+			if l != nil {
+				h.Lines = append(h.Lines, l)
+				h.order = append(h.order, headerEntry{kind: "line", raw: line, rendered: l.String(), line: l})
+				switch l.Key {
+				case "SAMPLE":
+					h.Samples[l.ID()] = line
+				case "PEDIGREE":
+					h.Pedigrees = append(h.Pedigrees, line)
+				}
 			} else {
-				verr.Add(fmt.Errorf("bad sample: %v", line), LineNumber)
+				h.order = append(h.order, headerEntry{kind: "extra", raw: line})
+				h.Extras = append(h.Extras, line)
 			}
-		} else if strings.HasPrefix(line, "##PEDIGREE") {
-			h.Pedigrees = append(h.Pedigrees, line)
+
 		} else if strings.HasPrefix(line, "##") {
 			kv, err := parseHeaderExtraKV(line)
-			verr.Add(err, LineNumber)
+			fail(err, LineNumber)
 
 			if kv != nil && len(kv) == 2 {
+				h.order = append(h.order, headerEntry{kind: "extra", raw: line})
 				h.Extras = append(h.Extras, line)
 			}
 
 		} else if strings.HasPrefix(line, "#CHROM") {
 			var err error
 			h.SampleNames, err = parseSampleLine(line)
-			verr.Add(err, LineNumber)
+			fail(err, LineNumber)
 			//h.Validate(verr)
 			break
 
 		} else {
 			e := fmt.Errorf("unexpected header line: %s", line)
-			return nil, e
+			return nil, nil, LineNumber, nil, e
 		}
 	}
-	reader := &Reader{buffered, h, verr, LineNumber, lazySamples, r}
-	return reader, reader.Error()
+	return h, verr, LineNumber, errs, nil
 }
 
 func makeFields(line []byte) [][]byte {
@@ -158,8 +324,8 @@ func makeFields(line []byte) [][]byte {
 		}
 		s += len(f) + 1
 	}
+	// a line with too few fields is reported by parse.
 	if s >= len(line) {
-		fmt.Fprintf(os.Stderr, "XXXXX: bad VCF line '%s'", line)
 		return fields
 	}
 	e := bytes.IndexByte(line[s:], '\t')
@@ -176,7 +342,26 @@ func makeFields(line []byte) [][]byte {
 // Read returns a pointer to a Variant. Upon reading the caller is assumed
 // to check Reader.Err()
 func (vr *Reader) Read() *Variant {
+	for vr.abort == nil {
+		v := vr.read()
+		if v == nil {
+			return nil
+		}
+		if vr.judge(v) == Warn {
+			return v
+		}
+	}
+	return nil
+}
 
+// read returns the next variant without applying the Policy.
+func (vr *Reader) read() *Variant {
+	if vr.workers > 1 {
+		return vr.readParallel()
+	}
+	if vr.bcf != nil {
+		return vr.readBCF()
+	}
 	line, err := vr.buf.ReadBytes('\n')
 	if err != nil {
 		if len(line) == 0 && err == io.EOF {
@@ -199,16 +384,33 @@ func unsafeString(b []byte) string {
 }
 
 func (vr *Reader) Parse(fields [][]byte) *Variant {
-	if len(fields) < 7 {
-		s := make([]string, 0)
-		for _, b := range fields {
-			s = append(s, string(b))
+	return vr.parse(fields, vr.LineNumber, vr.verr)
+}
+
+// parse is Parse with the line number and the error sink given explicitly so
+// that it can be called from the goroutines of a pipeline.
+func (vr *Reader) parse(fields [][]byte, lineNumber int64, verr *VCFError) *Variant {
+	v := &Variant{Header: vr.Header, LineNumber: lineNumber}
+	// fail records err on the variant and adds it to the errors of the Reader.
+	fail := func(column, key string, sample int, kind, err error) {
+		if err != nil {
+			v.Errors = append(v.Errors, &ParseError{Line: lineNumber, Column: column, Key: key, Sample: sample, Kind: kind, Err: err})
+			verr.Add(err, lineNumber)
+		}
+	}
+	if len(fields) < 8 {
+		fail("", "", -1, ErrTooFewFields, fmt.Errorf("not enough fields for a VCF: %d", len(fields)))
+		for len(fields) < 8 {
+			fields = append(fields, []byte{'.'})
 		}
-		log.Printf("error at line %d: not enough fields for a VCF. Content was: '%s'\n", vr.LineNumber, strings.Join(s, "\t"))
 	}
 
+	// POS 0 is allowed for telomeres; a negative POS is not.
 	pos, err := strconv.ParseUint(unsafeString(fields[1]), 10, 64)
-	vr.verr.Add(err, vr.LineNumber)
+	if len(fields[1]) > 0 && fields[1][0] == '-' {
+		err = fmt.Errorf("negative POS: %s", fields[1])
+	}
+	fail("POS", "", -1, ErrInvalidValue, err)
 
 	var qual float32
 	if len(fields[5]) == 1 && fields[5][0] == '.' {
@@ -216,46 +418,93 @@ func (vr *Reader) Parse(fields [][]byte) *Variant {
 	} else {
 		q, err := strconv.ParseFloat(unsafeString(fields[5]), 32)
 		qual = float32(q)
-		vr.verr.Add(err, vr.LineNumber)
+		fail("QUAL", "", -1, ErrInvalidValue, err)
 	}
 
-	v := &Variant{Chromosome: string(fields[0]), Pos: pos, Id_: string(fields[2]), Reference: string(fields[3]), Alternate: strings.Split(string(fields[4]), ","), Quality: float32(qual),
-		Filter: string(fields[6]), Header: vr.Header}
+	v.Chromosome, v.Pos, v.Id_, v.Reference = string(fields[0]), pos, string(fields[2]), string(fields[3])
+	v.Alternate, v.Quality, v.Filter = strings.Split(string(fields[4]), ","), float32(qual), string(fields[6])
 
 	if len(fields) > 8 {
 		sample_fields := bytes.SplitN(fields[8], []byte{'\t'}, 2)
 		v.Format = strings.Split(string(sample_fields[0]), ":")
-		v.sampleString = string(sample_fields[1])
-		if !vr.lazySamples {
-			err = vr.Header.ParseSamples(v)
-			vr.verr.Add(err, vr.LineNumber)
+		if len(sample_fields) > 1 {
+			v.sampleString = string(sample_fields[1])
 		}
 	}
-	v.LineNumber = vr.LineNumber
+	if vr.policy != nil {
+		vr.validate(v, fields[7], fail)
+	}
+	if !vr.lazySamples {
+		err = vr.Header.ParseSamples(v)
+		verr.Add(err, lineNumber)
+	}
 
 	v.Info_ = NewInfoByte(fields[7], vr.Header)
 	return v
 }
 
-// Force parsing of the sample fields.
+// Force parsing of the sample fields. Every error is added to v.Errors and the
+// first one is returned.
+// If the Reader was given ReaderOptions.Samples or Fields, only those are
+// decoded: the other entries of v.Samples are nil and their Fields lack the
+// other keys. The sample columns are then kept so that Variant.String and the
+// Writer emit what was not decoded as it was read.
 func (h *Header) ParseSamples(v *Variant) error {
 	if v.Format == nil || v.sampleString == "" || v.Samples != nil {
 		return nil
 	}
-	var errors []error
+	var first error
 	v.Samples = make([]*SampleGenotype, len(h.SampleNames))
+	sel := h.selection
+	raw := strings.Split(v.sampleString, "\t")
+	inputs := h.inputColumns()
+
+	for k := range v.Samples {
+		// i is the column of the sample in the line; see Header.columnIDs.
+		i := k
+		if h.columns != nil {
+			i = h.columns[k]
+		}
+		var sample string
+		switch {
+		case i < len(raw):
+			sample = raw[i]
+		case i >= inputs:
+			// added by Header.AddSample.
+			sample = missingSample(v.Format)
+		default:
+			continue
+		}
+		var want map[string]bool
+		if sel != nil {
+			if i < len(sel.samples) && !sel.samples[i] {
+				continue
+			}
+			want = sel.fields
+		}
+		geno, errs := h.parseSampleFields(v.Format, sample, want)
+		for _, e := range errs {
+			e.Line, e.Sample = v.LineNumber, k
+			if first == nil {
+				first = e.Err
+			}
+			v.Errors = append(v.Errors, e)
+		}
 
-	for i, sample := range strings.Split(v.sampleString, "\t") {
-		geno, moreErrors := h.parseSample(v.Format, sample)
-		errors = append(errors, moreErrors...)
-
-		v.Samples[i] = geno
+		v.Samples[k] = geno
 	}
-	v.sampleString = ""
-	if len(errors) > 0 {
-		return errors[0]
+	// a Reader with a Policy has already reported the extra samples.
+	if len(raw) > inputs && !hasKind(v.Errors, ErrSampleCount) {
+		v.Errors = append(v.Errors, &ParseError{Line: v.LineNumber, Column: "FORMAT", Sample: -1, Kind: ErrSampleCount, Err: fmt.Errorf("more samples than the %d in the header", inputs)})
+		if first == nil {
+			first = v.Errors[len(v.Errors)-1].Err
+		}
 	}
-	return nil
+	v.parsed = h.columns
+	if sel == nil {
+		v.sampleString = ""
+	}
+	return first
 }
 
 // AddInfoToHeader adds a INFO field to the header.
@@ -290,9 +539,26 @@ func (vr *Reader) Clear() {
 	vr.verr.Clear()
 }
 
+// Compression reports the encoding that was detected (or forced) for the input.
+func (vr *Reader) Compression() Compression {
+	return vr.compression
+}
+
+// Close closes any decompressors and then the underlying reader if it is an io.Closer.
 func (vr *Reader) Close() error {
+	if vr.pipe != nil {
+		vr.pipe.close()
+	}
+	var err error
+	for i := len(vr.closers) - 1; i >= 0; i-- {
+		if e := vr.closers[i].Close(); e != nil && err == nil {
+			err = e
+		}
+	}
 	if rc, ok := vr.r.(io.ReadCloser); ok {
-		return rc.Close()
+		if e := rc.Close(); e != nil && err == nil {
+			err = e
+		}
 	}
-	return nil
+	return err
 }
@@ -1,8 +1,10 @@
 package vcfgo_test
 
 import (
+	"errors"
 	"io"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/brentp/vcfgo"
@@ -125,6 +127,79 @@ func (s *ReaderSuite) TestSampleParsingErrors(c *C) {
 	c.Assert(rdr.Error(), ErrorMatches, ".*E.* invalid syntax.*")
 }
 
+func (s *ReaderSuite) TestVariantErrors(c *C) {
+	sr := strings.NewReader(`##fileformat=VCFv4.0
+##FORMAT=<ID=GT,Number=1,Type=String,Description="Genotype">
+##FORMAT=<ID=DP,Number=1,Type=Integer,Description="Depth">
+##FORMAT=<ID=GQ,Number=1,Type=Float,Description="Genotype quality">
+#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO	FORMAT	S-1	S-2
+1	100000	.	C	G	x	.	.	GT:DP:GQ	0|M:3:1.5	1/0:4
+2	200000	.	C	G	.	.	.	GT:DP	0|0:2	0|1:2
+3	10a	.	C	G	.	.`)
+
+	rdr, err := vcfgo.NewReader(sr, false)
+	c.Assert(err, IsNil)
+
+	v := rdr.Read()
+	c.Assert(v.Errors, HasLen, 4)
+	c.Assert(*v.Errors[0], Equals, vcfgo.ParseError{Line: 6, Column: "QUAL", Sample: -1, Kind: vcfgo.ErrInvalidValue, Err: v.Errors[0].Err})
+	c.Assert(*v.Errors[1], Equals, vcfgo.ParseError{Line: 6, Column: "FORMAT", Key: "GT", Sample: 0, Kind: vcfgo.ErrInvalidValue, Err: v.Errors[1].Err})
+	c.Assert(*v.Errors[2], Equals, vcfgo.ParseError{Line: 6, Column: "FORMAT", Key: "GQ", Sample: 0, Kind: vcfgo.ErrTypeMismatch, Err: v.Errors[2].Err})
+	c.Assert(v.Errors[3].Sample, Equals, 1)
+	c.Assert(errors.Is(v.Errors[3], vcfgo.ErrSampleFieldCount), Equals, true)
+	c.Assert(v.Errors[1], ErrorMatches, `line 6 FORMAT GT sample 0: vcfgo: invalid value: .*"M": invalid syntax`)
+
+	var numErr *strconv.NumError
+	c.Assert(errors.As(v.Err(), &numErr), Equals, true)
+	c.Assert(errors.Is(v.Err(), vcfgo.ErrTypeMismatch), Equals, true)
+
+	v = rdr.Read()
+	c.Assert(v.Errors, HasLen, 0)
+	c.Assert(v.Err(), IsNil)
+
+	v = rdr.Read()
+	c.Assert(v.Errors, HasLen, 2)
+	c.Assert(v.Errors[0].Column, Equals, "")
+	c.Assert(errors.Is(v.Err(), vcfgo.ErrTooFewFields), Equals, true)
+	c.Assert(v.Errors[1].Column, Equals, "POS")
+}
+
+func (s *ReaderSuite) TestNegativePos(c *C) {
+	sr := strings.NewReader(`##fileformat=VCFv4.3
+#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO
+1	0	.	C	G	.	.	.
+1	-2147483648	.	C	G	.	.	.
+`)
+	rdr, err := vcfgo.NewReader(sr, false)
+	c.Assert(err, IsNil)
+
+	// POS 0 is a telomere.
+	v := rdr.Read()
+	c.Assert(v.Errors, HasLen, 0)
+	c.Assert(v.Pos, Equals, uint64(0))
+
+	v = rdr.Read()
+	c.Assert(v.Errors, HasLen, 1)
+	c.Assert(v.Errors[0].Column, Equals, "POS")
+	c.Assert(errors.Is(v.Err(), vcfgo.ErrInvalidValue), Equals, true)
+}
+
+func (s *ReaderSuite) TestLazyVariantErrors(c *C) {
+	sr := strings.NewReader(`##fileformat=VCFv4.0
+##FORMAT=<ID=GT,Number=1,Type=String,Description="Genotype">
+#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO	FORMAT	S-1
+1	100000	.	C	G	.	.	.	GT	0|M`)
+
+	rdr, err := vcfgo.NewReader(sr, true)
+	c.Assert(err, IsNil)
+	v := rdr.Read()
+	c.Assert(v.Errors, HasLen, 0)
+	c.Assert(rdr.Header.ParseSamples(v), Not(IsNil))
+	c.Assert(v.Errors, HasLen, 1)
+	c.Assert(v.Errors[0].Line, Equals, int64(4))
+	c.Assert(v.Errors[0].Key, Equals, "GT")
+}
+
 func (s *ReaderSuite) TestSampleParsingErrors2(c *C) {
 	f, err := os.Open("test-dp.vcf")
 	c.Assert(err, IsNil)
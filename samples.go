@@ -0,0 +1,180 @@
+package vcfgo
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RenameSamples renames the samples of h given as keys of names to their
+// values, along with their ##SAMPLE lines and the references to them in
+// ##PEDIGREE lines. Variants keep their sample columns.
+func (h *Header) RenameSamples(names map[string]string) error {
+	renamed := make([]string, len(h.SampleNames))
+	copy(renamed, h.SampleNames)
+	for from, to := range names {
+		i := h.sampleIndex(from)
+		if i == -1 {
+			return fmt.Errorf("vcfgo: sample not found in header: %s", from)
+		}
+		renamed[i] = to
+	}
+	seen := make(map[string]bool, len(renamed))
+	for _, name := range renamed {
+		if seen[name] {
+			return fmt.Errorf("vcfgo: duplicate sample in header: %s", name)
+		}
+		seen[name] = true
+	}
+	h.SampleNames = renamed
+
+	samples := make(map[string]string, len(h.Samples))
+	for id, line := range h.Samples {
+		if to, ok := names[id]; ok {
+			if l, err := parseHeaderLine(line); err == nil {
+				l.Set("ID", to)
+				line = l.String()
+			}
+			id = to
+		}
+		samples[id] = line
+	}
+	h.Samples = samples
+	for _, l := range h.Lines {
+		switch l.Key {
+		case "SAMPLE":
+			if to, ok := names[l.ID()]; ok {
+				l.Set("ID", to)
+			}
+		case "PEDIGREE":
+			for i, a := range l.Attrs {
+				if to, ok := names[a.Value]; ok {
+					l.Attrs[i].Value = to
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// RemoveSamples removes the named samples and their ##SAMPLE lines from h.
+// Variants read with h no longer include them when they are written, whether
+// or not their samples were parsed.
+func (h *Header) RemoveSamples(names []string) error {
+	idx, err := h.sampleIndexes(names)
+	if err != nil {
+		return err
+	}
+	drop := make(map[int]bool, len(idx))
+	for _, i := range idx {
+		drop[i] = true
+	}
+	ids := h.editColumns()
+	sampleNames := make([]string, 0, len(h.SampleNames)-len(drop))
+	columns := make([]int, 0, len(sampleNames))
+	for i, name := range h.SampleNames {
+		if !drop[i] {
+			sampleNames = append(sampleNames, name)
+			columns = append(columns, ids[i])
+		}
+	}
+	h.SampleNames, h.columns = sampleNames, columns
+
+	removed := make(map[string]bool, len(names))
+	for _, name := range names {
+		removed[name] = true
+		delete(h.Samples, name)
+	}
+	lines := h.Lines[:0:0]
+	for _, l := range h.Lines {
+		if l.Key != "SAMPLE" || !removed[l.ID()] {
+			lines = append(lines, l)
+		}
+	}
+	h.Lines = lines
+	return nil
+}
+
+// AddSample adds a sample to the end of h. Variants read with h get missing
+// values for it: "." for each FORMAT field.
+func (h *Header) AddSample(name string) error {
+	if h.sampleIndex(name) != -1 {
+		return fmt.Errorf("vcfgo: duplicate sample in header: %s", name)
+	}
+	ids := h.editColumns()
+	columns := make([]int, len(ids), len(ids)+1)
+	copy(columns, ids)
+	h.SampleNames = append(h.SampleNames[:len(h.SampleNames):len(h.SampleNames)], name)
+	h.columns = append(columns, h.nextColumn)
+	h.nextColumn++
+	return nil
+}
+
+// columnIDs returns the column in the input of each sample of h. The ids of
+// added samples are beyond the columns of the input.
+func (h *Header) columnIDs() []int {
+	if h.columns != nil {
+		return h.columns
+	}
+	ids := make([]int, len(h.SampleNames))
+	for i := range ids {
+		ids[i] = i
+	}
+	return ids
+}
+
+// editColumns is columnIDs for a change to the samples of h. The slices of
+// h.columns are never modified as variants keep them.
+func (h *Header) editColumns() []int {
+	if h.columns == nil {
+		h.inputs = len(h.SampleNames)
+		h.nextColumn = h.inputs
+	}
+	return h.columnIDs()
+}
+
+// inputColumns returns the number of sample columns that records should have.
+func (h *Header) inputColumns() int {
+	if h.columns != nil {
+		return h.inputs
+	}
+	return len(h.SampleNames)
+}
+
+// missingSample returns a sample column with every field of format missing.
+func missingSample(format []string) string {
+	if len(format) == 0 {
+		return "."
+	}
+	return strings.Repeat(".:", len(format)-1) + "."
+}
+
+// sampleLayout returns, for each sample of the header, its column in the line
+// of v (which may be beyond the columns for an added sample) and its index in
+// v.Samples, or -1 if it has none.
+func (v *Variant) sampleLayout() (ids, parsed []int) {
+	ids = v.Header.columnIDs()
+	parsed = make([]int, len(ids))
+	// at maps the column ids of v.Samples to their index.
+	at := make(map[int]int, len(v.Samples))
+	for j := range v.Samples {
+		if v.parsed != nil {
+			at[v.parsed[j]] = j
+		} else {
+			at[j] = j
+		}
+	}
+	for k, id := range ids {
+		if j, ok := at[id]; ok {
+			parsed[k] = j
+		} else {
+			parsed[k] = -1
+		}
+	}
+	return ids, parsed
+}
+
+// edited reports whether the samples of v are not in the order of its line
+// or of v.Samples, because the header changed.
+func (v *Variant) edited() bool {
+	return v.Header != nil && v.Header.columns != nil || v.parsed != nil
+}
@@ -0,0 +1,87 @@
+package vcfgo
+
+import (
+	"bytes"
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+type SamplesSuite struct{}
+
+var _ = Suite(&SamplesSuite{})
+
+func (s *SamplesSuite) TestRename(c *C) {
+	rdr, err := NewReader(strings.NewReader(subsetVCF), false)
+	c.Assert(err, IsNil)
+	h := rdr.Header
+	c.Assert(h.RenameSamples(map[string]string{"S1": "S3", "S3": "S1"}), IsNil)
+	c.Assert(h.SampleNames, DeepEquals, []string{"S3", "S2", "S1"})
+	c.Assert(h.RenameSamples(map[string]string{"S9": "X"}), ErrorMatches, "vcfgo: sample not found in header: S9")
+	c.Assert(h.RenameSamples(map[string]string{"S1": "S2"}), ErrorMatches, "vcfgo: duplicate sample in header: S2")
+	c.Assert(h.SampleNames, DeepEquals, []string{"S3", "S2", "S1"})
+
+	var out bytes.Buffer
+	w, err := NewWriter(&out, h)
+	c.Assert(err, IsNil)
+	c.Assert(w.WriteVariant(rdr.Read()), IsNil)
+	c.Assert(out.String(), Equals, `##fileformat=VCFv4.2
+##contig=<ID=1,length=1000>
+##FORMAT=<ID=GT,Number=1,Type=String,Description="Genotype">
+##FORMAT=<ID=DP,Number=1,Type=Integer,Description="Depth">
+##SAMPLE=<ID=S3,Assay=WGS>
+##SAMPLE=<ID=S2,Assay=WGS>
+##SAMPLE=<ID=S1,Assay=WES>
+#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO	FORMAT	S3	S2	S1
+1	100	.	A	C	.	PASS	.	GT:DP	0/1:10	1/1:20	0/0:30
+`)
+}
+
+func (s *SamplesSuite) TestRemoveAdd(c *C) {
+	for _, lazy := range []bool{false, true} {
+		rdr, err := NewReader(strings.NewReader(subsetVCF), lazy)
+		c.Assert(err, IsNil)
+		h := rdr.Header
+		// read before the header changes.
+		before := rdr.Read()
+
+		c.Assert(h.RemoveSamples([]string{"S2"}), IsNil)
+		c.Assert(h.AddSample("S4"), IsNil)
+		c.Assert(h.AddSample("S1"), ErrorMatches, "vcfgo: duplicate sample in header: S1")
+		c.Assert(h.RemoveSamples([]string{"S9"}), ErrorMatches, "vcfgo: sample not found in header: S9")
+		c.Assert(h.SampleNames, DeepEquals, []string{"S1", "S3", "S4"})
+
+		after := rdr.Read()
+		c.Assert(after.Errors, HasLen, 0)
+		if !lazy {
+			c.Assert(after.Samples, HasLen, 3)
+			c.Assert(after.Samples[1].GT, DeepEquals, []int{0, 1})
+			c.Assert(after.Samples[2].GT, DeepEquals, []int{-1})
+		}
+
+		var out bytes.Buffer
+		w, err := NewWriter(&out, h)
+		c.Assert(err, IsNil)
+		c.Assert(w.WriteVariant(before), IsNil)
+		c.Assert(w.WriteVariant(after), IsNil)
+		c.Assert(out.String(), Equals, `##fileformat=VCFv4.2
+##contig=<ID=1,length=1000>
+##FORMAT=<ID=GT,Number=1,Type=String,Description="Genotype">
+##FORMAT=<ID=DP,Number=1,Type=Integer,Description="Depth">
+##SAMPLE=<ID=S1,Assay=WGS>
+##SAMPLE=<ID=S3,Assay=WES>
+#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO	FORMAT	S1	S3	S4
+1	100	.	A	C	.	PASS	.	GT:DP	0/1:10	0/0:30	.:.
+1	200	.	A	G	.	PASS	.	GT:DP	0/0:1	0/1:3	.:.
+`, Commentf("lazy: %v", lazy))
+
+		dp, _, err := after.FormatInts("DP")
+		c.Assert(err, IsNil)
+		c.Assert(dp, DeepEquals, []int32{1, 3, MISSING_INT})
+
+		// parsed samples follow later changes too.
+		c.Assert(h.ParseSamples(after), IsNil)
+		c.Assert(h.RemoveSamples([]string{"S1"}), IsNil)
+		c.Assert(strings.HasSuffix(after.String(), "\tGT:DP\t0/1:3\t.:."), Equals, true)
+	}
+}
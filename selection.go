@@ -0,0 +1,112 @@
+package vcfgo
+
+import "strings"
+
+// sampleSelection is the subset of samples and FORMAT fields that
+// Header.ParseSamples decodes.
+type sampleSelection struct {
+	// samples is indexed like Header.SampleNames.
+	samples []bool
+	// fields is nil to decode every FORMAT field.
+	fields map[string]bool
+}
+
+// selectSamples restricts the decoding of the sample columns to the named
+// samples and FORMAT fields. Empty samples or fields select all of them.
+func (h *Header) selectSamples(samples, fields []string) error {
+	if len(samples) == 0 && len(fields) == 0 {
+		h.selection = nil
+		return nil
+	}
+	sel := &sampleSelection{samples: make([]bool, len(h.SampleNames))}
+	if len(samples) == 0 {
+		for i := range sel.samples {
+			sel.samples[i] = true
+		}
+	}
+	idx, err := h.sampleIndexes(samples)
+	if err != nil {
+		return err
+	}
+	for _, i := range idx {
+		sel.samples[i] = true
+	}
+	if len(fields) > 0 {
+		sel.fields = make(map[string]bool, len(fields))
+		for _, f := range fields {
+			sel.fields[f] = true
+		}
+	}
+	h.selection = sel
+	return nil
+}
+
+func (h *Header) sampleIndex(name string) int {
+	for i, s := range h.SampleNames {
+		if s == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// sampleColumns returns the text of each sample column of v. A sample that was
+// not decoded, or a field of it that was not, is taken verbatim from the line.
+// If the samples of the header were changed, the columns follow the header.
+func (v *Variant) sampleColumns() []string {
+	var raw []string
+	if v.sampleString != "" {
+		raw = strings.Split(v.sampleString, "\t")
+	}
+	if !v.edited() {
+		if len(v.Samples) == 0 {
+			return raw
+		}
+		columns := make([]string, len(v.Samples))
+		for i, s := range v.Samples {
+			columns[i] = v.sampleColumn(s, i, raw)
+		}
+		return columns
+	}
+	if len(v.Format) == 0 {
+		return nil
+	}
+	ids, parsed := v.sampleLayout()
+	columns := make([]string, len(ids))
+	for k, i := range ids {
+		var s *SampleGenotype
+		if parsed[k] >= 0 {
+			s = v.Samples[parsed[k]]
+		}
+		columns[k] = v.sampleColumn(s, i, raw)
+	}
+	return columns
+}
+
+// sampleColumn renders s, taking what it lacks from column i of raw.
+func (v *Variant) sampleColumn(s *SampleGenotype, i int, raw []string) string {
+	switch {
+	case s == nil && i < len(raw):
+		return raw[i]
+	case s == nil:
+		return missingSample(v.Format)
+	case i < len(raw) && len(s.Fields) < len(v.Format):
+		return s.merge(v.Format, raw[i])
+	}
+	return s.ToString(v.Format)
+}
+
+// merge renders the fields of sg in the order of format, taking those that were
+// not decoded from the raw sample column.
+func (sg *SampleGenotype) merge(format []string, raw string) string {
+	values := strings.Split(raw, ":")
+	for i, f := range format {
+		if v, ok := sg.Fields[f]; ok {
+			for len(values) <= i {
+				values = append(values, ".")
+			}
+			values[i] = v
+		}
+	}
+	return strings.Join(values, ":")
+}
@@ -0,0 +1,70 @@
+package vcfgo
+
+import (
+	"bytes"
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+type SelectionSuite struct{}
+
+var _ = Suite(&SelectionSuite{})
+
+const selectionVCF = `##fileformat=VCFv4.2
+##FORMAT=<ID=GT,Number=1,Type=String,Description="Genotype">
+##FORMAT=<ID=DP,Number=1,Type=Integer,Description="Depth">
+##FORMAT=<ID=AD,Number=R,Type=Integer,Description="Allele depths">
+#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO	FORMAT	S1	S2	S3	S4
+1	100	.	A	C	.	PASS	.	GT:DP:AD	0/1:10:4,6	1/1:bad:0,8	0/0:7:7,0	./.
+`
+
+func (s *SelectionSuite) TestSelect(c *C) {
+	for _, lazy := range []bool{false, true} {
+		rdr, err := NewReaderWithOptions(strings.NewReader(selectionVCF), ReaderOptions{LazySamples: lazy, Samples: []string{"S3", "S1"}, Fields: []string{"GT", "AD"}})
+		c.Assert(err, IsNil)
+		v := rdr.Read()
+		c.Assert(rdr.Header.ParseSamples(v), IsNil)
+		// the bad DP of S2 is neither decoded nor reported.
+		c.Assert(v.Errors, HasLen, 0)
+		c.Assert(v.Samples, HasLen, 4)
+		c.Assert(v.Samples[1], IsNil)
+		c.Assert(v.Samples[3], IsNil)
+		c.Assert(v.Samples[0].GT, DeepEquals, []int{0, 1})
+		c.Assert(v.Samples[0].DP, Equals, 0)
+		c.Assert(v.Samples[0].Fields, DeepEquals, map[string]string{"GT": "0/1", "AD": "4,6"})
+		c.Assert(v.Samples[2].GT, DeepEquals, []int{0, 0})
+
+		c.Assert(strings.HasSuffix(v.String(), "\tGT:DP:AD\t0/1:10:4,6\t1/1:bad:0,8\t0/0:7:7,0\t./."), Equals, true)
+		v.Samples[0].Fields["AD"] = "5,5"
+		c.Assert(strings.HasSuffix(v.String(), "\tGT:DP:AD\t0/1:10:5,5\t1/1:bad:0,8\t0/0:7:7,0\t./."), Equals, true)
+
+		ad, _, err := v.FormatInts("AD")
+		c.Assert(err, IsNil)
+		c.Assert(ad[:6], DeepEquals, []int32{5, 5, 0, 8, 7, 0})
+
+		var out bytes.Buffer
+		w, err := NewWriter(&out, rdr.Header)
+		c.Assert(err, IsNil)
+		c.Assert(w.WriteVariant(v), IsNil)
+		c.Assert(strings.HasSuffix(out.String(), "\t0/1:10:5,5\t1/1:bad:0,8\t0/0:7:7,0\t./.\n"), Equals, true)
+	}
+
+	_, err := NewReaderWithOptions(strings.NewReader(selectionVCF), ReaderOptions{Samples: []string{"S9"}})
+	c.Assert(err, ErrorMatches, "vcfgo: sample not found in header: S9")
+}
+
+func (s *SelectionSuite) TestFieldsOnly(c *C) {
+	rdr, err := NewReaderWithOptions(strings.NewReader(selectionVCF), ReaderOptions{Fields: []string{"GT"}})
+	c.Assert(err, IsNil)
+	v := rdr.Read()
+	// S4 lacks fields, but the bad DP of S2 is not decoded.
+	c.Assert(v.Errors, HasLen, 1)
+	c.Assert(v.Errors[0].Kind, Equals, ErrSampleFieldCount)
+	for _, g := range v.Samples {
+		c.Assert(g, Not(IsNil))
+		c.Assert(len(g.Fields) <= 1, Equals, true)
+	}
+	c.Assert(v.Samples[1].GT, DeepEquals, []int{1, 1})
+	c.Assert(strings.HasSuffix(v.String(), "\t0/1:10:4,6\t1/1:bad:0,8\t0/0:7:7,0\t./."), Equals, true)
+}
@@ -0,0 +1,163 @@
+package vcfgo
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// SetGT sets the genotype of g, a sample of v, to alleles, which are indexes
+// into REF and ALT or -1 for a missing allele. It updates g.GT, g.Phased and
+// g.Fields and adds GT to the front of v.Format if needed.
+func (v *Variant) SetGT(g *SampleGenotype, alleles []int, phased bool) error {
+	sep := "/"
+	if phased {
+		sep = "|"
+	}
+	s := make([]string, len(alleles))
+	for i, a := range alleles {
+		switch {
+		case a == -1:
+			s[i] = "."
+		case a < 0 || a > len(v.Alternate):
+			return fmt.Errorf("SetGT: no allele %d in %s:%d: %w", a, v.Chromosome, v.Pos, ErrInvalidValue)
+		default:
+			s[i] = strconv.Itoa(a)
+		}
+	}
+	if len(s) == 0 {
+		s = append(s, ".")
+	}
+	return v.setField(g, "GT", strings.Join(s, sep), "String", "Character")
+}
+
+// SetInts sets the Integer FORMAT field key of g, a sample of v. MISSING_INT
+// is written as ".". The number of values is checked against the Number of key
+// in the header and key is added to v.Format if needed. DP, GQ and PL also
+// update the pre-parsed fields of g.
+func (v *Variant) SetInts(g *SampleGenotype, key string, values ...int) error {
+	s := make([]string, len(values))
+	for i, x := range values {
+		if x == MISSING_INT {
+			s[i] = "."
+		} else {
+			s[i] = strconv.Itoa(x)
+		}
+	}
+	return v.setField(g, key, strings.Join(s, ","), "Integer")
+}
+
+// SetFloats is SetInts for a Float field. MISSING_VAL (or any NaN) is written as ".".
+func (v *Variant) SetFloats(g *SampleGenotype, key string, values ...float32) error {
+	s := make([]string, len(values))
+	for i, x := range values {
+		if math.IsNaN(float64(x)) {
+			s[i] = "."
+		} else {
+			s[i] = strconv.FormatFloat(float64(x), 'g', -1, 32)
+		}
+	}
+	return v.setField(g, key, strings.Join(s, ","), "Float")
+}
+
+// SetString sets the String or Character FORMAT field key of g, a sample of v,
+// to value, which is percent-encoded if the header says so. A list of values
+// must be joined with "," by the caller.
+func (v *Variant) SetString(g *SampleGenotype, key, value string) error {
+	if v.Header.percentEncoded() {
+		parts := strings.Split(value, ",")
+		for i, p := range parts {
+			parts[i] = PercentEncode(p)
+		}
+		value = strings.Join(parts, ",")
+	}
+	return v.setField(g, key, value, "String", "Character")
+}
+
+// setField checks value against the definition of key in the header and sets
+// it on g.
+func (v *Variant) setField(g *SampleGenotype, key, value string, types ...string) error {
+	if g == nil {
+		return fmt.Errorf("set %s: empty genotype", key)
+	}
+	format, ok := v.Header.SampleFormats[key]
+	if !ok {
+		return fmt.Errorf("set %s: field not found in formats: %w", key, ErrUndeclared)
+	}
+	typed := false
+	for _, t := range types {
+		typed = typed || format.Type == t
+	}
+	if !typed {
+		return fmt.Errorf("set %s: field has Type=%s: %w", key, format.Type, ErrTypeMismatch)
+	}
+	if value == "" {
+		value = "."
+	}
+	ploidy := len(g.GT)
+	if key == "GT" {
+		ploidy = strings.Count(value, "/") + strings.Count(value, "|") + 1
+	}
+	if ploidy == 0 {
+		ploidy = 2
+	}
+	nLocal := -1
+	if strings.HasPrefix(format.Number, "L") {
+		laa, err := v.LocalAlleles(g)
+		if err != nil {
+			return err
+		}
+		nLocal = len(laa)
+	}
+	if kind, err := checkValue(format.Type, format.Number, value, len(v.Alternate), ploidy, nLocal); err != nil {
+		return fmt.Errorf("set %s: %v: %w", key, err, kind)
+	}
+
+	if g.Fields == nil {
+		g.Fields = make(map[string]string)
+	}
+	if err := v.Header.setSampleField(g, key, value); err != nil && err != errGQFloat {
+		return err
+	}
+	v.addFormat(key)
+	return nil
+}
+
+// addFormat adds key to v.Format, first if it is GT, and makes it missing for
+// the other samples, including the columns that were not decoded.
+func (v *Variant) addFormat(key string) {
+	for _, f := range v.Format {
+		if f == key {
+			return
+		}
+	}
+	if key == "GT" {
+		v.Format = append([]string{key}, v.Format...)
+		if v.sampleString != "" {
+			v.sampleString = ".:" + strings.ReplaceAll(v.sampleString, "\t", "\t.:")
+		}
+	} else {
+		v.Format = append(v.Format, key)
+		if v.sampleString != "" {
+			columns := strings.Split(v.sampleString, "\t")
+			for i, col := range columns {
+				if n := strings.Count(col, ":") + 1; n < len(v.Format) {
+					columns[i] = col + strings.Repeat(":.", len(v.Format)-n)
+				}
+			}
+			v.sampleString = strings.Join(columns, "\t")
+		}
+	}
+	for _, s := range v.Samples {
+		if s == nil {
+			continue
+		}
+		if s.Fields == nil {
+			s.Fields = make(map[string]string)
+		}
+		if _, ok := s.Fields[key]; !ok {
+			s.Fields[key] = "."
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package vcfgo
+
+import (
+	"errors"
+	"math"
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+type SettersSuite struct{}
+
+var _ = Suite(&SettersSuite{})
+
+const settersVCF = `##fileformat=VCFv4.3
+##FORMAT=<ID=GT,Number=1,Type=String,Description="Genotype">
+##FORMAT=<ID=DP,Number=1,Type=Integer,Description="Depth">
+##FORMAT=<ID=GQ,Number=1,Type=Integer,Description="Genotype quality">
+##FORMAT=<ID=PL,Number=G,Type=Integer,Description="Phred-scaled likelihoods">
+##FORMAT=<ID=AF,Number=A,Type=Float,Description="Allele fractions">
+##FORMAT=<ID=FT,Number=1,Type=String,Description="Sample filter">
+#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO	FORMAT	S1	S2
+1	100	.	A	C	.	PASS	.	DP	10	20
+`
+
+func (s *SettersSuite) TestSet(c *C) {
+	rdr, err := NewReader(strings.NewReader(settersVCF), false)
+	c.Assert(err, IsNil)
+	v := rdr.Read()
+	g := v.Samples[0]
+
+	c.Assert(v.SetGT(g, []int{0, 1}, true), IsNil)
+	c.Assert(v.Format, DeepEquals, []string{"GT", "DP"})
+	c.Assert(g.GT, DeepEquals, []int{0, 1})
+	c.Assert(g.Phased, Equals, true)
+
+	c.Assert(v.SetInts(g, "DP", 12), IsNil)
+	c.Assert(g.DP, Equals, 12)
+	c.Assert(v.SetInts(g, "GQ", 30), IsNil)
+	c.Assert(g.GQ, Equals, 30)
+	c.Assert(v.SetInts(g, "PL", 30, 0, MISSING_INT), IsNil)
+	c.Assert(g.GL[:2], DeepEquals, []float64{-3, 0})
+	c.Assert(math.IsNaN(g.GL[2]), Equals, true)
+	c.Assert(v.SetFloats(g, "AF", 0.25), IsNil)
+	c.Assert(v.SetString(g, "FT", "low;depth"), IsNil)
+	c.Assert(g.Fields["FT"], Equals, "low%3Bdepth")
+	c.Assert(v.Format, DeepEquals, []string{"GT", "DP", "GQ", "PL", "AF", "FT"})
+
+	c.Assert(strings.HasSuffix(v.String(), "\tGT:DP:GQ:PL:AF:FT\t0|1:12:30:30,0,.:0.25:low%3Bdepth\t.:20:.:.:.:."), Equals, true)
+	ft, err := v.GetGenotypeField(g, "FT", "")
+	c.Assert(err, IsNil)
+	c.Assert(ft, Equals, "low;depth")
+
+	c.Assert(v.SetGT(v.Samples[1], []int{1, -1}, false), IsNil)
+	c.Assert(v.Samples[1].Fields["GT"], Equals, "1/.")
+	c.Assert(v.Samples[1].GT, DeepEquals, []int{1, -1})
+}
+
+func (s *SettersSuite) TestErrors(c *C) {
+	rdr, err := NewReader(strings.NewReader(settersVCF), false)
+	c.Assert(err, IsNil)
+	v := rdr.Read()
+	g := v.Samples[0]
+
+	for _, t := range []struct {
+		err  error
+		kind error
+	}{
+		{v.SetGT(g, []int{0, 2}, false), ErrInvalidValue},
+		{v.SetInts(g, "XX", 1), ErrUndeclared},
+		{v.SetInts(g, "AF", 1), ErrTypeMismatch},
+		{v.SetFloats(g, "DP", 1), ErrTypeMismatch},
+		{v.SetInts(g, "DP", 1, 2), ErrValueCount},
+		{v.SetInts(g, "PL", 1, 2), ErrValueCount},
+		{v.SetFloats(g, "AF", 0.1, 0.2), ErrValueCount},
+		{v.SetString(g, "DP", "x"), ErrTypeMismatch},
+	} {
+		c.Assert(errors.Is(t.err, t.kind), Equals, true, Commentf("%v", t.err))
+	}
+	c.Assert(v.Format, DeepEquals, []string{"DP"})
+	c.Assert(g.DP, Equals, 10)
+}
+
+func (s *SettersSuite) TestSelection(c *C) {
+	rdr, err := NewReaderWithOptions(strings.NewReader(settersVCF), ReaderOptions{Samples: []string{"S2"}})
+	c.Assert(err, IsNil)
+	v := rdr.Read()
+	c.Assert(v.SetGT(v.Samples[1], []int{0, 0}, false), IsNil)
+	c.Assert(v.SetInts(v.Samples[1], "GQ", 5), IsNil)
+	c.Assert(strings.HasSuffix(v.String(), "\tGT:DP:GQ\t.:10:.\t0/0:20:5"), Equals, true)
+}
@@ -3,68 +3,274 @@ package vcfgo
 import (
 	"fmt"
 	"strconv"
+	"strings"
 )
 
+// oldMultiallelic is the INFO tag that SplitAlts adds with the position and
+// alleles of the record that was split, as vt decompose does.
+const oldMultiallelic = "OLD_MULTIALLELIC"
+
+// SplitAlts decomposes v into one bi-allelic record per ALT allele, like
+// bcftools norm -m- or vt decompose. Each record has its own INFO and samples:
+// fields with Number=A, R and G (and the local allele fields of VCF 4.4) keep
+// the values of its allele, GT alleles are re-indexed with the other ALT
+// alleles set to missing, and AN drops the calls of the other alleles. If v
+// has more than one ALT, each record gets an OLD_MULTIALLELIC INFO tag of the
+// form CHROM:POS:REF/ALT1/ALT2, which is added to the header if needed.
+// Values whose count does not match their Number are kept and reported in
+// Errors.
 func SplitAlts(v *Variant) []*Variant {
-	vars := make([]*Variant, len(v.Alt()))
-	for i := range v.Alt() {
-		vars[i] = &Variant{Chromosome: v.Chromosome, Pos: v.Pos, Id_: v.Id_,
+	nAlts := len(v.Alt())
+	if nAlts > 1 && v.Header != nil {
+		v.Header.Lock()
+		if _, ok := v.Header.Infos[oldMultiallelic]; !ok {
+			v.Header.Infos[oldMultiallelic] = &Info{Id: oldMultiallelic, Number: "1", Type: "String", Description: "Original chr:pos:ref:alt encoding"}
+		}
+		v.Header.Unlock()
+	}
+	var raw []string
+	if v.sampleString != "" {
+		raw = strings.Split(v.sampleString, "\t")
+	}
+	vars := make([]*Variant, nAlts)
+	for i := range vars {
+		sv := &Variant{Chromosome: v.Chromosome, Pos: v.Pos, Id_: v.Id_,
 			Reference: v.Ref(), Alternate: []string{v.Alt()[i]}, Quality: v.Quality, Filter: v.Filter,
-			Info_: v.Info_, Samples: v.Samples, sampleString: v.sampleString,
+			Format: append([]string(nil), v.Format...), parsed: v.parsed,
 			Header: v.Header, LineNumber: v.LineNumber}
+		sv.Info_ = NewInfoByte([]byte(splitInfo(sv, v, i)), v.Header)
 
-		split(vars[i], i, len(v.Alt()))
+		if raw != nil {
+			columns := make([]string, len(raw))
+			for j, col := range raw {
+				columns[j] = splitSample(sv, col, i, nAlts)
+			}
+			sv.sampleString = strings.Join(columns, "\t")
+		}
+		if v.Samples != nil {
+			var want map[string]bool
+			if sel := v.Header.selection; sel != nil {
+				want = sel.fields
+			}
+			sv.Samples = make([]*SampleGenotype, len(v.Samples))
+			for j, g := range v.Samples {
+				if g == nil {
+					continue
+				}
+				id := j
+				if v.parsed != nil {
+					id = v.parsed[j]
+				}
+				col := splitSample(sv, v.sampleColumn(g, id, raw), i, nAlts)
+				sv.Samples[j], _ = v.Header.parseSampleFields(sv.Format, col, want)
+			}
+		}
+		vars[i] = sv
 	}
 	return vars
 }
 
-func split(v *Variant, i int, nAlts int) error {
-	// extract the appropriate
-	var err error
-	for _, k := range v.Info_.Keys() {
-		h := v.Header.Infos[k]
-		switch h.Number {
-		case "A":
-			var s interface{}
-			s, err = splitA(v, i, nAlts)
-			v.Info_.Set(k, s)
-		case "G":
-			var s []interface{}
-			var val interface{}
-			val, err = v.Info_.Get(k)
-			s, err = splitG(val, i, nAlts)
-			v.Info_.Set(k, s)
-		case "R":
-			var s []interface{}
-			var val interface{}
-			val, err = v.Info_.Get(k)
-			s, err = splitR(val, i, nAlts)
-			v.Info_.Set(k, s)
-		}
-	}
-	v.Header.ParseSamples(v)
-	for _, h := range v.Header.SampleFormats {
-		if h.Number != "A" && h.Number != "G" && h.Number != "R" {
+// splitInfo returns the INFO of v for its i'th ALT as text. Errors go to sv.
+func splitInfo(sv, v *Variant, i int) string {
+	nAlts := len(v.Alt())
+	text := ""
+	if v.Info_ != nil {
+		text = v.Info_.String()
+	}
+	if text == "." {
+		text = ""
+	}
+	var fields []string
+	var ac []string
+	an := -1
+	for _, kv := range strings.Split(text, ";") {
+		if kv == "" {
 			continue
 		}
-		/*
-			for j, samp := range v.Samples {
-				switch h.Number {
-				case "A":
-					// TODO: should this still be a list?
-					var s interface{}
-					s, err = splitA(samp.Fields[k], i, nAlts)
-					//samp.Fields[k] = s
-
-				case "G":
+		key, val, hasVal := strings.Cut(kv, "=")
+		if key == oldMultiallelic && nAlts > 1 {
+			continue
+		}
+		if def, ok := v.Header.Infos[key]; ok && hasVal && nAlts > 1 {
+			vals := strings.Split(val, ",")
+			if key == "AC" && def.Number == "A" {
+				ac = vals
+			}
+			out, err := splitValues(def.Number, vals, i, nAlts)
+			if err != nil {
+				sv.Errors = append(sv.Errors, &ParseError{Line: v.LineNumber, Column: "INFO", Key: key, Sample: -1, Kind: ErrValueCount, Err: err})
+			} else {
+				val = strings.Join(out, ",")
+			}
+		}
+		if key == "AN" {
+			an = len(fields)
+		}
+		if hasVal {
+			kv = key + "=" + val
+		}
+		fields = append(fields, kv)
+	}
+	// the calls of the other ALT alleles are now missing.
+	if an >= 0 && len(ac) == nAlts && nAlts > 1 {
+		_, val, _ := strings.Cut(fields[an], "=")
+		if n, err := strconv.Atoi(val); err == nil {
+			for j, s := range ac {
+				if c, err := strconv.Atoi(s); err == nil && j != i {
+					n -= c
+				}
+			}
+			fields[an] = "AN=" + strconv.Itoa(n)
+		}
+	}
+	if nAlts > 1 {
+		old := fmt.Sprintf("%s:%d:%s/%s", v.Chromosome, v.Pos, v.Ref(), strings.Join(v.Alt(), "/"))
+		if v.Header.percentEncoded() {
+			old = PercentEncode(old)
+		}
+		fields = append(fields, oldMultiallelic+"="+old)
+	}
+	return strings.Join(fields, ";")
+}
 
-				case "R":
+// splitSample returns a sample column of a record with nAlts ALT alleles for
+// the bi-allelic record sv of its i'th ALT.
+func splitSample(sv *Variant, col string, i, nAlts int) string {
+	if nAlts < 2 {
+		return col
+	}
+	values := strings.Split(col, ":")
+	// local is the index of ALT i in the LAA of the sample, or -1, and nLocal
+	// the number of local ALT alleles.
+	local, nLocal := -1, 0
+	for j, key := range sv.Format {
+		if key == "LAA" && j < len(values) && values[j] != "." {
+			laa := strings.Split(values[j], ",")
+			nLocal = len(laa)
+			for k, a := range laa {
+				if a == strconv.Itoa(i+1) {
+					local = k
 				}
 			}
-		*/
+		}
+	}
+	for j, key := range sv.Format {
+		if j >= len(values) || values[j] == "." {
+			continue
+		}
+		switch key {
+		case "GT":
+			values[j] = splitGenotype(values[j], i+1)
+			continue
+		case "LGT":
+			values[j] = splitGenotype(values[j], local+1)
+			continue
+		case "LAA":
+			values[j] = "."
+			if local >= 0 {
+				values[j] = "1"
+			}
+			continue
+		}
+		def, ok := sv.Header.SampleFormats[key]
+		if !ok {
+			continue
+		}
+		vals := strings.Split(values[j], ",")
+		var out []string
+		var err error
+		switch def.Number {
+		case "A", "R", "G":
+			out, err = splitValues(def.Number, vals, i, nAlts)
+		case "LA", "LR", "LG":
+			// the local alleles are those of a record whose ALTs are in LAA.
+			switch {
+			case local >= 0:
+				out, err = splitValues(def.Number[1:], vals, local, nLocal)
+			case def.Number == "LA":
+				out = []string{"."}
+			default:
+				out = vals[:1]
+			}
+		default:
+			continue
+		}
+		if err != nil {
+			sv.Errors = append(sv.Errors, &ParseError{Line: sv.LineNumber, Column: "FORMAT", Key: key, Sample: -1, Kind: ErrValueCount, Err: err})
+			continue
+		}
+		values[j] = strings.Join(out, ",")
 	}
-	// TODO: v.Samples
-	return err
+	return strings.Join(values, ":")
+}
+
+// splitGenotype re-indexes the alleles of a GT for a bi-allelic record of
+// allele alt: it becomes 1 and the other ALT alleles become missing.
+func splitGenotype(gt string, alt int) string {
+	var alleles []interface{}
+	var seps []byte
+	start := 0
+	for k := 0; k <= len(gt); k++ {
+		if k < len(gt) && !isGenotypeSep(rune(gt[k])) {
+			continue
+		}
+		alleles = append(alleles, gt[start:k])
+		if k < len(gt) {
+			seps = append(seps, gt[k])
+		}
+		start = k + 1
+	}
+	out, _ := splitGT(alleles, alt)
+	b := make([]byte, 0, len(gt))
+	for k, a := range out {
+		b = append(b, a.(string)...)
+		if k < len(seps) {
+			b = append(b, seps[k])
+		}
+	}
+	return string(b)
+}
+
+// splitValues returns the values of a field with Number A, R or G for ALT i
+// (0-based) of nAlts. A G field is taken as haploid if it has one value per allele.
+func splitValues(number string, vals []string, i, nAlts int) ([]string, error) {
+	if len(vals) == 1 && vals[0] == "." {
+		return vals, nil
+	}
+	m := make([]interface{}, len(vals))
+	for k, s := range vals {
+		m[k] = s
+	}
+	var out []interface{}
+	var err error
+	switch number {
+	case "A":
+		var a interface{}
+		if a, err = splitA(m, i, nAlts); err == nil {
+			out = []interface{}{a}
+		}
+	case "R":
+		out, err = splitR(m, i, nAlts)
+	case "G":
+		switch len(vals) {
+		case nAlts + 1:
+			out, err = splitR(m, i, nAlts)
+		case genotypeCount(nAlts+1, 2):
+			out, err = splitG(m, i+1, nAlts)
+		default:
+			err = fmt.Errorf("incorrect number of genotypes in splitG: %v", vals)
+		}
+	default:
+		return vals, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	strs := make([]string, len(out))
+	for k, s := range out {
+		strs[k] = s.(string)
+	}
+	return strs, nil
 }
 
 func splitG(m interface{}, i int, nAlts int) ([]interface{}, error) {
@@ -85,8 +291,7 @@ func splitGT(m interface{}, i int) ([]interface{}, error) {
 		allele := allelei.(string)
 		if allele == "0" {
 			out[i] = "0"
-		}
-		if ai == allele {
+		} else if ai == allele {
 			out[i] = "1"
 		} else {
 			out[i] = "."
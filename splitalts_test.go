@@ -1,6 +1,10 @@
 package vcfgo
 
-import . "gopkg.in/check.v1"
+import (
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
 
 type SplitAltSuite struct {
 }
@@ -79,3 +83,60 @@ func (s *SplitAltSuite) TestSplitGT(c *C) {
 	c.Assert(out, DeepEquals, []interface{}{".", "1"})
 
 }
+
+const splitVCF = `##fileformat=VCFv4.2
+##INFO=<ID=AC,Number=A,Type=Integer,Description="Allele count">
+##INFO=<ID=AN,Number=1,Type=Integer,Description="Allele number">
+##INFO=<ID=AF,Number=A,Type=Float,Description="Allele frequency">
+##INFO=<ID=DP,Number=1,Type=Integer,Description="Depth">
+##FORMAT=<ID=GT,Number=1,Type=String,Description="Genotype">
+##FORMAT=<ID=AD,Number=R,Type=Integer,Description="Allele depths">
+##FORMAT=<ID=PL,Number=G,Type=Integer,Description="Phred-scaled likelihoods">
+##FORMAT=<ID=DP,Number=1,Type=Integer,Description="Depth">
+#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO	FORMAT	S1	S2	S3
+1	100	rs1	A	C,G	50.0	PASS	AC=2,1;AN=6;AF=0.33,0.17;DP=30	GT:AD:PL:DP	0/1:5,4,0:40,0,50,60,70,80:9	1|2:0,6,5:90,80,70,10,20,0:11	0:5,.,1:0,.,9:10
+`
+
+func (s *SplitAltSuite) TestSplitAlts(c *C) {
+	for _, lazy := range []bool{false, true} {
+		rdr, err := NewReader(strings.NewReader(splitVCF), lazy)
+		c.Assert(err, IsNil)
+		v := rdr.Read()
+		vars := SplitAlts(v)
+		c.Assert(vars, HasLen, 2)
+		c.Assert(vars[0].String(), Equals, "1\t100\trs1\tA\tC\t50.0\tPASS\tAC=2;AN=5;AF=0.33;DP=30;OLD_MULTIALLELIC=1:100:A/C/G\tGT:AD:PL:DP\t0/1:5,4:40,0,50:9\t1|.:0,6:90,80,70:11\t0:5,.:0,.:10")
+		c.Assert(vars[1].String(), Equals, "1\t100\trs1\tA\tG\t50.0\tPASS\tAC=1;AN=4;AF=0.17;DP=30;OLD_MULTIALLELIC=1:100:A/C/G\tGT:AD:PL:DP\t0/.:5,0:40,60,80:9\t.|1:0,5:90,10,0:11\t0:5,1:0,9:10")
+		c.Assert(vars[0].Errors, HasLen, 0)
+		c.Assert(rdr.Header.Infos["OLD_MULTIALLELIC"], Not(IsNil))
+
+		// the records do not share their INFO or samples.
+		c.Assert(vars[0].Info().Set("DP", 1), IsNil)
+		dp, _ := vars[1].Info().Get("DP")
+		c.Assert(dp, Equals, 30)
+		if !lazy {
+			c.Assert(vars[1].Samples[1].GT, DeepEquals, []int{-1, 1})
+			c.Assert(vars[1].Samples[1].Phased, Equals, true)
+			vars[0].Samples[0].Fields["DP"] = "1"
+			c.Assert(vars[1].Samples[0].Fields["DP"], Equals, "9")
+			c.Assert(v.Samples[0].Fields["DP"], Equals, "9")
+		}
+	}
+}
+
+func (s *SplitAltSuite) TestSplitLocal(c *C) {
+	rdr, err := NewReader(strings.NewReader(vcf44), true)
+	c.Assert(err, IsNil)
+	vars := SplitAlts(rdr.Read())
+	c.Assert(vars, HasLen, 3)
+	c.Assert(strings.SplitN(vars[0].String(), "\t", 10)[9], Equals, "0/.:.:5:0:.:1,1\t1/.:1:0,4:50,40,30:0.4:1,1\t0/0:.:9:0:.:1,1")
+	c.Assert(strings.SplitN(vars[2].String(), "\t", 10)[9], Equals, "0/1:1:5,7:0,10,20:0.5:1,1\t./.:.:0:50:.:1,1\t0/0:.:9:0:.:1,1")
+}
+
+func (s *SplitAltSuite) TestSplitBad(c *C) {
+	rdr, err := NewReader(strings.NewReader(strings.Replace(splitVCF, "AC=2,1", "AC=2", 1)), true)
+	c.Assert(err, IsNil)
+	vars := SplitAlts(rdr.Read())
+	c.Assert(vars[1].Errors, HasLen, 1)
+	c.Assert(vars[1].Errors[0].Key, Equals, "AC")
+	c.Assert(strings.Contains(vars[1].Info().String(), "AC=2;"), Equals, true)
+}
@@ -0,0 +1,80 @@
+package vcfgo
+
+import "fmt"
+
+// WithSamples returns a copy of h that has only the named samples, in the
+// given order, with the ##SAMPLE lines of the others removed. The INFO, FORMAT
+// and other definitions are copied; the values they point to are shared.
+func (h *Header) WithSamples(names []string) (*Header, error) {
+	idx, err := h.sampleIndexes(names)
+	if err != nil {
+		return nil, err
+	}
+	keep := make(map[string]bool, len(names))
+	for _, name := range names {
+		keep[name] = true
+	}
+
+	c := NewHeader()
+	c.SampleNames = append(c.SampleNames, names...)
+	for k, v := range h.Infos {
+		c.Infos[k] = v
+	}
+	for k, v := range h.SampleFormats {
+		c.SampleFormats[k] = v
+	}
+	for k, v := range h.Filters {
+		c.Filters[k] = v
+	}
+	for k, v := range h.Samples {
+		if keep[k] {
+			c.Samples[k] = v
+		}
+	}
+	for _, l := range h.Lines {
+		if l.Key != "SAMPLE" || keep[l.ID()] {
+			c.Lines = append(c.Lines, l)
+		}
+	}
+	c.Extras = append(c.Extras, h.Extras...)
+	c.Contigs = append(c.Contigs, h.Contigs...)
+	c.Pedigrees = append(c.Pedigrees, h.Pedigrees...)
+	c.FileFormat = h.FileFormat
+	c.PercentEncoding = h.PercentEncoding
+	c.order = h.order
+	ids := h.columnIDs()
+	c.columns = make([]int, len(idx))
+	for i, j := range idx {
+		c.columns[i] = ids[j]
+	}
+	c.inputs, c.nextColumn = h.inputColumns(), h.nextColumn
+	if h.columns == nil {
+		c.nextColumn = c.inputs
+	}
+	return c, nil
+}
+
+// sampleIndexes returns the index in h.SampleNames of each of names.
+func (h *Header) sampleIndexes(names []string) ([]int, error) {
+	idx := make([]int, len(names))
+	for i, name := range names {
+		if idx[i] = h.sampleIndex(name); idx[i] == -1 {
+			return nil, fmt.Errorf("vcfgo: sample not found in header: %s", name)
+		}
+	}
+	return idx, nil
+}
+
+// pickColumns returns the sample columns at the indexes in pick. A column that
+// is beyond those of the record is missing.
+func pickColumns(columns []string, pick []int) []string {
+	out := make([]string, len(pick))
+	for i, j := range pick {
+		if j < len(columns) {
+			out[i] = columns[j]
+		} else {
+			out[i] = "."
+		}
+	}
+	return out
+}
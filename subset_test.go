@@ -0,0 +1,82 @@
+package vcfgo
+
+import (
+	"bytes"
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+type SubsetSuite struct{}
+
+var _ = Suite(&SubsetSuite{})
+
+const subsetVCF = `##fileformat=VCFv4.2
+##contig=<ID=1,length=1000>
+##FORMAT=<ID=GT,Number=1,Type=String,Description="Genotype">
+##FORMAT=<ID=DP,Number=1,Type=Integer,Description="Depth">
+##SAMPLE=<ID=S1,Assay=WGS>
+##SAMPLE=<ID=S2,Assay=WGS>
+##SAMPLE=<ID=S3,Assay=WES>
+#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO	FORMAT	S1	S2	S3
+1	100	.	A	C	.	PASS	.	GT:DP	0/1:10	1/1:20	0/0:30
+1	200	.	A	G	.	PASS	.	GT:DP	0/0:1	./.:.	0/1:3
+`
+
+func (s *SubsetSuite) TestWrite(c *C) {
+	for _, lazy := range []bool{false, true} {
+		rdr, err := NewReader(strings.NewReader(subsetVCF), lazy)
+		c.Assert(err, IsNil)
+		var out bytes.Buffer
+		w, err := NewWriterWithOptions(&out, rdr.Header, WriterOptions{Samples: []string{"S3", "S1"}})
+		c.Assert(err, IsNil)
+		c.Assert(w.Header.SampleNames, DeepEquals, []string{"S3", "S1"})
+		c.Assert(rdr.Header.SampleNames, DeepEquals, []string{"S1", "S2", "S3"})
+		for v := rdr.Read(); v != nil; v = rdr.Read() {
+			c.Assert(w.WriteVariant(v), IsNil)
+		}
+		c.Assert(out.String(), Equals, `##fileformat=VCFv4.2
+##contig=<ID=1,length=1000>
+##FORMAT=<ID=GT,Number=1,Type=String,Description="Genotype">
+##FORMAT=<ID=DP,Number=1,Type=Integer,Description="Depth">
+##SAMPLE=<ID=S1,Assay=WGS>
+##SAMPLE=<ID=S3,Assay=WES>
+#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO	FORMAT	S3	S1
+1	100	.	A	C	.	PASS	.	GT:DP	0/0:30	0/1:10
+1	200	.	A	G	.	PASS	.	GT:DP	0/1:3	0/0:1
+`, Commentf("lazy: %v", lazy))
+	}
+}
+
+func (s *SubsetSuite) TestNoSamples(c *C) {
+	rdr, err := NewReader(strings.NewReader(subsetVCF), true)
+	c.Assert(err, IsNil)
+	var out bytes.Buffer
+	w, err := NewWriterWithOptions(&out, rdr.Header, WriterOptions{Samples: []string{}})
+	c.Assert(err, IsNil)
+	c.Assert(w.WriteVariant(rdr.Read()), IsNil)
+	c.Assert(strings.HasSuffix(out.String(), "\tINFO\n1\t100\t.\tA\tC\t.\tPASS\t.\n"), Equals, true)
+
+	_, err = NewWriterWithOptions(&out, rdr.Header, WriterOptions{Samples: []string{"S4"}})
+	c.Assert(err, ErrorMatches, "vcfgo: sample not found in header: S4")
+}
+
+func (s *SubsetSuite) TestBCF(c *C) {
+	rdr, err := NewReader(strings.NewReader(subsetVCF), true)
+	c.Assert(err, IsNil)
+	var out bytes.Buffer
+	w, err := NewWriterWithOptions(&out, rdr.Header, WriterOptions{BCF: true, Samples: []string{"S2"}})
+	c.Assert(err, IsNil)
+	for v := rdr.Read(); v != nil; v = rdr.Read() {
+		c.Assert(w.WriteVariant(v), IsNil)
+	}
+	c.Assert(w.Close(), IsNil)
+
+	rdr, err = NewReader(bytes.NewReader(out.Bytes()), false)
+	c.Assert(err, IsNil)
+	c.Assert(rdr.Header.SampleNames, DeepEquals, []string{"S2"})
+	v := rdr.Read()
+	c.Assert(v.Samples, HasLen, 1)
+	c.Assert(v.Samples[0].GT, DeepEquals, []int{1, 1})
+	c.Assert(v.Samples[0].DP, Equals, 20)
+}
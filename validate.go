@@ -0,0 +1,203 @@
+package vcfgo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Action is what a Reader does with a record that has a ParseError.
+type Action int
+
+const (
+	// Warn keeps the record; the error is in Variant.Errors and Reader.Error.
+	Warn Action = iota
+	// Skip drops the record and continues with the next one.
+	Skip
+	// Abort stops reading. Read returns nil and Reader.Aborted returns the error.
+	Abort
+)
+
+// Policy chooses the Action for an error found in the header or in a record.
+// Setting a Policy in ReaderOptions also enables the checks of records against
+// the header: undeclared INFO and FORMAT keys, values that do not match the
+// declared Type and Number, and records whose number of samples differs from
+// the header. The Action for a record is the most severe one chosen for its errors.
+type Policy func(*ParseError) Action
+
+// Strict is a Policy that aborts at the first error.
+func Strict(*ParseError) Action { return Abort }
+
+// Lenient is a Policy that reports every error but keeps all records.
+func Lenient(*ParseError) Action { return Warn }
+
+// judge applies the policy of the Reader to the errors of v.
+func (vr *Reader) judge(v *Variant) Action {
+	act := Warn
+	if vr.policy == nil {
+		return act
+	}
+	for _, e := range v.Errors {
+		if a := vr.policy(e); a > act {
+			act = a
+			if a == Abort {
+				vr.abort = e
+				if vr.pipe != nil {
+					vr.pipe.close()
+				}
+				break
+			}
+		}
+	}
+	return act
+}
+
+// Aborted returns the error that stopped the Reader when its Policy chose Abort, or nil.
+func (vr *Reader) Aborted() error {
+	if vr.abort == nil {
+		return nil
+	}
+	return vr.abort
+}
+
+// validate checks the INFO and sample columns of v against the header. It must
+// be called before the samples are parsed.
+func (vr *Reader) validate(v *Variant, info []byte, fail func(column, key string, sample int, kind, err error)) {
+	h := vr.Header
+	nAlts := len(v.Alternate)
+	if nAlts == 1 && v.Alternate[0] == "." {
+		nAlts = 0
+	}
+	if s := string(info); s != "." && s != "" {
+		for _, kv := range strings.Split(s, ";") {
+			key, val, hasVal := strings.Cut(kv, "=")
+			def, ok := h.Infos[key]
+			switch {
+			case !ok:
+				fail("INFO", key, -1, ErrUndeclared, fmt.Errorf("INFO %s is not declared in the header", key))
+			case def.Type == "Flag":
+				if hasVal {
+					fail("INFO", key, -1, ErrTypeMismatch, fmt.Errorf("flag %s has a value: %s", key, val))
+				}
+			case !hasVal:
+				fail("INFO", key, -1, ErrValueCount, fmt.Errorf("INFO %s has no value", key))
+			default:
+				if kind, err := checkValue(def.Type, def.Number, val, nAlts, 2, -1); err != nil {
+					fail("INFO", key, -1, kind, err)
+				}
+			}
+		}
+	}
+
+	var samples []string
+	if v.sampleString != "" {
+		samples = strings.Split(v.sampleString, "\t")
+	}
+	if n := h.inputColumns(); len(samples) != n {
+		fail("FORMAT", "", -1, ErrSampleCount, fmt.Errorf("found %d samples but the header has %d", len(samples), n))
+	}
+	defs := make([]*SampleFormat, len(v.Format))
+	gt, laa := -1, -1
+	for j, key := range v.Format {
+		if defs[j] = h.SampleFormats[key]; defs[j] == nil {
+			fail("FORMAT", key, -1, ErrUndeclared, fmt.Errorf("FORMAT %s is not declared in the header", key))
+		}
+		switch key {
+		case "GT", "LGT":
+			if gt == -1 || key == "GT" {
+				gt = j
+			}
+		case "LAA":
+			laa = j
+		}
+	}
+	for i, sample := range samples {
+		values := strings.Split(sample, ":")
+		// a mismatch with FORMAT is reported when the samples are parsed.
+		if len(values) != len(v.Format) {
+			continue
+		}
+		ploidy := 2
+		if gt >= 0 {
+			ploidy = len(strings.FieldsFunc(values[gt], isGenotypeSep))
+		}
+		nLocal := -1
+		if laa >= 0 {
+			nLocal = 0
+			if values[laa] != "." {
+				nLocal = strings.Count(values[laa], ",") + 1
+			}
+		}
+		for j, def := range defs {
+			if def == nil || j == gt {
+				continue
+			}
+			if kind, err := checkValue(def.Type, def.Number, values[j], nAlts, ploidy, nLocal); err != nil {
+				fail("FORMAT", def.Id, i, kind, err)
+			}
+		}
+	}
+}
+
+// hasKind reports whether one of errs is of the given kind.
+func hasKind(errs []*ParseError, kind error) bool {
+	for _, e := range errs {
+		if e.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// checkValue checks that value has the type and number of values declared in the
+// header and returns the kind of error if it does not. A missing value is allowed.
+// nLocal is the number of local alleles of the sample, or -1 if it is unknown.
+func checkValue(typ, number, value string, nAlts, ploidy, nLocal int) (error, error) {
+	if value == "." {
+		return nil, nil
+	}
+	vals := strings.Split(value, ",")
+	count := -1
+	switch number {
+	case "A":
+		count = nAlts
+	case "R":
+		count = nAlts + 1
+	case "G":
+		count = genotypeCount(nAlts+1, ploidy)
+	case "P":
+		count = ploidy
+	case "LA", "LR", "LG":
+		if nLocal >= 0 {
+			count, _ = numberCount(number, nAlts, ploidy, nLocal)
+		}
+	case ".", "", "M":
+	default:
+		if n, err := strconv.Atoi(number); err == nil {
+			count = n
+		}
+	}
+	if count >= 0 && len(vals) != count {
+		return ErrValueCount, fmt.Errorf("expected %d values but found %d in '%s'", count, len(vals), value)
+	}
+	for _, s := range vals {
+		if s == "." {
+			continue
+		}
+		var err error
+		switch typ {
+		case "Integer":
+			_, err = strconv.Atoi(s)
+		case "Float":
+			_, err = strconv.ParseFloat(s, 32)
+		case "Character":
+			if len(s) != 1 {
+				err = fmt.Errorf("not a single character")
+			}
+		}
+		if err != nil {
+			return ErrTypeMismatch, fmt.Errorf("%s is not of type %s: %w", s, typ, err)
+		}
+	}
+	return nil, nil
+}
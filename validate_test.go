@@ -0,0 +1,154 @@
+package vcfgo
+
+import (
+	"errors"
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+type ValidateSuite struct{}
+
+var _ = Suite(&ValidateSuite{})
+
+const validateHeader = `##fileformat=VCFv4.2
+##INFO=<ID=DP,Number=1,Type=Integer,Description="Depth">
+##INFO=<ID=AF,Number=A,Type=Float,Description="Allele frequency">
+##INFO=<ID=DB,Number=0,Type=Flag,Description="dbSNP membership">
+##FORMAT=<ID=GT,Number=1,Type=String,Description="Genotype">
+##FORMAT=<ID=PL,Number=G,Type=Integer,Description="Phred likelihoods">
+#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO	FORMAT	S1	S2
+`
+
+var validateRecords = []string{
+	"1\t100\t.\tA\tC,G\t.\tPASS\tDP=3;AF=0.1,0.2;DB\tGT:PL\t0/1:0,1,2,3,4,5\t1:0,1,2",
+	"1\t200\t.\tA\tC\t.\tPASS\tXX=1\tGT\t0/1\t0/0",
+	"1\t300\t.\tA\tC\t.\tPASS\tAF=0.1,0.2;DB=1\tGT\t0/1\t0/0",
+	"1\t400\t.\tA\tC\t.\tPASS\tDP=x\tGT:PL\t0/1:0,1\t0/0:0,1,2",
+	"1\t500\t.\tA\tC\t.\tPASS\t.\tGT:YY\t0/1:1\t0/0:2\t1/1:3",
+	"1\t600\t.\tA\tC\t.\tPASS\tDP=4",
+}
+
+func validateReader(c *C, opts ReaderOptions) *Reader {
+	rdr, err := NewReaderWithOptions(strings.NewReader(validateHeader+strings.Join(validateRecords, "\n")+"\n"), opts)
+	c.Assert(err, IsNil)
+	return rdr
+}
+
+type errorSummary struct {
+	Column, Key string
+	Sample      int
+	Kind        error
+}
+
+func summarize(v *Variant) []errorSummary {
+	var out []errorSummary
+	for _, e := range v.Errors {
+		out = append(out, errorSummary{e.Column, e.Key, e.Sample, e.Kind})
+	}
+	return out
+}
+
+func (s *ValidateSuite) TestLenient(c *C) {
+	rdr := validateReader(c, ReaderOptions{Policy: Lenient})
+	var got [][]errorSummary
+	for v := rdr.Read(); v != nil; v = rdr.Read() {
+		got = append(got, summarize(v))
+	}
+	c.Assert(got, DeepEquals, [][]errorSummary{
+		nil,
+		{{"INFO", "XX", -1, ErrUndeclared}},
+		{{"INFO", "AF", -1, ErrValueCount}, {"INFO", "DB", -1, ErrTypeMismatch}},
+		{{"INFO", "DP", -1, ErrTypeMismatch}, {"FORMAT", "PL", 0, ErrValueCount}},
+		{{"FORMAT", "", -1, ErrSampleCount}, {"FORMAT", "YY", -1, ErrUndeclared}},
+		{{"FORMAT", "", -1, ErrSampleCount}},
+	})
+	c.Assert(rdr.Aborted(), IsNil)
+
+	// without a policy the records are not checked against the header.
+	rdr = validateReader(c, ReaderOptions{})
+	for v := rdr.Read(); v != nil; v = rdr.Read() {
+		if v.Pos != 500 {
+			c.Assert(v.Errors, HasLen, 0)
+		}
+	}
+}
+
+func (s *ValidateSuite) TestStrict(c *C) {
+	rdr := validateReader(c, ReaderOptions{Policy: Strict})
+	c.Assert(rdr.Read().Pos, Equals, uint64(100))
+	c.Assert(rdr.Read(), IsNil)
+	c.Assert(rdr.Read(), IsNil)
+	c.Assert(errors.Is(rdr.Aborted(), ErrUndeclared), Equals, true)
+	c.Assert(rdr.Aborted(), ErrorMatches, "line 9 INFO XX: .*")
+	c.Assert(rdr.Error(), Not(IsNil))
+
+	bad := strings.Replace(validateHeader, "Type=Integer", "Type=Int", 1)
+	_, err := NewReaderWithOptions(strings.NewReader(bad), ReaderOptions{Policy: Strict})
+	c.Assert(errors.Is(err, ErrInvalidHeader), Equals, true)
+	var pe *ParseError
+	c.Assert(errors.As(err, &pe), Equals, true)
+	c.Assert(pe.Line, Equals, int64(2))
+	c.Assert(pe.Column, Equals, "HEADER")
+
+	rdr, err = NewReaderWithOptions(strings.NewReader(bad), ReaderOptions{Policy: Lenient})
+	c.Assert(rdr, Not(IsNil))
+	c.Assert(err, Not(IsNil))
+}
+
+func skipUndeclared(e *ParseError) Action {
+	if errors.Is(e, ErrUndeclared) {
+		return Skip
+	}
+	if errors.Is(e, ErrSampleCount) {
+		return Abort
+	}
+	return Warn
+}
+
+func (s *ValidateSuite) TestCustom(c *C) {
+	for _, workers := range []int{0, 3} {
+		parseBatchSize = 2
+		rdr := validateReader(c, ReaderOptions{Policy: skipUndeclared, Workers: workers})
+		parseBatchSize = 512
+		var pos []uint64
+		for v := rdr.Read(); v != nil; v = rdr.Read() {
+			pos = append(pos, v.Pos)
+		}
+		c.Assert(pos, DeepEquals, []uint64{100, 300, 400})
+		c.Assert(errors.Is(rdr.Aborted(), ErrSampleCount), Equals, true)
+		c.Assert(rdr.Close(), IsNil)
+	}
+}
+
+func (s *ValidateSuite) TestCheckValue(c *C) {
+	for _, t := range []struct {
+		typ, number, value string
+		nAlts, ploidy      int
+		nLocal             int
+		kind               error
+	}{
+		{"Integer", "G", "0,1,2,3,4,5", 2, 2, -1, nil},
+		{"Integer", "G", "0,1,2", 2, 1, -1, nil},
+		{"Integer", "G", "0,1,2", 1, 2, -1, nil},
+		{"Integer", "G", "0,1,2,3", 1, 3, -1, nil},
+		{"Integer", "G", "0,1", 1, 2, -1, ErrValueCount},
+		{"Integer", "R", "1,.", 1, 2, -1, nil},
+		{"Integer", "2", "1", 1, 2, -1, ErrValueCount},
+		{"Integer", ".", "1,2,3", 1, 2, -1, nil},
+		{"Float", "A", "1e-3", 1, 2, -1, nil},
+		{"Float", "A", "x", 1, 2, -1, ErrTypeMismatch},
+		{"Character", "1", "ab", 1, 2, -1, ErrTypeMismatch},
+		{"String", "1", ".", 1, 2, -1, nil},
+		{"Integer", "P", "1,2", 1, 2, -1, nil},
+		{"Integer", "LR", "1,2", 3, 2, 1, nil},
+		{"Integer", "LR", "1,2", 3, 2, 2, ErrValueCount},
+		{"Integer", "LG", "1,2,3,4,5,6", 3, 2, 2, nil},
+		{"Integer", "LG", "1,2,3", 3, 2, -1, nil},
+		{"Integer", "LA", "1,2", 3, 2, 0, ErrValueCount},
+	} {
+		kind, err := checkValue(t.typ, t.number, t.value, t.nAlts, t.ploidy, t.nLocal)
+		c.Assert(kind, Equals, t.kind, Commentf("%+v %v", t, err))
+		c.Assert(err == nil, Equals, t.kind == nil)
+	}
+}
@@ -25,8 +25,25 @@ type Variant struct {
 	Samples    []*SampleGenotype
 	// if lazy parsing, then just save the sample strings here.
 	sampleString string
-	Header       *Header
-	LineNumber   int64
+	// parsed holds the column ids (see Header.columnIDs) of Samples if the
+	// header samples had been changed when they were parsed.
+	parsed     []int
+	Header     *Header
+	LineNumber int64
+	// Errors holds the problems found while parsing this variant.
+	Errors []*ParseError
+}
+
+// Err returns the errors found while parsing v joined into one, or nil.
+func (v *Variant) Err() error {
+	if len(v.Errors) == 0 {
+		return nil
+	}
+	errs := make([]error, len(v.Errors))
+	for i, e := range v.Errors {
+		errs[i] = e
+	}
+	return errors.Join(errs...)
 }
 
 func (v *Variant) Info() interfaces.Info {
@@ -116,7 +133,8 @@ func (v *Variant) CIEnd() (uint32, uint32, bool) {
 	return uint32(int(e)+left) - 1, uint32(int(e) + right), true
 }
 
-// End returns the 0-based start + the length of the reference allele.
+// End returns the 0-based start + the length of the reference allele, or
+// the INFO END of a gVCF reference block with the <*> or <NON_REF> ALT.
 func (v *Variant) End() uint32 {
 	/*
 		if len(v.Alt()[0]) == 0 {
@@ -126,6 +144,19 @@ func (v *Variant) End() uint32 {
 	if len(a) == 0 || a[0] != '<' {
 		return uint32(v.Pos-1) + uint32(len(v.Ref()))
 	}
+	if IsUnspecified(a) {
+		// a gVCF reference block extends to INFO/END.
+		end, _ := v.Info().Get("END")
+		switch e := end.(type) {
+		case int:
+			return uint32(e)
+		case string:
+			if n, err := strconv.Atoi(e); err == nil {
+				return uint32(n)
+			}
+		}
+		return uint32(v.Pos-1) + uint32(len(v.Ref()))
+	}
 	if strings.HasPrefix(v.Alt()[0], "<DEL") || strings.HasPrefix(v.Alt()[0], "<DUP") || strings.HasPrefix(v.Alt()[0], "<IN") || strings.HasPrefix(v.Alt()[0], "<CN") {
 		if svlenValue, err := v.Info().Get("SVLEN"); err == nil || (strings.Contains(err.Error(), "not found in header") && svlenValue != nil) {
 			var slen int
@@ -213,7 +244,9 @@ func fmtFloat64(v float64) string {
 }
 
 // SampleGenotype holds the information about a sample. Several fields are pre-parsed, but
-// all fields are kept in Fields as well.
+// all fields are kept in Fields as well. Fields holds the values as they are in
+// the file, so percent-encoded for VCF 4.3; see PercentEncode and
+// Variant.GetGenotypeField.
 type SampleGenotype struct {
 	Phased bool
 	GT     []int
@@ -287,6 +320,28 @@ func NewSampleGenotype() *SampleGenotype {
 
 // String gives a string representation of a variant
 func (v *Variant) String() string {
+	s := v.site()
+	if len(v.Samples) > 0 || v.edited() {
+		if columns := v.sampleColumns(); len(columns) > 0 {
+			s += fmt.Sprintf("\t%s\t%s", strings.Join(v.Format, ":"), strings.Join(columns, "\t"))
+		}
+	} else if v.sampleString != "" {
+		s += fmt.Sprintf("\t%s\t%s", strings.Join(v.Format, ":"), v.sampleString)
+	}
+	return s
+}
+
+// stringWithSamples is String with only the sample columns at the indexes in pick.
+func (v *Variant) stringWithSamples(pick []int) string {
+	s := v.site()
+	if len(pick) > 0 && len(v.Format) > 0 {
+		s += fmt.Sprintf("\t%s\t%s", strings.Join(v.Format, ":"), strings.Join(pickColumns(v.sampleColumns(), pick), "\t"))
+	}
+	return s
+}
+
+// site returns the first eight columns of v.
+func (v *Variant) site() string {
 	var qual string
 
 	if math.Float32bits(v.Quality) == missingBits {
@@ -295,17 +350,7 @@ func (v *Variant) String() string {
 		qual = fmt.Sprintf("%.1f", v.Quality)
 	}
 
-	s := fmt.Sprintf("%s\t%d\t%s\t%s\t%s\t%s\t%s\t%s", v.Chromosome, v.Pos, v.Id_, v.Ref(), strings.Join(v.Alt(), ","), qual, v.Filter, v.Info())
-	if len(v.Samples) > 0 {
-		samps := make([]string, len(v.Samples))
-		for i, s := range v.Samples {
-			samps[i] = s.ToString(v.Format)
-		}
-		s += fmt.Sprintf("\t%s\t%s", strings.Join(v.Format, ":"), strings.Join(samps, "\t"))
-	} else if v.sampleString != "" {
-		s += fmt.Sprintf("\t%s\t%s", strings.Join(v.Format, ":"), v.sampleString)
-	}
-	return s
+	return fmt.Sprintf("%s\t%d\t%s\t%s\t%s\t%s\t%s\t%s", v.Chromosome, v.Pos, v.Id_, v.Ref(), strings.Join(v.Alt(), ","), qual, v.Filter, v.Info())
 }
 
 // GetGenotypeField uses the information from the header to parse the correct time from a genotype field.
@@ -323,6 +368,14 @@ func (v *Variant) GetGenotypeField(g *SampleGenotype, field string, missing inte
 	if !ok {
 		return nil, fmt.Errorf("GetGenotypeField: field not found in genotypes: %s", field)
 	}
+	nLocal := 0
+	if strings.HasPrefix(format.Number, "L") {
+		laa, err := v.LocalAlleles(g)
+		if err != nil {
+			return nil, fmt.Errorf("GetGenotypeField: %s: %w", field, err)
+		}
+		nLocal = len(laa)
+	}
 	switch format.Type {
 	case "Integer":
 		var mv int
@@ -330,7 +383,7 @@ func (v *Variant) GetGenotypeField(g *SampleGenotype, field string, missing inte
 		if mv, ok = missing.(int); !ok {
 			return nil, fmt.Errorf("GetGenotypeField: bad non-int missing value: %v", missing)
 		}
-		return handleNumberType(format.Number, value, len(v.Alt()), len(g.GT), true, mv)
+		return handleNumberType(format.Number, value, len(v.Alt()), samplePloidy(g), nLocal, true, mv)
 
 	case "Float":
 		var mv float32
@@ -338,9 +391,12 @@ func (v *Variant) GetGenotypeField(g *SampleGenotype, field string, missing inte
 		if mv, ok = missing.(float32); !ok {
 			return nil, fmt.Errorf("GetGenotypeField: bad non-float missing value: %v", missing)
 		}
-		return handleNumberType(format.Number, value, len(v.Alt()), len(g.GT), false, mv)
+		return handleNumberType(format.Number, value, len(v.Alt()), samplePloidy(g), nLocal, false, mv)
 
 	case "String", "Character", "Unknown":
+		if h.percentEncoded() {
+			return PercentDecode(value), nil
+		}
 		return value, nil
 
 	case "Flag":
@@ -351,8 +407,41 @@ func (v *Variant) GetGenotypeField(g *SampleGenotype, field string, missing inte
 	return nil, fmt.Errorf("unknown format: %s", format.Type)
 }
 
-func handleNumberType(number string, value string, nAlts int, nGTs int, isInt bool, mv interface{}) (interface{}, error) {
-	if number == "1" || !strings.Contains(value, ",") || number == "." || number == "" {
+// numberCount returns the number of values that a field with the given Number
+// has, and false if it is not fixed.
+func numberCount(number string, nAlts, nGTs, nLocal int) (int, bool) {
+	switch number {
+	case "G":
+		return genotypeCount(nAlts+1, nGTs), true
+	case "A":
+		return nAlts, true
+	case "R":
+		return nAlts + 1, true
+	case "LA":
+		return nLocal, true
+	case "LR":
+		return nLocal + 1, true
+	case "LG":
+		return genotypeCount(nLocal+1, nGTs), true
+	case "P":
+		return nGTs, true
+	}
+	count, err := strconv.Atoi(number)
+	return count, err == nil
+}
+
+// genotypeCount returns the number of unordered genotypes of the given ploidy
+// that can be made from nAlleles alleles.
+func genotypeCount(nAlleles, ploidy int) int {
+	count := 1
+	for k := 1; k <= ploidy; k++ {
+		count = count * (nAlleles - 1 + k) / k
+	}
+	return count
+}
+
+func handleNumberType(number string, value string, nAlts int, nGTs int, nLocal int, isInt bool, mv interface{}) (interface{}, error) {
+	if number == "1" || !strings.Contains(value, ",") {
 		if isInt {
 			if value == "" || value == "." {
 				return (mv).(int), nil
@@ -364,18 +453,9 @@ func handleNumberType(number string, value string, nAlts int, nGTs int, isInt bo
 		}
 		return strconv.ParseFloat(value, 32)
 	}
-	if count, err := strconv.Atoi(number); err == nil || number == "G" || number == "A" || number == "R" {
-		if err != nil {
-			switch number {
-			case "G":
-				count = nGTs * (nGTs + 1) / 2
-			case "A":
-				count = nAlts
-			case "R":
-				count = nAlts + 1
-			}
-			err = nil
-		}
+	// M, like ., has a count that is not known from the record.
+	variable := number == "." || number == "" || number == "M"
+	if count, ok := numberCount(number, nAlts, nGTs, nLocal); ok || variable {
 		var ret interface{}
 		split := strings.Split(value, ",")
 		if isInt {
@@ -387,7 +467,7 @@ func handleNumberType(number string, value string, nAlts int, nGTs int, isInt bo
 		var countErr error
 
 		// caller can ignore error if they want, we still fill what we can.
-		if len(split) != count {
+		if !variable && len(split) != count {
 			countErr = fmt.Errorf("number of fields (%d) does not match expected (%d) in '%s'", len(split), count, value)
 		}
 		for i, s := range split {
@@ -420,9 +500,6 @@ func handleNumberType(number string, value string, nAlts int, nGTs int, isInt bo
 			}
 		}
 		return ret, countErr
-	} else if number == "." || number == "" {
-		return value, nil
-	} else {
-		return nil, fmt.Errorf("unknown number field: %s", number)
 	}
+	return nil, fmt.Errorf("unknown number field: %s", number)
 }
@@ -1,10 +1,69 @@
 package vcfgo
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 )
 
+// The kinds of ParseError. Use errors.Is to test for them.
+var (
+	// ErrTooFewFields means a record has fewer than the 8 fixed columns.
+	ErrTooFewFields = errors.New("vcfgo: too few fields")
+	// ErrInvalidValue means a value could not be parsed.
+	ErrInvalidValue = errors.New("vcfgo: invalid value")
+	// ErrTypeMismatch means a value does not have the type given in the header.
+	ErrTypeMismatch = errors.New("vcfgo: value does not match header type")
+	// ErrSampleFieldCount means a sample has a different number of values than FORMAT has keys.
+	ErrSampleFieldCount = errors.New("vcfgo: sample does not match FORMAT")
+	// ErrSampleCount means a record has a different number of samples than the header.
+	ErrSampleCount = errors.New("vcfgo: number of samples does not match header")
+	// ErrUndeclared means an INFO or FORMAT key is not declared in the header.
+	ErrUndeclared = errors.New("vcfgo: key not declared in header")
+	// ErrValueCount means a value does not have the Number of items declared in the header.
+	ErrValueCount = errors.New("vcfgo: wrong number of values")
+	// ErrInvalidHeader means a header line could not be parsed.
+	ErrInvalidHeader = errors.New("vcfgo: invalid header line")
+	// ErrRefMismatch means the REF of a record does not match the reference genome.
+	ErrRefMismatch = errors.New("vcfgo: REF does not match the reference")
+)
+
+// ParseError describes a problem found in a record. Errors reports both the
+// Kind and the underlying Err, such as a *strconv.NumError, to errors.Is and errors.As.
+type ParseError struct {
+	// Line is the line number of the record.
+	Line int64
+	// Column is the name of the column, e.g. POS or INFO. Values of samples are in
+	// the FORMAT column and errors in the header have the column HEADER.
+	Column string
+	// Key is the INFO or FORMAT key, if any.
+	Key string
+	// Sample is the index of the sample or -1.
+	Sample int
+	Kind   error
+	Err    error
+}
+
+// Error returns a message with the location of the error.
+func (e *ParseError) Error() string {
+	where := fmt.Sprintf("line %d", e.Line)
+	if e.Column != "" {
+		where += " " + e.Column
+	}
+	if e.Key != "" {
+		where += " " + e.Key
+	}
+	if e.Sample >= 0 {
+		where += fmt.Sprintf(" sample %d", e.Sample)
+	}
+	return fmt.Sprintf("%s: %s: %v", where, e.Kind, e.Err)
+}
+
+// Unwrap returns the kind and the underlying error.
+func (e *ParseError) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
 // VCFError satisfies the error interface and allows multiple errors.
 // This is useful because, for example, on a single line, every sample may have
 // a field that doesn't match the description in the header. We want to keep parsing
@@ -12,11 +71,16 @@ import (
 type VCFError struct {
 	Msgs  []string
 	Lines []int64
+	// Dropped counts the oldest errors that were discarded to keep memory bounded.
+	Dropped int
 }
 
 // Error returns a string with all errors delimited by newlines.
 func (e *VCFError) Error() string {
 	var msgs []string
+	if e.Dropped > 0 {
+		msgs = append(msgs, fmt.Sprintf("%d earlier errors were dropped", e.Dropped))
+	}
 	seen := make(map[string]struct{})
 	for i, m := range e.Msgs {
 		// remove duplicates
@@ -35,6 +99,7 @@ func NewVCFError() *VCFError {
 }
 
 // Add adds an error and the line number within the vcf where the error took place.
+// Only the latest errors are kept; see Dropped.
 func (e *VCFError) Add(err error, line int64) {
 	if err != nil {
 		if ierr := err.Error(); ierr != "" {
@@ -46,6 +111,7 @@ func (e *VCFError) Add(err error, line int64) {
 				l = append(l, e.Lines[3000:]...)
 				e.Msgs = m
 				e.Lines = l
+				e.Dropped += 3000
 			}
 			e.Msgs = append(e.Msgs, ierr)
 			e.Lines = append(e.Lines, line)
@@ -62,4 +128,5 @@ func (e *VCFError) IsEmpty() bool {
 func (e *VCFError) Clear() {
 	e.Msgs = e.Msgs[:0]
 	e.Lines = e.Lines[:0]
+	e.Dropped = 0
 }
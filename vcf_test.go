@@ -226,3 +226,16 @@ chr1	123	.	A	C	.	PASS	.
 	c.Assert(strings.Contains(wtr.String(), "\t.\tPASS"), Equals, true)
 
 }
+
+func (s *VCFSuite) TestVCFErrorDropped(c *C) {
+	e := NewVCFError()
+	for i := 0; i < 5001; i++ {
+		e.Add(fmt.Errorf("error %d", i), int64(i))
+	}
+	c.Assert(e.Dropped, Equals, 3000)
+	c.Assert(len(e.Msgs), Equals, 2001)
+	c.Assert(e.Lines[0], Equals, int64(3000))
+	c.Assert(e.Error(), Matches, "(?s)3000 earlier errors were dropped\nerror 3000. \\[line: 3000\\].*")
+	e.Clear()
+	c.Assert(e.Dropped, Equals, 0)
+}
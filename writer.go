@@ -1,8 +1,11 @@
 package vcfgo
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
+	"os"
 	"sort"
 	"strings"
 )
@@ -11,53 +14,321 @@ import (
 type Writer struct {
 	io.Writer
 	Header *Header
+
+	bgzf *BGZFWriter
+	// closer is set when the Writer opened the output itself.
+	closer     io.Closer
+	start, end VirtualOffset
+
+	index *IndexBuilder
+	// indexOut receives the index on Close; if it is nil the index is written to indexPath.
+	indexOut  io.Writer
+	indexPath string
+
+	bcf *bcfEncoder
+	// pick holds the indexes of the columns written when WriterOptions.Samples is set.
+	pick []int
+}
+
+// WriterOptions configures a Writer created by NewWriterWithOptions or CreateWithOptions.
+type WriterOptions struct {
+	// Index, if set, builds an index of that format from the variants as they
+	// are written. The output must be a BGZFWriter and the variants must be sorted.
+	Index IndexFormat
+	// MinShift and Depth set the binning scheme of a CSI index. When they are 0 the tabix scheme is used.
+	MinShift int
+	Depth    int
+	// IndexWriter receives the index when the Writer is closed. CreateWithOptions
+	// writes it to the output path with ".tbi" or ".csi" appended when this is nil.
+	IndexWriter io.Writer
+	// BCF writes BCF rather than VCF text. The output is BGZF compressed, wrapping
+	// the io.Writer in a BGZFWriter if needed, and can only be indexed with CSI.
+	// Every contig, FILTER, INFO and FORMAT used by the variants must be in the header.
+	BCF bool
+	// Samples, if not nil, are the names of the samples of the header to write,
+	// in that order. The header is written by Header.WithSamples and Writer.Header
+	// is set to it; the sample columns of each variant are picked from those of
+	// the original header without decoding them.
+	Samples []string
+}
+
+// Create creates the file at path and writes the header to it. If path ends in
+// .gz or .bgz the output is BGZF compressed and if it ends in .bcf it is BCF.
+// Writer.Close must be called to flush the output and close the file.
+func Create(path string, h *Header) (*Writer, error) {
+	return CreateWithOptions(path, h, WriterOptions{})
+}
+
+// CreateWithOptions is Create with the index options of opts.
+func CreateWithOptions(path string, h *Header, opts WriterOptions) (*Writer, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	var out io.Writer = f
+	if strings.HasSuffix(path, ".bcf") {
+		opts.BCF = true
+	}
+	if opts.BCF || strings.HasSuffix(path, ".gz") || strings.HasSuffix(path, ".bgz") {
+		out = NewBGZFWriter(f)
+	}
+	w, err := NewWriterWithOptions(out, h, opts)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	w.closer = f
+	if w.index != nil && w.indexOut == nil {
+		w.indexPath = path + "." + opts.Index.String()
+	}
+	return w, nil
 }
 
 // NewWriter returns a writer after writing the header.
 func NewWriter(w io.Writer, h *Header) (*Writer, error) {
+	return NewWriterWithOptions(w, h, WriterOptions{})
+}
+
+// NewWriterWithOptions returns a writer configured by opts after writing the header.
+func NewWriterWithOptions(w io.Writer, h *Header, opts WriterOptions) (*Writer, error) {
+	if opts.BCF {
+		if opts.Index == TBI {
+			return nil, errors.New("vcfgo: BCF can only be indexed with CSI")
+		}
+		if _, ok := w.(*BGZFWriter); !ok {
+			w = NewBGZFWriter(w)
+		}
+	}
+	var pick []int
+	if opts.Samples != nil {
+		var err error
+		if pick, err = h.sampleIndexes(opts.Samples); err != nil {
+			return nil, err
+		}
+		if h, err = h.WithSamples(opts.Samples); err != nil {
+			return nil, err
+		}
+	}
+	var index *IndexBuilder
+	if opts.Index != 0 {
+		if _, ok := w.(*BGZFWriter); !ok {
+			return nil, errors.New("vcfgo: an index can only be built for BGZF output")
+		}
+		var err error
+		if index, err = NewIndexBuilder(opts.Index, opts.MinShift, opts.Depth); err != nil {
+			return nil, err
+		}
+	}
+	var enc *bcfEncoder
+	if opts.BCF {
+		var text bytes.Buffer
+		writeHeader(&text, h)
+		enc = newBCFEncoder(h, text.String())
+		enc.pick = pick
+		if err := writeBCFHeader(w, text.String()); err != nil {
+			return nil, err
+		}
+		if index != nil {
+			index.contigs = enc.contigs
+		}
+	} else {
+		writeHeader(w, h)
+	}
+	wtr := &Writer{Writer: w, Header: h, index: index, indexOut: opts.IndexWriter, bcf: enc, pick: pick}
+	if bw, ok := w.(*BGZFWriter); ok {
+		wtr.bgzf = bw
+		// start the records in a new block as htslib does.
+		if err := bw.Flush(); err != nil {
+			return nil, err
+		}
+	}
+	return wtr, nil
+}
+
+// WriteVariant writes a single variant. When an index is being built, it returns
+// an error without writing if v is out of order.
+func (w *Writer) WriteVariant(v *Variant) error {
+	if w.index != nil {
+		if err := w.index.check(v.Chromosome, int64(v.Start()), int64(v.End())); err != nil {
+			return err
+		}
+	}
+	if w.bgzf != nil {
+		w.start = w.bgzf.VirtualOffset()
+	}
+	var err error
+	if w.bcf != nil {
+		err = w.bcf.write(w.Writer, v)
+	} else if w.pick != nil {
+		_, err = fmt.Fprintln(w, v.stringWithSamples(w.pick))
+	} else {
+		_, err = fmt.Fprintln(w, v)
+	}
+	if w.bgzf != nil {
+		w.end = w.bgzf.VirtualOffset()
+	}
+	if err == nil && w.index != nil {
+		err = w.index.Add(v.Chromosome, int64(v.Start()), int64(v.End()), w.start, w.end)
+	}
+	return err
+}
+
+// Offsets returns the virtual offsets of the start and end of the last variant
+// written. They are only set when the Writer targets a BGZFWriter.
+func (w *Writer) Offsets() (start, end VirtualOffset) {
+	return w.start, w.end
+}
+
+// Close closes the BGZF stream, writing its EOF marker, if the Writer targets
+// one, and then the file if the Writer was made by Create. If an index was
+// requested, it is written last.
+func (w *Writer) Close() error {
+	var err error
+	if w.bgzf != nil {
+		err = w.bgzf.Close()
+	}
+	if w.closer != nil {
+		if e := w.closer.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	if w.index != nil && err == nil {
+		err = w.writeIndex()
+	}
+	return err
+}
+
+func (w *Writer) writeIndex() error {
+	idx := w.index.Index()
+	if w.indexOut != nil {
+		_, err := idx.WriteTo(w.indexOut)
+		return err
+	}
+	f, err := os.Create(w.indexPath)
+	if err != nil {
+		return err
+	}
+	if _, err = idx.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeHeader writes the header lines of h. Lines that were read are written
+// in their original order and unchanged unless their value was modified; new
+// lines follow, grouped by kind.
+func writeHeader(w io.Writer, h *Header) {
 	fmt.Fprintf(w, "##fileformat=VCFv%s\n", h.FileFormat)
 
+	written := make(map[string]bool)
+	lines := make(map[*HeaderLine]bool, len(h.Lines))
+	for _, l := range h.Lines {
+		lines[l] = true
+	}
+	extras := make(map[string]int, len(h.Extras))
+	for _, line := range h.Extras {
+		extras[line]++
+	}
+	contigs := make(map[string]map[string]string, len(h.Contigs))
 	for _, imap := range h.Contigs {
-		fmt.Fprintf(w, "##contig=<ID=%s", imap["ID"])
-
-		for k, v := range imap {
-			if k == "ID" {
-				continue
+		contigs[imap["ID"]] = imap
+	}
+	emit := func(e headerEntry, line string) {
+		written[e.kind+"\t"+e.id] = true
+		if line == e.rendered {
+			line = e.raw
+		}
+		fmt.Fprintln(w, line)
+	}
+	for _, e := range h.order {
+		if written[e.kind+"\t"+e.id] && e.id != "" {
+			continue
+		}
+		switch e.kind {
+		case "INFO":
+			if info, ok := h.Infos[e.id]; ok {
+				emit(e, info.String())
+			}
+		case "FORMAT":
+			if format, ok := h.SampleFormats[e.id]; ok {
+				emit(e, format.String())
+			}
+		case "FILTER":
+			if desc, ok := h.Filters[e.id]; ok {
+				emit(e, filterString(e.id, desc, e.line))
+			}
+		case "contig":
+			if imap, ok := contigs[e.id]; ok {
+				emit(e, contigString(imap, e.keys))
+			}
+		case "line":
+			if lines[e.line] {
+				delete(lines, e.line)
+				emit(e, e.line.String())
 			}
+		case "extra":
+			if extras[e.raw] > 0 {
+				extras[e.raw]--
+				fmt.Fprintln(w, e.raw)
+			}
+		}
+	}
 
-			fmt.Fprintf(w, ",%s=%s", k, v)
+	for _, imap := range h.Contigs {
+		if !written["contig\t"+imap["ID"]] {
+			written["contig\t"+imap["ID"]] = true
+			fmt.Fprintln(w, contigString(imap, nil))
 		}
-		fmt.Fprintln(w, ">")
 	}
 
-	// Samples
+	// Samples and pedigrees that are not also structured Lines.
+	samples := make(map[string]bool)
+	pedigrees := false
+	for _, l := range h.Lines {
+		samples[l.ID()] = samples[l.ID()] || l.Key == "SAMPLE"
+		pedigrees = pedigrees || l.Key == "PEDIGREE"
+	}
 	keys := make([]string, 0, len(h.Samples))
 	for sampleId := range h.Samples {
-		keys = append(keys, sampleId)
+		if !samples[sampleId] {
+			keys = append(keys, sampleId)
+		}
 	}
 	sort.Strings(keys)
 	for _, sampleId := range keys {
 		fmt.Fprintln(w, h.Samples[sampleId])
 	}
 
-	for i := range h.Pedigrees {
-		fmt.Fprintln(w, h.Pedigrees[i])
+	if !pedigrees {
+		for i := range h.Pedigrees {
+			fmt.Fprintln(w, h.Pedigrees[i])
+		}
+	}
+	for _, l := range h.Lines {
+		if lines[l] {
+			fmt.Fprintln(w, l)
+		}
 	}
 
 	// Filters
 	keys = keys[:0]
 	for k := range h.Filters {
-		keys = append(keys, k)
+		if !written["FILTER\t"+k] {
+			keys = append(keys, k)
+		}
 	}
 	sort.Strings(keys)
 	for _, k := range keys {
-		fmt.Fprintf(w, "##FILTER=<ID=%s,Description=\"%s\">\n", k, h.Filters[k])
+		fmt.Fprintln(w, filterString(k, h.Filters[k], nil))
 	}
 
 	// Infos
 	keys = keys[:0]
 	for k := range h.Infos {
-		keys = append(keys, k)
+		if !written["INFO\t"+k] {
+			keys = append(keys, k)
+		}
 	}
 	sort.Strings(keys)
 	for _, k := range keys {
@@ -67,14 +338,19 @@ func NewWriter(w io.Writer, h *Header) (*Writer, error) {
 	// SampleFormats
 	keys = keys[:0]
 	for k := range h.SampleFormats {
-		keys = append(keys, k)
+		if !written["FORMAT\t"+k] {
+			keys = append(keys, k)
+		}
 	}
 	sort.Strings(keys)
 	for _, k := range keys {
 		fmt.Fprintf(w, "%s\n", h.SampleFormats[k])
 	}
 	for _, line := range h.Extras {
-		fmt.Fprintf(w, "%s\n", line)
+		if extras[line] > 0 {
+			extras[line]--
+			fmt.Fprintf(w, "%s\n", line)
+		}
 	}
 
 	fmt.Fprint(w, "#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO")
@@ -84,10 +360,44 @@ func NewWriter(w io.Writer, h *Header) (*Writer, error) {
 	}
 
 	fmt.Fprint(w, s+"\n")
-	return &Writer{w, h}, nil
 }
 
-// WriteVariant writes a single variant
-func (w *Writer) WriteVariant(v *Variant) {
-	fmt.Fprintln(w, v)
+// filterString returns a ##FILTER line. The other attributes of l, the line
+// the filter was read from, are kept.
+func filterString(id, desc string, l *HeaderLine) string {
+	f := &HeaderLine{Key: "FILTER"}
+	if l != nil {
+		f.Attrs = append(f.Attrs, l.Attrs...)
+	}
+	f.Set("ID", id)
+	f.Set("Description", desc)
+	return f.String()
+}
+
+// contigString returns a ##contig line with the attributes in the order of
+// keys followed by ID and the others sorted by name.
+func contigString(imap map[string]string, keys []string) string {
+	rest := make([]string, 0, len(imap))
+	for k := range imap {
+		if k != "ID" {
+			rest = append(rest, k)
+		}
+	}
+	sort.Strings(rest)
+	var b strings.Builder
+	b.WriteString("##contig=<")
+	seen := make(map[string]bool, len(imap))
+	for _, k := range append(append(keys, "ID"), rest...) {
+		v, ok := imap[k]
+		if !ok || seen[k] {
+			continue
+		}
+		if len(seen) > 0 {
+			b.WriteByte(',')
+		}
+		seen[k] = true
+		fmt.Fprintf(&b, "%s=%s", k, v)
+	}
+	b.WriteByte('>')
+	return b.String()
 }